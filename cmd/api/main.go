@@ -3,16 +3,30 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/eventbus"
 	"github.com/yokitheyo/todo/internal/handler"
+	"github.com/yokitheyo/todo/internal/idempotency"
+	"github.com/yokitheyo/todo/internal/reminder"
+	"github.com/yokitheyo/todo/internal/repository/file"
 	"github.com/yokitheyo/todo/internal/repository/memory"
+	sqlrepo "github.com/yokitheyo/todo/internal/repository/sql"
 	"github.com/yokitheyo/todo/internal/service"
+	"github.com/yokitheyo/todo/internal/stream"
+	"github.com/yokitheyo/todo/internal/trash"
+	"github.com/yokitheyo/todo/internal/webhook"
 	"github.com/yokitheyo/todo/pkg/logger"
 )
 
@@ -20,14 +34,76 @@ func main() {
 	log := logger.New(getEnv("LOG_LEVEL", "info"), os.Stdout, "json")
 	log.Info("starting todo api server")
 
-	memRepo := memory.NewTodoRepository()
-	todoService := service.NewTodoService(memRepo)
+	repo, closeRepo, err := newTodoRepository(log)
+	if err != nil {
+		log.Error("failed to init storage", "error", err)
+		os.Exit(1)
+	}
+	todoService := service.NewTodoService(repo)
+	bus := eventbus.NewBus(eventbus.Options{Logger: log})
+	todoService.AddEventSink(bus)
+	tagService := service.NewTagService(repo)
+	listService := service.NewListService(repo)
+
+	scheduler := reminder.NewScheduler(repo, newNotifier(log), reminder.Options{
+		RetryInterval:    time.Duration(getEnvAsInt("REMINDER_RETRY_INTERVAL", 5)) * time.Second,
+		MaxRetryInterval: time.Duration(getEnvAsInt("REMINDER_MAX_RETRY_INTERVAL", 300)) * time.Second,
+		Logger:           log,
+	})
+	todoService.SetReminders(scheduler)
+	if err := startScheduler(scheduler); err != nil {
+		log.Error("failed to start reminder scheduler", "error", err)
+		os.Exit(1)
+	}
+
+	// webhooks only reach public addresses unless WEBHOOK_ALLOW_PRIVATE is set
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE"))
+	dispatcher := webhook.NewDispatcher(repo, webhook.Options{
+		Workers:           getEnvAsInt("WEBHOOK_WORKERS", 4),
+		MaxAttempts:       getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 6),
+		AllowPrivateAddrs: allowPrivate,
+		Logger:            log,
+	})
+	dispatcher.Start()
+	bus.SubscribeAsync("webhooks", dispatcher.Publish)
+	webhookService := service.NewWebhookService(repo, dispatcher)
+	if allowPrivate {
+		webhookService.AllowPrivateURLs()
+	}
+
+	// TRASH_RETENTION=0 keeps deleted todos until they are restored
+	var purger *trash.Purger
+	if retention := getEnvAsInt("TRASH_RETENTION", 2592000); retention > 0 {
+		purger = trash.NewPurger(repo, trash.Options{
+			Retention: time.Duration(retention) * time.Second,
+			Interval:  time.Duration(getEnvAsInt("TRASH_PURGE_INTERVAL", 3600)) * time.Second,
+			Logger:    log,
+		})
+		purger.Start()
+	}
+
+	broker := stream.NewBroker(stream.Options{BufferSize: getEnvAsInt("SSE_BUFFER", 1000)})
+	bus.Subscribe("stream", broker.Publish)
 
 	timeout := time.Duration(getEnvAsInt("REQUEST_TIMEOUT", 30)) * time.Second
 	todoHandler := handler.NewTodoHandler(todoService, log, timeout)
+	todoHandler.SetIdempotencyStore(idempotency.NewStore(idempotency.Options{
+		TTL: time.Duration(getEnvAsInt("IDEMPOTENCY_TTL", 86400)) * time.Second,
+	}))
+	tagHandler := handler.NewTagHandler(tagService, log, timeout)
+	listHandler := handler.NewListHandler(listService, todoHandler, log, timeout)
+	webhookHandler := handler.NewWebhookHandler(webhookService, log, timeout)
+	wsHandler := handler.NewWSHandler(todoService, broker, log, timeout)
+	wsHandler.SetAllowedOrigins(getEnvAsList("WS_ALLOWED_ORIGINS"))
+	eventsHandler := handler.NewEventsHandler(broker, log, time.Duration(getEnvAsInt("SSE_HEARTBEAT", 15))*time.Second)
 
 	mux := http.NewServeMux()
 	todoHandler.RegisterRoutes(mux)
+	tagHandler.RegisterRoutes(mux)
+	listHandler.RegisterRoutes(mux)
+	webhookHandler.RegisterRoutes(mux)
+	eventsHandler.RegisterRoutes(mux)
+	wsHandler.RegisterRoutes(mux)
 
 	port := getEnv("PORT", "8080")
 	server := &http.Server{
@@ -37,6 +113,9 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Shutdown waits for requests to finish, so end the event streams and
+	// the WebSocket sessions that follow them
+	server.RegisterOnShutdown(broker.Close)
 
 	go func() {
 		log.Info("server listening", "port", port)
@@ -59,9 +138,110 @@ func main() {
 		log.Error("server forced to shutdown", "error", err)
 	}
 
+	if err := wsHandler.Wait(ctx); err != nil {
+		log.Error("websocket sessions did not end", "error", err)
+	}
+
+	// hands the events still queued to the dispatcher
+	if err := bus.Close(ctx); err != nil {
+		log.Error("failed to drain event bus", "error", err)
+	}
+
+	if err := scheduler.Stop(ctx); err != nil {
+		log.Error("failed to stop reminder scheduler", "error", err)
+	}
+
+	if err := dispatcher.Stop(ctx); err != nil {
+		log.Error("failed to stop webhook dispatcher", "error", err)
+	}
+
+	if purger != nil {
+		if err := purger.Stop(ctx); err != nil {
+			log.Error("failed to stop trash purger", "error", err)
+		}
+	}
+
+	if err := closeRepo(); err != nil {
+		log.Error("failed to close storage", "error", err)
+	}
+
 	log.Info("server stopped")
 }
 
+// repository is what every storage backend provides.
+type repository interface {
+	domain.TodoRepository
+	domain.ReminderRepository
+	domain.TagRepository
+	domain.ListRepository
+	domain.WebhookRepository
+	domain.TrashRepository
+}
+
+func newTodoRepository(log *logger.Logger) (repository, func() error, error) {
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		repo, err := sqlrepo.Open(ctx, databaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		log.Info("using sql storage")
+		return repo, repo.Close, nil
+	}
+
+	storage := getEnv("STORAGE", "memory")
+
+	switch storage {
+	case "memory":
+		log.Info("using in-memory storage")
+		return memory.NewTodoRepository(), func() error { return nil }, nil
+	case "file":
+		policy, err := file.ParseSyncPolicy(getEnv("FSYNC", "always"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dir := getEnv("DATA_DIR", "data")
+		repo, err := file.NewTodoRepository(file.Options{
+			Dir:          dir,
+			SyncPolicy:   policy,
+			SyncInterval: time.Duration(getEnvAsInt("FSYNC_INTERVAL", 1)) * time.Second,
+
+			SnapshotInterval: time.Duration(getEnvAsInt("SNAPSHOT_INTERVAL", 300)) * time.Second,
+			SnapshotEvery:    getEnvAsInt("SNAPSHOT_EVERY", 1000),
+			Logger:           log,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		log.Info("using file storage", "dir", dir)
+		return repo, repo.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage %q", storage)
+	}
+}
+
+// newNotifier posts reminders to REMINDER_WEBHOOK_URL when it is set and
+// logs them otherwise.
+func newNotifier(log *logger.Logger) reminder.Notifier {
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		log.Info("sending reminders to webhook")
+		return reminder.NewWebhookNotifier(url, nil)
+	}
+	return reminder.NewLogNotifier(log)
+}
+
+func startScheduler(scheduler *reminder.Scheduler) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return scheduler.Start(ctx)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -77,3 +257,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsList splits a comma separated variable, dropping empty items.
+func getEnvAsList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
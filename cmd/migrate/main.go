@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/yokitheyo/todo/internal/migrate"
+	sqlrepo "github.com/yokitheyo/todo/internal/repository/sql"
+)
+
+const usage = `usage: migrate [-database URL] <command>
+
+commands:
+  up [N]         apply all pending migrations, or the next N
+  down [N]       revert the last applied migration, or the last N
+  status         list migrations and whether they are applied
+  force VERSION  mark VERSION as the current schema without running SQL
+
+The database URL defaults to $DATABASE_URL.
+`
+
+func main() {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	databaseURL := fs.String("database", os.Getenv("DATABASE_URL"), "database url")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	_ = fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 || *databaseURL == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, *databaseURL, fs.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, databaseURL string, args []string) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	db, dialect, err := sqlrepo.OpenDB(connectCtx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := sqlrepo.NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "up":
+		n, err := optionalCount(rest)
+		if err != nil {
+			return err
+		}
+		applied, err := migrator.Up(ctx, n)
+		for _, v := range applied {
+			fmt.Printf("applied %d\n", v)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		n, err := optionalCount(rest)
+		if err != nil {
+			return err
+		}
+		reverted, err := migrator.Down(ctx, n)
+		for _, v := range reverted {
+			fmt.Printf("reverted %d\n", v)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	case "force":
+		if len(rest) != 1 {
+			return fmt.Errorf("force requires a version")
+		}
+		version, err := strconv.Atoi(rest[0])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", rest[0])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("forced version %d\n", version)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func optionalCount(args []string) (int, error) {
+	switch len(args) {
+	case 0:
+		return 0, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid count %q", args[0])
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("too many arguments")
+	}
+}
+
+func printStatus(statuses []migrate.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+
+	for _, st := range statuses {
+		state, appliedAt := "pending", ""
+		switch {
+		case st.Dirty:
+			state = "dirty"
+		case st.Applied && st.Name == "":
+			state = "applied (missing file)"
+		case st.Applied:
+			state = "applied"
+		}
+		if st.Applied {
+			appliedAt = st.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+	}
+
+	w.Flush()
+}
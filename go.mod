@@ -1,3 +1,8 @@
 module github.com/yokitheyo/todo
 
 go 1.25.4
+
+require (
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.32
+)
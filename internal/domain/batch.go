@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const MaxBatchSize = 100
+
+var (
+	ErrEmptyBatch              = errors.New("batch has no operations")
+	ErrBatchTooLarge           = errors.New("batch has too many operations(max 100)")
+	ErrInvalidBatchOp          = errors.New("invalid batch operation")
+	ErrTransactionsUnsupported = errors.New("storage does not support atomic batches")
+)
+
+// Transactor is implemented by repositories that can apply several changes
+// all-or-nothing. fn gets a repository scoped to the transaction; when fn
+// returns an error none of the changes made through it are kept.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(repo TodoRepository) error) error
+}
+
+type BatchOpType string
+
+const (
+	BatchCreate BatchOpType = "create"
+	BatchUpdate BatchOpType = "update"
+	BatchDelete BatchOpType = "delete"
+)
+
+// BatchOp is one operation of POST /todos:batch. Create uses Input, update
+// applies Patch to todo ID like PATCH does and delete removes todo ID with
+// its subtasks handled as Children says. A non-zero Version makes an update
+// or delete conditional.
+type BatchOp struct {
+	Op       BatchOpType
+	ID       int
+	Input    CreateTodoInput
+	Patch    TodoPatch
+	Version  int
+	Children ChildPolicy
+}
+
+// BatchResult is the outcome of one operation: the todo it created or
+// updated, or the error it failed with. ID is the todo it applied to.
+type BatchResult struct {
+	ID   int
+	Todo *Todo
+	Err  error
+}
+
+// BatchError is returned when an atomic batch fails; Index is the position
+// of the operation that failed, and nothing of the batch was applied.
+type BatchError struct {
+	Index int
+	ID    int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
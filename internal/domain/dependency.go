@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrDependencyNotFound = errors.New("dependency not found")
+	ErrDependencyExists   = errors.New("dependency already exists")
+	ErrDependencyCycle    = errors.New("dependency would create a cycle")
+	ErrTodoBlocked        = errors.New("todo is blocked by open todos")
+)
+
+// Dependency says the todo TodoID is blocked by the todo BlockerID until that
+// one is completed.
+type Dependency struct {
+	TodoID    int `json:"todo_id"`
+	BlockerID int `json:"blocker_id"`
+}
+
+// DependencyRepository keeps the blocker graph between todos. Stores that
+// implement it fill Todo.BlockedBy and Todo.Blocked, and drop the edges of a
+// todo when it is deleted. AddDependency fails with ErrDependencyCycle when
+// the edge would close a cycle, checked atomically with adding it.
+type DependencyRepository interface {
+	AddDependency(ctx context.Context, dep Dependency) error
+	RemoveDependency(ctx context.Context, dep Dependency) error
+	ListDependencies(ctx context.Context) ([]Dependency, error)
+}
+
+// AddBlockerInput is the body of POST /todos/{id}/blockers.
+type AddBlockerInput struct {
+	BlockerID int `json:"blocker_id"`
+}
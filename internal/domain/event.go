@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType names a change in a todo's lifecycle.
+type EventType string
+
+const (
+	EventTodoCreated   EventType = "todo.created"
+	EventTodoUpdated   EventType = "todo.updated"
+	EventTodoCompleted EventType = "todo.completed"
+	EventTodoDeleted   EventType = "todo.deleted"
+	EventTodoRestored  EventType = "todo.restored"
+)
+
+// EventTypes lists every event TodoService publishes.
+var EventTypes = []EventType{EventTodoCreated, EventTodoUpdated, EventTodoCompleted, EventTodoDeleted, EventTodoRestored}
+
+func (t EventType) Valid() bool {
+	for _, typ := range EventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// TodoEvent is published by TodoService after a todo changed. Completing a
+// todo publishes todo.updated followed by todo.completed. Todo is the state
+// after the change, or the last one for todo.deleted; Before is the state
+// before todo.updated and todo.completed, and nil for the other events.
+type TodoEvent struct {
+	Type       EventType `json:"type"`
+	Todo       Todo      `json:"todo"`
+	Before     *Todo     `json:"before,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Matches reports whether the todo matched filter before or after the
+// change, so a subscriber also sees a todo leave what it is watching.
+func (e TodoEvent) Matches(filter func(Todo) bool) bool {
+	return filter(e.Todo) || (e.Before != nil && filter(*e.Before))
+}
+
+// NormalizeEventTypes checks types and drops duplicates, keeping the order
+// of EventTypes.
+func NormalizeEventTypes(types []EventType) ([]EventType, error) {
+	seen := make(map[EventType]bool, len(types))
+	for _, t := range types {
+		if !t.Valid() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEventType, t)
+		}
+		seen[t] = true
+	}
+
+	out := []EventType{}
+	for _, t := range EventTypes {
+		if seen[t] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
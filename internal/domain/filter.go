@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FilterSyntaxError points at the token of a filter expression that could
+// not be parsed. Pos is the 1-based character offset of that token.
+type FilterSyntaxError struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("invalid filter at position %d near %q: %s", e.Pos, e.Token, e.Msg)
+}
+
+func (e *FilterSyntaxError) Unwrap() error {
+	return ErrInvalidFilter
+}
+
+type FilterField string
+
+const (
+	FilterID          FilterField = "id"
+	FilterTitle       FilterField = "title"
+	FilterDescription FilterField = "description"
+	FilterCompleted   FilterField = "completed"
+	FilterCreatedAt   FilterField = "created_at"
+	FilterUpdatedAt   FilterField = "updated_at"
+)
+
+type FilterOp string
+
+const (
+	OpEq       FilterOp = "="
+	OpNe       FilterOp = "!="
+	OpLt       FilterOp = "<"
+	OpLe       FilterOp = "<="
+	OpGt       FilterOp = ">"
+	OpGe       FilterOp = ">="
+	OpIn       FilterOp = "in"
+	OpPrefix   FilterOp = "prefix"
+	OpContains FilterOp = "contains"
+)
+
+// FilterExpr is a parsed filter expression. Repositories either call Match
+// or translate the tree (AndExpr, OrExpr, NotExpr, Condition) themselves.
+type FilterExpr interface {
+	Match(todo Todo) bool
+}
+
+type AndExpr struct {
+	Exprs []FilterExpr
+}
+
+func (e AndExpr) Match(todo Todo) bool {
+	for _, x := range e.Exprs {
+		if !x.Match(todo) {
+			return false
+		}
+	}
+	return true
+}
+
+type OrExpr struct {
+	Exprs []FilterExpr
+}
+
+func (e OrExpr) Match(todo Todo) bool {
+	for _, x := range e.Exprs {
+		if x.Match(todo) {
+			return true
+		}
+	}
+	return false
+}
+
+type NotExpr struct {
+	Expr FilterExpr
+}
+
+func (e NotExpr) Match(todo Todo) bool {
+	return !e.Expr.Match(todo)
+}
+
+// Condition compares one field with Value, which is an int for id, []int for
+// id in, a string for title and description, a bool for completed and a
+// time.Time for the timestamps. Prefix and contains ignore case.
+type Condition struct {
+	Field FilterField
+	Op    FilterOp
+	Value interface{}
+}
+
+func (c Condition) Match(todo Todo) bool {
+	switch c.Field {
+	case FilterID:
+		if c.Op == OpIn {
+			for _, id := range c.Value.([]int) {
+				if todo.ID == id {
+					return true
+				}
+			}
+			return false
+		}
+		return compareResult(compareInt(todo.ID, c.Value.(int)), c.Op)
+	case FilterTitle:
+		return matchString(todo.Title, c.Op, c.Value.(string))
+	case FilterDescription:
+		return matchString(todo.Description, c.Op, c.Value.(string))
+	case FilterCompleted:
+		return compareResult(compareBool(todo.Completed, c.Value.(bool)), c.Op)
+	case FilterCreatedAt:
+		return compareResult(todo.CreatedAt.Compare(c.Value.(time.Time)), c.Op)
+	case FilterUpdatedAt:
+		return compareResult(todo.UpdatedAt.Compare(c.Value.(time.Time)), c.Op)
+	}
+	return false
+}
+
+func matchString(s string, op FilterOp, v string) bool {
+	switch op {
+	case OpPrefix:
+		return strings.HasPrefix(strings.ToLower(s), strings.ToLower(v))
+	case OpContains:
+		return strings.Contains(strings.ToLower(s), strings.ToLower(v))
+	default:
+		return compareResult(strings.Compare(s, v), op)
+	}
+}
+
+func compareResult(c int, op FilterOp) bool {
+	switch op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	}
+	return false
+}
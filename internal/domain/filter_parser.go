@@ -0,0 +1,346 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MaxFilterLength = 1024
+	maxFilterDepth  = 32
+)
+
+// ParseFilter parses a filter expression such as
+//
+//	title prefix "buy" AND (completed = false OR id in (1, 2, 3))
+//	created_at >= 2024-01-01 AND NOT updated_at < "2024-02-01T10:00:00Z"
+//
+// Keywords (AND, OR, NOT, IN, PREFIX, CONTAINS, TRUE, FALSE) are case
+// insensitive. Timestamps are RFC 3339 or a bare date meaning midnight UTC.
+// NOT binds tighter than AND, which binds tighter than OR.
+func ParseFilter(input string) (FilterExpr, error) {
+	if len(input) > MaxFilterLength {
+		return nil, &FilterSyntaxError{Pos: MaxFilterLength + 1, Msg: fmt.Sprintf("expression longer than %d characters", MaxFilterLength)}
+	}
+
+	tokens, err := lexFilter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok, "expected AND, OR or end of expression")
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) is(keyword string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, keyword)
+}
+
+func lexFilter(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", pos})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokComma, ",", pos})
+			i++
+		case r == '!' || r == '<' || r == '>' || r == '=':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &FilterSyntaxError{Pos: pos, Token: op, Msg: `expected "!="`}
+			}
+			tokens = append(tokens, token{tokOp, op, pos})
+			i += len(op)
+		case r == '"':
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if c == '"' {
+					closed = true
+					break
+				}
+				b.WriteRune(c)
+			}
+			if !closed {
+				return nil, &FilterSyntaxError{Pos: pos, Token: string(runes[pos-1:]), Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{tokString, b.String(), pos})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`(),"!<>=`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokWord, string(runes[start:i]), pos})
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes) + 1})
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	i      int
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *filterParser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *filterParser) errorAt(tok token, msg string) error {
+	return &FilterSyntaxError{Pos: tok.pos, Token: tok.text, Msg: msg}
+}
+
+func (p *filterParser) parseOr(depth int) (FilterExpr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []FilterExpr{left}
+	for p.peek().is("or") {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+
+	if len(exprs) == 1 {
+		return left, nil
+	}
+	return OrExpr{Exprs: exprs}, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (FilterExpr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []FilterExpr{left}
+	for p.peek().is("and") {
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+
+	if len(exprs) == 1 {
+		return left, nil
+	}
+	return AndExpr{Exprs: exprs}, nil
+}
+
+func (p *filterParser) parseUnary(depth int) (FilterExpr, error) {
+	if depth > maxFilterDepth {
+		return nil, p.errorAt(p.peek(), "expression nested too deeply")
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.is("not"):
+		p.next()
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return NotExpr{Expr: expr}, nil
+	case tok.kind == tokLParen:
+		p.next()
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorAt(closing, `expected ")"`)
+		}
+		return expr, nil
+	default:
+		return p.parseCondition()
+	}
+}
+
+var filterOps = map[FilterField][]FilterOp{
+	FilterID:          {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe, OpIn},
+	FilterTitle:       {OpEq, OpNe, OpPrefix, OpContains},
+	FilterDescription: {OpEq, OpNe, OpPrefix, OpContains},
+	FilterCompleted:   {OpEq, OpNe},
+	FilterCreatedAt:   {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+	FilterUpdatedAt:   {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+}
+
+func (p *filterParser) parseCondition() (FilterExpr, error) {
+	fieldTok := p.next()
+	if fieldTok.kind != tokWord {
+		return nil, p.errorAt(fieldTok, "expected field name")
+	}
+
+	field := FilterField(strings.ToLower(fieldTok.text))
+	allowed, ok := filterOps[field]
+	if !ok {
+		return nil, p.errorAt(fieldTok, "unknown field")
+	}
+
+	opTok := p.next()
+	var op FilterOp
+	switch {
+	case opTok.kind == tokOp:
+		op = FilterOp(opTok.text)
+	case opTok.is("in"), opTok.is("prefix"), opTok.is("contains"):
+		op = FilterOp(strings.ToLower(opTok.text))
+	default:
+		return nil, p.errorAt(opTok, "expected operator")
+	}
+	if !containsOp(allowed, op) {
+		return nil, p.errorAt(opTok, fmt.Sprintf("operator not supported for %s", field))
+	}
+
+	cond := Condition{Field: field, Op: op}
+	if op == OpIn {
+		ids, err := p.parseIDList()
+		if err != nil {
+			return nil, err
+		}
+		cond.Value = ids
+		return cond, nil
+	}
+
+	valTok := p.next()
+	if valTok.kind != tokWord && valTok.kind != tokString {
+		return nil, p.errorAt(valTok, "expected value")
+	}
+
+	switch field {
+	case FilterID:
+		id, err := strconv.Atoi(valTok.text)
+		if err != nil {
+			return nil, p.errorAt(valTok, "expected integer id")
+		}
+		cond.Value = id
+	case FilterTitle, FilterDescription:
+		cond.Value = valTok.text
+	case FilterCompleted:
+		switch {
+		case valTok.is("true"):
+			cond.Value = true
+		case valTok.is("false"):
+			cond.Value = false
+		default:
+			return nil, p.errorAt(valTok, "expected true or false")
+		}
+	case FilterCreatedAt, FilterUpdatedAt:
+		t, err := ParseTime(valTok.text)
+		if err != nil {
+			return nil, p.errorAt(valTok, "expected RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+		cond.Value = t
+	}
+
+	return cond, nil
+}
+
+func (p *filterParser) parseIDList() ([]int, error) {
+	if tok := p.next(); tok.kind != tokLParen {
+		return nil, p.errorAt(tok, `expected "(" to start id list`)
+	}
+
+	var ids []int
+	for {
+		tok := p.next()
+		id, err := strconv.Atoi(tok.text)
+		if tok.kind != tokWord || err != nil {
+			return nil, p.errorAt(tok, "expected integer id")
+		}
+		ids = append(ids, id)
+
+		sep := p.next()
+		if sep.kind == tokRParen {
+			return ids, nil
+		}
+		if sep.kind != tokComma {
+			return nil, p.errorAt(sep, `expected "," or ")"`)
+		}
+	}
+}
+
+// ParseTime reads an RFC 3339 timestamp or a bare YYYY-MM-DD date meaning
+// midnight UTC.
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func containsOp(ops []FilterOp, op FilterOp) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
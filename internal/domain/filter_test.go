@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseFilter_Match(t *testing.T) {
+	jan := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	todo := Todo{
+		ID:          3,
+		Title:       "Buy groceries",
+		Description: "Milk and eggs",
+		Completed:   false,
+		CreatedAt:   jan,
+		UpdatedAt:   jan.Add(48 * time.Hour),
+	}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`id = 3`, true},
+		{`id != 3`, false},
+		{`id in (1, 2, 3)`, true},
+		{`id IN (4,5)`, false},
+		{`id >= 3 and id < 4`, true},
+		{`title prefix "buy"`, true},
+		{`title PREFIX groceries`, false},
+		{`description contains EGGS`, true},
+		{`title = "Buy groceries"`, true},
+		{`title = "buy groceries"`, false},
+		{`completed = false`, true},
+		{`completed = TRUE`, false},
+		{`created_at >= 2024-01-01 AND created_at < 2024-02-01`, true},
+		{`created_at > "2024-01-15T12:00:00Z"`, false},
+		{`updated_at <= 2024-01-17T12:00:00+00:00`, true},
+		{`NOT completed = true`, true},
+		{`completed = true OR id = 3`, true},
+		{`completed = true OR id = 4 AND title prefix buy`, false},
+		{`(completed = true OR id = 3) AND NOT (title contains milk)`, true},
+		{`not not id = 3`, true},
+	}
+
+	for _, tc := range cases {
+		expr, err := ParseFilter(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) failed: %v", tc.expr, err)
+		}
+		if got := expr.Match(todo); got != tc.want {
+			t.Errorf("%q: expected %v, got %v", tc.expr, tc.want, got)
+		}
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	cases := []struct {
+		expr  string
+		pos   int
+		token string
+	}{
+		{`priority = 1`, 1, "priority"},
+		{`id = abc`, 6, "abc"},
+		{`title < "b"`, 7, "<"},
+		{`id = 1 AND`, 11, ""},
+		{`id = 1 id = 2`, 8, "id"},
+		{`(id = 1`, 8, ""},
+		{`completed = yes`, 13, "yes"},
+		{`created_at > 2024-13-01`, 14, "2024-13-01"},
+		{`title = "open`, 9, `"open`},
+		{`id in (1, x)`, 11, "x"},
+		{`id ! 1`, 4, "!"},
+		{`= 1`, 1, "="},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseFilter(tc.expr)
+
+		var syntaxErr *FilterSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("%q: expected FilterSyntaxError, got %v", tc.expr, err)
+		}
+		if !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%q: expected error to wrap ErrInvalidFilter", tc.expr)
+		}
+		if syntaxErr.Pos != tc.pos || syntaxErr.Token != tc.token {
+			t.Errorf("%q: expected error at %d near %q, got %d near %q (%v)", tc.expr, tc.pos, tc.token, syntaxErr.Pos, syntaxErr.Token, err)
+		}
+	}
+}
+
+func TestParseFilter_Limits(t *testing.T) {
+	deep := ""
+	for i := 0; i < maxFilterDepth+2; i++ {
+		deep += "NOT "
+	}
+	if _, err := ParseFilter(deep + "id = 1"); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected nesting limit error, got %v", err)
+	}
+
+	long := make([]byte, MaxFilterLength+1)
+	for i := range long {
+		long[i] = ' '
+	}
+	if _, err := ParseFilter(string(long)); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected length limit error, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("limit must be between 1 and 100")
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// TodoFilter narrows a listing; zero fields do not filter. Due bounds are
+// exclusive and skip todos without a due date. Overdue is judged at Now.
+// Tags match any of the names unless TagMatch is TagMatchAll. A ListID of 0
+// selects todos outside any list, a ParentID of 0 top-level todos.
+type TodoFilter struct {
+	Completed  *bool
+	Search     string
+	Expr       FilterExpr
+	Priorities []Priority
+	DueBefore  *time.Time
+	DueAfter   *time.Time
+	Overdue    *bool
+	Now        time.Time
+	Tags       []string
+	TagMatch   TagMatch
+	ListID     *int
+	ParentID   *int
+}
+
+// Match reports whether todo passes every field of the filter but Search,
+// which needs an index or the database.
+func (f TodoFilter) Match(todo Todo) bool {
+	if f.Completed != nil && todo.Completed != *f.Completed {
+		return false
+	}
+	if len(f.Priorities) > 0 && !containsPriority(f.Priorities, todo.Priority) {
+		return false
+	}
+	if f.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*f.DueBefore)) {
+		return false
+	}
+	if f.DueAfter != nil && (todo.DueAt == nil || !todo.DueAt.After(*f.DueAfter)) {
+		return false
+	}
+	if f.Overdue != nil && todo.IsOverdue(f.Now) != *f.Overdue {
+		return false
+	}
+	if len(f.Tags) > 0 && !todo.HasTags(f.Tags, f.TagMatch == TagMatchAll) {
+		return false
+	}
+	if f.ListID != nil && todo.ListID != *f.ListID {
+		return false
+	}
+	if f.ParentID != nil && todo.ParentID != *f.ParentID {
+		return false
+	}
+	if f.Expr != nil && !f.Expr.Match(todo) {
+		return false
+	}
+	return true
+}
+
+func containsPriority(ps []Priority, p Priority) bool {
+	for _, x := range ps {
+		if x == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Cursor marks the last todo of a page; the next page starts right after it.
+// It carries the sort it was made for and that todo's values for every key
+// of that sort, so paging keeps working when the todo itself is gone.
+type Cursor struct {
+	Sort      string     `json:"sort,omitempty"`
+	ID        int        `json:"id"`
+	Title     *string    `json:"title,omitempty"`
+	Completed *bool      `json:"completed,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Score     *float64   `json:"score,omitempty"`
+	Priority  *Priority  `json:"priority,omitempty"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	// NoDueAt marks a due_at sort value of "no due date", which sorts last.
+	NoDueAt bool `json:"no_due_at,omitempty"`
+}
+
+// ListQuery asks a repository for one page of todos. A nil Sort means
+// DefaultSort, a zero Limit means no limit and a nil After starts from the
+// beginning.
+type ListQuery struct {
+	Filter TodoFilter
+	Sort   []SortKey
+	Limit  int
+	After  *Cursor
+}
+
+// PageRequest is the paging part of a listing as sent by a client.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+type TodoPage struct {
+	Items      []Todo `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+func CursorFor(todo Todo, keys []SortKey) Cursor {
+	c := Cursor{Sort: FormatSort(keys), ID: todo.ID}
+	for _, k := range keys {
+		switch k.Field {
+		case SortByTitle:
+			c.Title = &todo.Title
+		case SortByCompleted:
+			c.Completed = &todo.Completed
+		case SortByCreatedAt:
+			c.CreatedAt = &todo.CreatedAt
+		case SortByUpdatedAt:
+			c.UpdatedAt = &todo.UpdatedAt
+		case SortByScore:
+			c.Score = &todo.Score
+		case SortByPriority:
+			c.Priority = &todo.Priority
+		case SortByDueAt:
+			c.DueAt = todo.DueAt
+			c.NoDueAt = todo.DueAt == nil
+		}
+	}
+	return c
+}
+
+// Matches reports whether the cursor was made for keys and carries a value
+// for each of them.
+func (c Cursor) Matches(keys []SortKey) bool {
+	if c.Sort != FormatSort(keys) {
+		return false
+	}
+	for _, k := range keys {
+		switch {
+		case k.Field == SortByTitle && c.Title == nil,
+			k.Field == SortByCompleted && c.Completed == nil,
+			k.Field == SortByCreatedAt && c.CreatedAt == nil,
+			k.Field == SortByUpdatedAt && c.UpdatedAt == nil,
+			k.Field == SortByScore && c.Score == nil,
+			k.Field == SortByPriority && c.Priority == nil,
+			k.Field == SortByDueAt && c.DueAt == nil && !c.NoDueAt:
+			return false
+		}
+	}
+	return true
+}
+
+// Todo returns a todo holding the cursor's sort values, to be compared with
+// CompareTodos.
+func (c Cursor) Todo() Todo {
+	todo := Todo{ID: c.ID}
+	if c.Title != nil {
+		todo.Title = *c.Title
+	}
+	if c.Completed != nil {
+		todo.Completed = *c.Completed
+	}
+	if c.CreatedAt != nil {
+		todo.CreatedAt = *c.CreatedAt
+	}
+	if c.UpdatedAt != nil {
+		todo.UpdatedAt = *c.UpdatedAt
+	}
+	if c.Score != nil {
+		todo.Score = *c.Score
+	}
+	if c.Priority != nil {
+		todo.Priority = *c.Priority
+	}
+	todo.DueAt = c.DueAt
+	return todo
+}
+
+// Encode returns the opaque token handed to clients.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
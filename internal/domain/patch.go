@@ -0,0 +1,21 @@
+package domain
+
+import "errors"
+
+var (
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrPatchTestFailed = errors.New("patch test failed")
+	ErrReadOnlyField   = errors.New("field is read-only")
+)
+
+// TodoPatch changes the JSON representation of a todo, as returned by the
+// API, and returns the changed one.
+type TodoPatch func(doc []byte) ([]byte, error)
+
+// ReadOnlyTodoFields are the members of a todo's JSON representation that
+// clients cannot write. Version is not among them: writing it states the
+// version a change is meant for.
+var ReadOnlyTodoFields = []string{
+	"id", "blocked_by", "blocked", "occurrence", "previous_occurrence_id", "next_occurrence_id",
+	"reminded_at", "created_at", "updated_at", "deleted_at", "score",
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidPriority = errors.New("priority must be one of none, low, medium, high, urgent")
+	ErrInvalidDueAt    = errors.New("due_at must be between 1970 and 9999")
+	ErrDueAtConflict   = errors.New("due_at and clear_due_at cannot be combined")
+)
+
+type Priority string
+
+const (
+	PriorityNone   Priority = "none"
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// Priorities lists every level from lowest to highest.
+var Priorities = []Priority{PriorityNone, PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// Rank orders priorities, none being 0. Unknown values rank as none.
+func (p Priority) Rank() int {
+	for i, level := range Priorities {
+		if p == level {
+			return i
+		}
+	}
+	return 0
+}
+
+func (p Priority) Valid() bool {
+	for _, level := range Priorities {
+		if p == level {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	minDueAt = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDueAt = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// ValidDueAt reports whether t can be stored by every repository.
+func ValidDueAt(t time.Time) bool {
+	return !t.Before(minDueAt) && t.Before(maxDueAt)
+}
+
+// IsOverdue reports whether an open todo is past its due date at now.
+func (t Todo) IsOverdue(now time.Time) bool {
+	return !t.Completed && t.DueAt != nil && t.DueAt.Before(now)
+}
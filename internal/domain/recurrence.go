@@ -0,0 +1,322 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRecurrence    = errors.New("invalid recurrence")
+	ErrRecurrenceNeedsDueAt = errors.New("a recurring todo needs a due_at")
+)
+
+type Frequency string
+
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+	FreqYearly  Frequency = "YEARLY"
+)
+
+// Recurrence is the subset of an RFC 5545 RRULE todos support: FREQ,
+// INTERVAL (up to MaxInterval), BYDAY without ordinals, BYMONTHDAY, COUNT
+// and UNTIL.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int // 1 to 31, or -1 to -31 counting from the month's end
+	Count      int   // total number of occurrences, 0 for no limit
+	Until      *time.Time
+}
+
+// MaxInterval is the largest INTERVAL a rule may have.
+const MaxInterval = 1000
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// ParseRecurrence parses an RRULE such as "FREQ=WEEKLY;BYDAY=MO,WE",
+// with or without the "RRULE:" prefix.
+func ParseRecurrence(s string) (*Recurrence, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+
+	r := &Recurrence{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalidRecurrence, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrInvalidRecurrence, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(value)
+			switch r.Freq {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && (r.Interval < 1 || r.Interval > MaxInterval) {
+				err = fmt.Errorf("INTERVAL must be 1 to %d", MaxInterval)
+			}
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					err = fmt.Errorf("unsupported BYDAY %q", code)
+					break
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				day, convErr := strconv.Atoi(v)
+				if convErr != nil || day == 0 || day < -31 || day > 31 {
+					err = fmt.Errorf("BYMONTHDAY %q must be 1 to 31 or -1 to -31", v)
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, day)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.New("COUNT must be positive")
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			r.Until = &until
+		default:
+			err = fmt.Errorf("unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+		}
+	}
+
+	switch {
+	case r.Freq == "":
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRecurrence)
+	case r.Count > 0 && r.Until != nil:
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalidRecurrence)
+	case r.Freq == FreqWeekly && len(r.ByMonthDay) > 0:
+		return nil, fmt.Errorf("%w: BYMONTHDAY cannot be used with WEEKLY", ErrInvalidRecurrence)
+	}
+
+	r.ByDay = uniqueWeekdays(r.ByDay)
+	r.ByMonthDay = uniqueInts(r.ByMonthDay)
+	return r, nil
+}
+
+// parseUntil reads UNTIL as a UTC date-time. A date alone includes the
+// whole day, so it stands for the last second of that day.
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL %q is not a date or UTC date-time", s)
+}
+
+// String returns the rule in a canonical form, which is what todos store.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			codes[i] = strings.ToUpper(day.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the occurrence after the one at prev, which is the
+// occurrence'th (counting from 1), keeping prev's time of day. It reports
+// false once COUNT or UNTIL is reached or no date before year 9999 matches.
+// Dates are judged in UTC.
+func (r Recurrence) Next(prev time.Time, occurrence int) (time.Time, bool) {
+	if r.Count > 0 && occurrence >= r.Count {
+		return time.Time{}, false
+	}
+
+	prev = prev.UTC()
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	// walk the periods the rule repeats in, and the days of each in order
+	for first := r.periodStart(prev); ; first = r.addPeriods(first, interval) {
+		day := first
+		if !day.After(prev) {
+			day = prev.AddDate(0, 0, 1)
+		}
+		for p := r.period(first); r.period(day) == p; day = day.AddDate(0, 0, 1) {
+			if !ValidDueAt(day) || (r.Until != nil && day.After(*r.Until)) {
+				return time.Time{}, false
+			}
+			if r.matches(day, prev) {
+				return day, true
+			}
+		}
+	}
+}
+
+// periodStart returns the first day of the period t falls in, at t's time
+// of day.
+func (r Recurrence) periodStart(t time.Time) time.Time {
+	switch r.Freq {
+	case FreqWeekly:
+		return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	case FreqMonthly:
+		return t.AddDate(0, 0, 1-t.Day())
+	case FreqYearly:
+		return t.AddDate(0, 0, 1-t.YearDay())
+	default:
+		return t
+	}
+}
+
+// addPeriods moves the first day of a period n periods on.
+func (r Recurrence) addPeriods(first time.Time, n int) time.Time {
+	switch r.Freq {
+	case FreqWeekly:
+		return first.AddDate(0, 0, 7*n)
+	case FreqMonthly:
+		return first.AddDate(0, n, 0)
+	case FreqYearly:
+		return first.AddDate(n, 0, 0)
+	default:
+		return first.AddDate(0, 0, n)
+	}
+}
+
+// period numbers the day, week (starting Monday), month or year t falls in.
+func (r Recurrence) period(t time.Time) int {
+	switch r.Freq {
+	case FreqWeekly:
+		// 1970-01-01 was a Thursday, shift so weeks start on Monday
+		return (daysSinceEpoch(t) + 3) / 7
+	case FreqMonthly:
+		return t.Year()*12 + int(t.Month())
+	case FreqYearly:
+		return t.Year()
+	default:
+		return daysSinceEpoch(t)
+	}
+}
+
+// matches reports whether day t is an occurrence within its period. Without
+// BYDAY and BYMONTHDAY the rule repeats the weekday, day of month or date of
+// the occurrence it started from.
+func (r Recurrence) matches(t, from time.Time) bool {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		switch r.Freq {
+		case FreqWeekly:
+			return t.Weekday() == from.Weekday()
+		case FreqMonthly:
+			return t.Day() == from.Day()
+		case FreqYearly:
+			return t.Month() == from.Month() && t.Day() == from.Day()
+		default:
+			return true
+		}
+	}
+
+	if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, t.Weekday()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(t) {
+		return false
+	}
+	return true
+}
+
+func (r Recurrence) matchesMonthDay(t time.Time) bool {
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || (day < 0 && last+day+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func daysSinceEpoch(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueWeekdays sorts days Monday first and drops duplicates.
+func uniqueWeekdays(days []time.Weekday) []time.Weekday {
+	monday := func(d time.Weekday) int { return (int(d) + 6) % 7 }
+	sort.Slice(days, func(i, j int) bool { return monday(days[i]) < monday(days[j]) })
+
+	var out []time.Weekday
+	for i, d := range days {
+		if i == 0 || d != days[i-1] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func uniqueInts(ns []int) []int {
+	sort.Ints(ns)
+	var out []int
+	for i, n := range ns {
+		if i == 0 || n != ns[i-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	for in, want := range map[string]string{
+		"FREQ=DAILY": "FREQ=DAILY",
+		"RRULE:freq=weekly;byday=we,mo,we;interval=2": "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+		"FREQ=MONTHLY;BYMONTHDAY=-1,15;COUNT=3":       "FREQ=MONTHLY;BYMONTHDAY=-1,15;COUNT=3",
+		"FREQ=YEARLY;INTERVAL=1;UNTIL=20301231":       "FREQ=YEARLY;UNTIL=20301231T235959Z",
+	} {
+		r, err := ParseRecurrence(in)
+		if err != nil {
+			t.Errorf("ParseRecurrence(%q) failed: %v", in, err)
+			continue
+		}
+		if got := r.String(); got != want {
+			t.Errorf("ParseRecurrence(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{
+		"", "DAILY", "FREQ=HOURLY", "FREQ=DAILY;FREQ=WEEKLY", "FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=999999999",
+		"FREQ=WEEKLY;BYDAY=1MO", "FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20300101", "FREQ=DAILY;UNTIL=tomorrow", "FREQ=DAILY;BYSETPOS=1",
+	} {
+		if _, err := ParseRecurrence(in); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("ParseRecurrence(%q): expected ErrInvalidRecurrence, got %v", in, err)
+		}
+	}
+}
+
+func TestRecurrence_Next(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02T15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	cases := []struct {
+		rule       string
+		prev       string
+		occurrence int
+		want       string // empty when the rule has run out
+	}{
+		{"FREQ=DAILY", "2025-01-31T09:00", 1, "2025-02-01T09:00"},
+		{"FREQ=DAILY;INTERVAL=3", "2025-01-31T09:00", 1, "2025-02-03T09:00"},
+		{"FREQ=WEEKLY", "2025-01-01T09:00", 1, "2025-01-08T09:00"},
+		// Wednesday to Friday in the same week, then Monday two weeks on
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR", "2025-01-01T09:00", 1, "2025-01-03T09:00"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR", "2025-01-03T09:00", 2, "2025-01-13T09:00"},
+		// months without a 31st are skipped
+		{"FREQ=MONTHLY", "2025-01-31T09:00", 1, "2025-03-31T09:00"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2025-01-31T09:00", 1, "2025-02-28T09:00"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15", "2025-01-15T09:00", 1, "2025-02-01T09:00"},
+		{"FREQ=MONTHLY;BYDAY=SA,SU", "2025-01-03T09:00", 1, "2025-01-04T09:00"},
+		{"FREQ=YEARLY", "2024-02-29T09:00", 1, "2028-02-29T09:00"},
+		{"FREQ=YEARLY;BYMONTHDAY=1", "2025-01-01T09:00", 1, "2025-02-01T09:00"},
+		{"FREQ=DAILY;COUNT=3", "2025-01-01T09:00", 2, "2025-01-02T09:00"},
+		{"FREQ=DAILY;COUNT=3", "2025-01-02T09:00", 3, ""},
+		{"FREQ=DAILY;UNTIL=20250102T090000Z", "2025-01-01T09:00", 1, "2025-01-02T09:00"},
+		{"FREQ=DAILY;UNTIL=20250102T090000Z", "2025-01-02T09:00", 2, ""},
+		// a date alone includes the whole day
+		{"FREQ=DAILY;UNTIL=20250103", "2025-01-02T09:00", 2, "2025-01-03T09:00"},
+		{"FREQ=DAILY;UNTIL=20250103", "2025-01-03T09:00", 3, ""},
+		{"FREQ=DAILY;INTERVAL=1000", "2025-01-01T09:00", 1, "2027-09-28T09:00"},
+		{"FREQ=YEARLY;INTERVAL=1000", "2025-01-01T09:00", 1, "3025-01-01T09:00"},
+		// never matches again, stops at UNTIL rather than year 9999
+		{"FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30;UNTIL=20300101", "2025-02-01T09:00", 1, ""},
+	}
+	for _, tc := range cases {
+		r, err := ParseRecurrence(tc.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) failed: %v", tc.rule, err)
+		}
+		got, ok := r.Next(date(tc.prev), tc.occurrence)
+		switch {
+		case tc.want == "" && ok:
+			t.Errorf("%s after %s: expected no occurrence, got %s", tc.rule, tc.prev, got)
+		case tc.want != "" && !got.Equal(date(tc.want)):
+			t.Errorf("%s after %s: expected %s, got %s (ok=%v)", tc.rule, tc.prev, tc.want, got, ok)
+		}
+	}
+}
+
+func BenchmarkRecurrence_Next(b *testing.B) {
+	rules := []struct{ name, rule string }{
+		{"LargeInterval", "FREQ=DAILY;INTERVAL=1000"},
+		// February never has a 30th, so this walks every period up to year 9999
+		{"NoMatch", "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30"},
+	}
+	prev := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	for _, rc := range rules {
+		r, err := ParseRecurrence(rc.rule)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(rc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r.Next(prev, 1)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidRemindAt  = errors.New("remind_at must be between 1970 and 9999")
+	ErrRemindAtConflict = errors.New("remind_at and clear_remind_at cannot be combined")
+	ErrReminderMoved    = errors.New("reminder moved")
+)
+
+// ReminderRepository records the reminders sent for todos.
+type ReminderRepository interface {
+	// MarkReminded sets RemindedAt to at without counting as a change to
+	// the todo, whose Version and UpdatedAt stay. It fails with
+	// ErrReminderMoved unless the todo's reminder is still set for remindAt.
+	MarkReminded(ctx context.Context, id int, remindAt, at time.Time) error
+}
+
+// ReminderPending reports whether the todo has a reminder that has not been
+// delivered yet. A reminder counts as delivered once RemindedAt is not
+// before RemindAt, so moving RemindAt later arms it again.
+func (t Todo) ReminderPending() bool {
+	if t.Completed || t.RemindAt == nil {
+		return false
+	}
+	return t.RemindedAt == nil || t.RemindedAt.Before(*t.RemindAt)
+}
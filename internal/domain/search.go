@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SearchTerm is one required part of a search query: a single word, a word
+// prefix (written "buy*") or a phrase of consecutive words ("\"buy milk\"").
+// Terms match the words of the title or the description, see Tokenize: a
+// single word matches inside a longer word too, a prefix only at the start of
+// a word and a phrase only whole words in a row, whatever punctuation is
+// between them.
+type SearchTerm struct {
+	Tokens []string
+	Prefix bool
+}
+
+func (t SearchTerm) IsPhrase() bool {
+	return len(t.Tokens) > 1
+}
+
+// Match reports whether the term appears in words, as returned by Tokenize.
+func (t SearchTerm) Match(words []string) bool {
+	if t.IsPhrase() {
+		for i := 0; i+len(t.Tokens) <= len(words); i++ {
+			if slices.Equal(words[i:i+len(t.Tokens)], t.Tokens) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, word := range words {
+		if t.Prefix && strings.HasPrefix(word, t.Tokens[0]) || !t.Prefix && strings.Contains(word, t.Tokens[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSearch reports whether every term appears in the title or the
+// description of todo.
+func MatchSearch(todo Todo, terms []SearchTerm) bool {
+	title, desc := Tokenize(todo.Title), Tokenize(todo.Description)
+	for _, term := range terms {
+		if !term.Match(title) && !term.Match(desc) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSearch splits a search string into terms that must all match. Words
+// are case-insensitive; punctuation separates words like whitespace does.
+func ParseSearch(s string) []SearchTerm {
+	var terms []SearchTerm
+
+	for len(s) > 0 {
+		start := strings.IndexByte(s, '"')
+		if start < 0 {
+			terms = append(terms, wordTerms(s)...)
+			break
+		}
+
+		terms = append(terms, wordTerms(s[:start])...)
+		rest := s[start+1:]
+
+		end := strings.IndexByte(rest, '"')
+		if end < 0 {
+			// an unbalanced quote is treated as plain text
+			terms = append(terms, wordTerms(rest)...)
+			break
+		}
+
+		if tokens := Tokenize(rest[:end]); len(tokens) > 0 {
+			terms = append(terms, SearchTerm{Tokens: tokens})
+		}
+		s = rest[end+1:]
+	}
+
+	return terms
+}
+
+func wordTerms(s string) []SearchTerm {
+	var terms []SearchTerm
+	for _, field := range strings.Fields(s) {
+		before := len(terms)
+		for _, tok := range Tokenize(field) {
+			terms = append(terms, SearchTerm{Tokens: []string{tok}})
+		}
+		if strings.HasSuffix(field, "*") && len(terms) > before {
+			terms[len(terms)-1].Prefix = true
+		}
+	}
+	return terms
+}
+
+// Tokenize lower-cases text and splits it into runs of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []SearchTerm
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"Buy MILK", []SearchTerm{{Tokens: []string{"buy"}}, {Tokens: []string{"milk"}}}},
+		{"mil*", []SearchTerm{{Tokens: []string{"mil"}, Prefix: true}}},
+		{`"buy fresh milk" today`, []SearchTerm{{Tokens: []string{"buy", "fresh", "milk"}}, {Tokens: []string{"today"}}}},
+		{`"milk"`, []SearchTerm{{Tokens: []string{"milk"}}}},
+		{`"buy milk`, []SearchTerm{{Tokens: []string{"buy"}}, {Tokens: []string{"milk"}}}},
+		{"e-mail*", []SearchTerm{{Tokens: []string{"e"}}, {Tokens: []string{"mail"}, Prefix: true}}},
+		{"milk %*", []SearchTerm{{Tokens: []string{"milk"}}}},
+	}
+
+	for _, tc := range cases {
+		if got := ParseSearch(tc.input); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseSearch(%q) = %+v, want %+v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Call Bob: 50% done, ÜBER-fast!")
+	want := []string{"call", "bob", "50", "done", "über", "fast"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestMatchSearch(t *testing.T) {
+	cases := []struct {
+		search, title, desc string
+		want                bool
+	}{
+		{"mil*", "Buy milk", "", true},
+		{"mil*", "Call family", "", false},
+		{"mil", "Call family", "", true},
+		{"MILK", "", "eggs, Milk", true},
+		{`"buy milk"`, "Buy, milk!", "", true},
+		{`"buy milk"`, "buy-milk", "", true},
+		{`"buy milk"`, "buy fresh milk", "", false},
+		{`"buy milk"`, "rebuy milkshake", "", false},
+		{`"buy milk"`, "Buy", "milk", false},
+		{`"don't forget"`, "Don't forget!", "", true},
+		{`"don't forget"`, "don't, forget", "", true},
+		{`"e-mail" bob*`, "E mail Bobby", "", true},
+		{"milk bread", "Buy milk", "", false},
+	}
+
+	for _, tc := range cases {
+		todo := Todo{Title: tc.title, Description: tc.desc}
+		if got := MatchSearch(todo, ParseSearch(tc.search)); got != tc.want {
+			t.Errorf("MatchSearch(%q) on %q / %q = %v, want %v", tc.search, tc.title, tc.desc, got, tc.want)
+		}
+	}
+}
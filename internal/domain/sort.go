@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+type SortField string
+
+const (
+	SortByID        SortField = "id"
+	SortByTitle     SortField = "title"
+	SortByCompleted SortField = "completed"
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByPriority  SortField = "priority"
+	SortByDueAt     SortField = "due_at"
+	// SortByScore orders by search relevance and needs a search term.
+	SortByScore SortField = "score"
+)
+
+var sortFields = map[SortField]bool{
+	SortByID:        true,
+	SortByTitle:     true,
+	SortByCompleted: true,
+	SortByCreatedAt: true,
+	SortByUpdatedAt: true,
+	SortByPriority:  true,
+	SortByDueAt:     true,
+	SortByScore:     true,
+}
+
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// DefaultSort orders todos by ID, oldest first.
+var DefaultSort = []SortKey{{Field: SortByID}}
+
+// DefaultSearchSort puts the most relevant todos first.
+const DefaultSearchSort = "-score"
+
+// ParseSort reads a comma separated list of fields, each optionally prefixed
+// with "-" for descending order, e.g. "-created_at,title". The result always
+// ends with an id key unless id was given explicitly, so the order is total.
+func ParseSort(spec string) ([]SortKey, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return DefaultSort, nil
+	}
+
+	var keys []SortKey
+	seen := make(map[SortField]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+
+		key := SortKey{}
+		if strings.HasPrefix(part, "-") {
+			key.Desc = true
+			part = part[1:]
+		}
+		key.Field = SortField(part)
+
+		if !sortFields[key.Field] {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, part)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidSort, part)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+
+	if !seen[SortByID] {
+		keys = append(keys, SortKey{Field: SortByID})
+	}
+	return keys, nil
+}
+
+// HasSortField reports whether keys order by field.
+func HasSortField(keys []SortKey, field SortField) bool {
+	for _, k := range keys {
+		if k.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = string(k.Field)
+		if k.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// CompareTodos orders a and b by keys, returning -1, 0 or 1. Titles compare
+// byte-wise so every repository can reproduce the same order. Priorities
+// compare by rank and a missing due date sorts after every due date.
+func CompareTodos(a, b Todo, keys []SortKey) int {
+	for _, k := range keys {
+		var c int
+		switch k.Field {
+		case SortByID:
+			c = compareInt(a.ID, b.ID)
+		case SortByTitle:
+			c = strings.Compare(a.Title, b.Title)
+		case SortByCompleted:
+			c = compareBool(a.Completed, b.Completed)
+		case SortByCreatedAt:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case SortByUpdatedAt:
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case SortByPriority:
+			c = compareInt(a.Priority.Rank(), b.Priority.Rank())
+		case SortByDueAt:
+			c = compareDueAt(a.DueAt, b.DueAt)
+		case SortByScore:
+			c = compareFloat(a.Score, b.Score)
+		}
+
+		if c != 0 {
+			if k.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareDueAt(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	default:
+		return a.Compare(*b)
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		spec string
+		want string
+	}{
+		{"", "id"},
+		{"title", "title,id"},
+		{"-created_at, title", "-created_at,title,id"},
+		{"-id", "-id"},
+		{"completed,-id,title", "completed,-id,title"},
+	}
+
+	for _, tc := range cases {
+		keys, err := ParseSort(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseSort(%q) failed: %v", tc.spec, err)
+		}
+		if got := FormatSort(keys); got != tc.want {
+			t.Errorf("ParseSort(%q) = %q, want %q", tc.spec, got, tc.want)
+		}
+	}
+
+	for _, spec := range []string{"size", "title,title", "-", "title,"} {
+		if _, err := ParseSort(spec); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("ParseSort(%q): expected ErrInvalidSort, got %v", spec, err)
+		}
+	}
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	keys, _ := ParseSort("-created_at,title")
+	todo := Todo{ID: 7, Title: "Task", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)}
+
+	c, err := DecodeCursor(CursorFor(todo, keys).Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if !c.Matches(keys) {
+		t.Error("expected cursor to match the sort it was made for")
+	}
+	if CompareTodos(c.Todo(), todo, keys) != 0 {
+		t.Errorf("expected cursor to carry the sort values, got %+v", c.Todo())
+	}
+
+	other, _ := ParseSort("title")
+	if c.Matches(other) {
+		t.Error("expected cursor not to match another sort")
+	}
+
+	if _, err := DecodeCursor("bm90IGpzb24"); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestCompareTodos_PriorityAndDueAt(t *testing.T) {
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	urgent := Todo{ID: 1, Priority: PriorityUrgent, DueAt: &due}
+	none := Todo{ID: 2, Priority: PriorityNone}
+
+	byPriority, _ := ParseSort("priority")
+	if CompareTodos(none, urgent, byPriority) >= 0 {
+		t.Error("expected none to rank below urgent")
+	}
+
+	byDue, _ := ParseSort("due_at")
+	if CompareTodos(urgent, none, byDue) >= 0 {
+		t.Error("expected a missing due date to sort last")
+	}
+
+	c, err := DecodeCursor(CursorFor(none, byDue).Encode())
+	if err != nil || !c.Matches(byDue) {
+		t.Fatalf("expected a cursor without due date to match, got %+v, %v", c, err)
+	}
+	if CompareTodos(c.Todo(), none, byDue) != 0 {
+		t.Errorf("expected cursor to keep the missing due date, got %+v", c.Todo())
+	}
+}
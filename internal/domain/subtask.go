@@ -0,0 +1,36 @@
+package domain
+
+import "errors"
+
+var (
+	ErrUnknownParent      = errors.New("unknown parent todo")
+	ErrParentCycle        = errors.New("a todo cannot be nested under itself or its subtasks")
+	ErrHasChildren        = errors.New("todo has subtasks")
+	ErrInvalidChildPolicy = errors.New("children must be block, cascade or orphan")
+)
+
+// ChildPolicy decides what deleting a todo does to its subtasks.
+type ChildPolicy string
+
+const (
+	// ChildrenBlock refuses to delete a todo that has subtasks.
+	ChildrenBlock ChildPolicy = "block"
+	// ChildrenCascade deletes the whole subtree.
+	ChildrenCascade ChildPolicy = "cascade"
+	// ChildrenOrphan moves the subtasks to the top level.
+	ChildrenOrphan ChildPolicy = "orphan"
+)
+
+func (p ChildPolicy) Valid() bool {
+	switch p {
+	case ChildrenBlock, ChildrenCascade, ChildrenOrphan:
+		return true
+	}
+	return false
+}
+
+// TodoTree is a todo with its subtasks, nested to any depth.
+type TodoTree struct {
+	Todo
+	Children []TodoTree `json:"children"`
+}
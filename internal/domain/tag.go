@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTagNotFound     = errors.New("tag not found")
+	ErrTagExists       = errors.New("tag already exists")
+	ErrUnknownTag      = errors.New("unknown tag")
+	ErrInvalidTagName  = errors.New("tag name must be 1-50 lowercase letters, digits, '-' or '_'")
+	ErrInvalidTagColor = errors.New("tag color must be a #rrggbb hex color")
+	ErrInvalidTagMatch = errors.New("tag_match must be any or all")
+)
+
+const MaxTagNameLength = 50
+
+// Tag is a label todos refer to by name. Renaming a tag renames it on every
+// todo; deleting it detaches it from them.
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateTagInput struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type UpdateTagInput struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
+// TagRepository is implemented by the same stores as TodoRepository, which
+// keep the todo side of the association in step.
+type TagRepository interface {
+	CreateTag(ctx context.Context, input CreateTagInput) (*Tag, error)
+	GetTag(ctx context.Context, id int) (*Tag, error)
+	ListTags(ctx context.Context) ([]Tag, error)
+	UpdateTag(ctx context.Context, id int, input UpdateTagInput) (*Tag, error)
+	DeleteTag(ctx context.Context, id int) error
+}
+
+// TagMatch says whether a todo needs any or all of the tags filtered by.
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "any"
+	TagMatchAll TagMatch = "all"
+)
+
+// NormalizeTagName lower-cases and trims name and checks what is left.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || len(name) > MaxTagNameLength {
+		return "", ErrInvalidTagName
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return "", ErrInvalidTagName
+		}
+	}
+	return name, nil
+}
+
+// NormalizeTagNames normalizes every name and returns them sorted without
+// duplicates, the order todos carry their tags in.
+func NormalizeTagNames(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		n, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, name)
+		}
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// NormalizeTagColor lower-cases a #rrggbb color. An empty color stays empty.
+func NormalizeTagColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if color == "" {
+		return "", nil
+	}
+	if len(color) != 7 || color[0] != '#' {
+		return "", ErrInvalidTagColor
+	}
+	for _, r := range color[1:] {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return "", ErrInvalidTagColor
+		}
+	}
+	return color, nil
+}
+
+// HasTags reports whether todo carries any (or, with all, every) of names.
+func (t Todo) HasTags(names []string, all bool) bool {
+	for _, name := range names {
+		found := false
+		for _, tag := range t.Tags {
+			if tag == name {
+				found = true
+				break
+			}
+		}
+		if found && !all {
+			return true
+		}
+		if !found && all {
+			return false
+		}
+	}
+	return all
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTagNames(t *testing.T) {
+	got, err := NormalizeTagNames([]string{" Work", "urgent", "work", "to-do_2"})
+	if err != nil {
+		t.Fatalf("NormalizeTagNames failed: %v", err)
+	}
+	if want := []string{"to-do_2", "urgent", "work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTagNames = %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"", "  ", "a,b", "two words", "émoji", strings.Repeat("x", MaxTagNameLength+1)} {
+		if _, err := NormalizeTagNames([]string{name}); !errors.Is(err, ErrInvalidTagName) {
+			t.Errorf("NormalizeTagNames(%q): expected ErrInvalidTagName, got %v", name, err)
+		}
+	}
+}
+
+func TestNormalizeTagColor(t *testing.T) {
+	for in, want := range map[string]string{"": "", "#A0b1C2": "#a0b1c2"} {
+		if got, err := NormalizeTagColor(in); err != nil || got != want {
+			t.Errorf("NormalizeTagColor(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"red", "#fff", "#gggggg", "a0b1c2f"} {
+		if _, err := NormalizeTagColor(in); !errors.Is(err, ErrInvalidTagColor) {
+			t.Errorf("NormalizeTagColor(%q): expected ErrInvalidTagColor, got %v", in, err)
+		}
+	}
+}
+
+func TestTodo_HasTags(t *testing.T) {
+	todo := Todo{Tags: []string{"home", "work"}}
+
+	cases := []struct {
+		names []string
+		all   bool
+		want  bool
+	}{
+		{[]string{"work", "urgent"}, false, true},
+		{[]string{"work", "urgent"}, true, false},
+		{[]string{"work", "home"}, true, true},
+		{[]string{"urgent"}, false, false},
+	}
+	for _, tc := range cases {
+		if got := todo.HasTags(tc.names, tc.all); got != tc.want {
+			t.Errorf("HasTags(%v, all=%v) = %v, want %v", tc.names, tc.all, got, tc.want)
+		}
+	}
+}
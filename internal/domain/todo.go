@@ -13,6 +13,8 @@ var (
 	ErrDescriptionTooLong = errors.New("description is too long(max 1_000)")
 	ErrInvalidID          = errors.New("invalid id")
 	ErrInvalidPath        = errors.New("invalid path")
+	ErrVersionMismatch    = errors.New("todo has been changed since the given version")
+	ErrInvalidVersion     = errors.New("version must be positive")
 )
 
 const (
@@ -21,24 +23,161 @@ const (
 )
 
 type Todo struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	Priority    Priority   `json:"priority"`
+	DueAt       *time.Time `json:"due_at"`
+	Tags        []string   `json:"tags,omitempty"`
+	ListID      int        `json:"list_id,omitempty"`
+	ParentID    int        `json:"parent_id,omitempty"`
+	// AutoComplete completes the todo once all of its subtasks are completed.
+	AutoComplete bool `json:"auto_complete,omitempty"`
+	// BlockedBy lists the todos this one depends on; Blocked reports whether
+	// any of them is still open. Both come from the dependency graph.
+	BlockedBy []int `json:"blocked_by,omitempty"`
+	Blocked   bool  `json:"blocked"`
+	// Recurrence is a canonical RRULE. Completing a recurring todo creates
+	// its next occurrence; Occurrence counts them from 1 and the occurrence
+	// ids link each one to its neighbours.
+	Recurrence           string `json:"recurrence,omitempty"`
+	Occurrence           int    `json:"occurrence,omitempty"`
+	PreviousOccurrenceID int    `json:"previous_occurrence_id,omitempty"`
+	NextOccurrenceID     int    `json:"next_occurrence_id,omitempty"`
+	// RemindAt is when to send a reminder; RemindedAt is when one was last
+	// delivered.
+	RemindAt   *time.Time `json:"remind_at,omitempty"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+	// Version starts at 1 and goes up with every update of the todo.
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set while the todo is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Score is the search relevance of a listed todo; it is never stored.
+	Score float64 `json:"score,omitempty"`
 }
 
 type CreateTodoInput struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	Priority    Priority   `json:"priority,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	ListID      int        `json:"list_id,omitempty"`
+	ParentID    int        `json:"parent_id,omitempty"`
+
+	AutoComplete bool       `json:"auto_complete,omitempty"`
+	Recurrence   string     `json:"recurrence,omitempty"`
+	RemindAt     *time.Time `json:"remind_at,omitempty"`
+
+	// set by the service when it creates the next occurrence
+	Occurrence           int `json:"-"`
+	PreviousOccurrenceID int `json:"-"`
 }
 
+// UpdateTodoInput changes the non-nil fields. ClearDueAt removes the due date
+// and a non-nil Tags replaces the todo's tags. ListID moves the todo to
+// another list, 0 taking it out of its list; ParentID likewise moves it under
+// another todo or, with 0, to the top level, and fails with ErrParentCycle
+// under the todo itself or one of its subtasks. An empty Recurrence stops the
+// todo from recurring. ClearRemindAt removes the reminder. A non-nil Version
+// makes the update fail with ErrVersionMismatch unless the todo is still at
+// that version.
 type UpdateTodoInput struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Completed   *bool   `json:"completed,omitempty"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	Priority    *Priority  `json:"priority,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	ClearDueAt  bool       `json:"clear_due_at,omitempty"`
+	Tags        *[]string  `json:"tags,omitempty"`
+	ListID      *int       `json:"list_id,omitempty"`
+	ParentID    *int       `json:"parent_id,omitempty"`
+
+	AutoComplete *bool   `json:"auto_complete,omitempty"`
+	Recurrence   *string `json:"recurrence,omitempty"`
+
+	RemindAt      *time.Time `json:"remind_at,omitempty"`
+	ClearRemindAt bool       `json:"clear_remind_at,omitempty"`
+
+	Version *int `json:"version,omitempty"`
+
+	// set by the service when it links the next occurrence
+	Occurrence       *int `json:"-"`
+	NextOccurrenceID *int `json:"-"`
+	// set by the reminder scheduler once a reminder is delivered
+	RemindedAt *time.Time `json:"-"`
+}
+
+// ReplaceTodoInput holds every field a client can write. Replacing a todo
+// sets all of them, so fields left out take their zero value: no due date,
+// no tags, no list and so on. An empty Priority means PriorityNone.
+type ReplaceTodoInput struct {
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Completed    bool       `json:"completed"`
+	Priority     Priority   `json:"priority"`
+	DueAt        *time.Time `json:"due_at"`
+	Tags         []string   `json:"tags"`
+	ListID       int        `json:"list_id"`
+	ParentID     int        `json:"parent_id"`
+	AutoComplete bool       `json:"auto_complete"`
+	Recurrence   string     `json:"recurrence"`
+	RemindAt     *time.Time `json:"remind_at"`
+
+	Version *int `json:"version,omitempty"`
+}
+
+// ReplaceInput returns the writable fields of t.
+func (t Todo) ReplaceInput() ReplaceTodoInput {
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return ReplaceTodoInput{
+		Title:        t.Title,
+		Description:  t.Description,
+		Completed:    t.Completed,
+		Priority:     t.Priority,
+		DueAt:        t.DueAt,
+		Tags:         tags,
+		ListID:       t.ListID,
+		ParentID:     t.ParentID,
+		AutoComplete: t.AutoComplete,
+		Recurrence:   t.Recurrence,
+		RemindAt:     t.RemindAt,
+	}
+}
+
+// UpdateInput returns the update that sets every field to in's.
+func (in ReplaceTodoInput) UpdateInput() UpdateTodoInput {
+	priority := in.Priority
+	if priority == "" {
+		priority = PriorityNone
+	}
+	tags := in.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return UpdateTodoInput{
+		Title:         &in.Title,
+		Description:   &in.Description,
+		Completed:     &in.Completed,
+		Priority:      &priority,
+		DueAt:         in.DueAt,
+		ClearDueAt:    in.DueAt == nil,
+		Tags:          &tags,
+		ListID:        &in.ListID,
+		ParentID:      &in.ParentID,
+		AutoComplete:  &in.AutoComplete,
+		Recurrence:    &in.Recurrence,
+		RemindAt:      in.RemindAt,
+		ClearRemindAt: in.RemindAt == nil,
+		Version:       in.Version,
+	}
 }
 
 type TodoRepository interface {
@@ -47,5 +186,9 @@ type TodoRepository interface {
 	GetAll(ctx context.Context) ([]Todo, error)
 	Update(ctx context.Context, id int, input UpdateTodoInput) (*Todo, error)
 	Delete(ctx context.Context, id int) error
+	// DeleteVersion deletes the todo like Delete, but fails with
+	// ErrVersionMismatch unless it is at version.
+	DeleteVersion(ctx context.Context, id, version int) error
 	GetFiltered(ctx context.Context, completed *bool, search string) ([]Todo, error)
+	List(ctx context.Context, query ListQuery) (*TodoPage, error)
 }
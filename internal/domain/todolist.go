@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrListNotFound     = errors.New("list not found")
+	ErrUnknownList      = errors.New("unknown list")
+	ErrListNameRequired = errors.New("list name required")
+	ErrListNameTooLong  = errors.New("list name is too long(max 100)")
+	ErrListNotEmpty     = errors.New("list still contains todos")
+)
+
+const MaxListNameLength = 100
+
+// TodoList groups todos into a project. A todo belongs to at most one list;
+// ListID 0 means it belongs to none. The counts are computed when read.
+type TodoList struct {
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	OpenCount      int       `json:"open_count"`
+	CompletedCount int       `json:"completed_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type CreateListInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type UpdateListInput struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// ListRepository is implemented by the same stores as TodoRepository.
+// DeleteList fails with ErrListNotEmpty while the list has todos, unless
+// cascade is set, in which case they move to the trash. Todos in the trash
+// lose the list, so they are restored without one.
+type ListRepository interface {
+	CreateList(ctx context.Context, input CreateListInput) (*TodoList, error)
+	GetList(ctx context.Context, id int) (*TodoList, error)
+	GetLists(ctx context.Context) ([]TodoList, error)
+	UpdateList(ctx context.Context, id int, input UpdateListInput) (*TodoList, error)
+	DeleteList(ctx context.Context, id int, cascade bool) error
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrParentDeleted = errors.New("parent todo is in the trash, restore it first")
+
+// TrashRepository is implemented by repositories whose Delete moves a todo
+// to the trash rather than removing it. A trashed todo is left out of every
+// other query, as if it were gone, and loses its dependencies.
+type TrashRepository interface {
+	// ListTrash returns the trashed todos, most recently deleted first.
+	ListTrash(ctx context.Context) ([]Todo, error)
+	// RestoreDeleted takes the todo out of the trash. It fails with
+	// ErrTodoNotFound unless the todo is trashed, and with ErrParentDeleted
+	// while its parent is.
+	RestoreDeleted(ctx context.Context, id int) (*Todo, error)
+	// PurgeTrash removes the todos trashed before before for good and
+	// reports how many there were.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+}
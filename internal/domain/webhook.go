@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound       = errors.New("webhook not found")
+	ErrInvalidWebhookURL     = errors.New("webhook url must be an absolute http or https url")
+	ErrWebhookURLNotPublic   = errors.New("webhook url must point to a public address")
+	ErrWebhookSecret         = errors.New("webhook secret must be 16 to 256 characters")
+	ErrInvalidEventType      = errors.New("unknown event type")
+	ErrInvalidDeliveryStatus = errors.New("status must be pending, succeeded, failed or dead")
+)
+
+const (
+	MinWebhookSecretLength = 16
+	MaxWebhookSecretLength = 256
+)
+
+// Webhook subscribes a URL to todo events. Every delivery is signed with
+// Secret, which is write-only: the API never returns it.
+type Webhook struct {
+	ID     int    `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events limits the webhook to those event types, all of them when empty.
+	Events    []EventType `json:"events"`
+	Active    bool        `json:"active"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// nonPublicPrefixes are the ranges that are not reachable from the internet
+// and that the netip.Addr methods do not cover.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+}
+
+// PublicAddr reports whether webhooks may be delivered to addr. Loopback,
+// private, link-local (where cloud metadata services live), multicast and
+// unspecified addresses are refused.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Wants reports whether the webhook takes events of type t.
+func (w Webhook) Wants(t EventType) bool {
+	if !w.Active {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookInput creates an active webhook unless Active is false.
+type CreateWebhookInput struct {
+	URL    string      `json:"url"`
+	Secret string      `json:"secret"`
+	Events []EventType `json:"events,omitempty"`
+	Active *bool       `json:"active,omitempty"`
+}
+
+type UpdateWebhookInput struct {
+	URL    *string      `json:"url,omitempty"`
+	Secret *string      `json:"secret,omitempty"`
+	Events *[]EventType `json:"events,omitempty"`
+	Active *bool        `json:"active,omitempty"`
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, input CreateWebhookInput) (*Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, id int, input UpdateWebhookInput) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed is waiting for a retry; DeliveryDead ran out of them.
+	DeliveryFailed DeliveryStatus = "failed"
+	DeliveryDead   DeliveryStatus = "dead"
+)
+
+func (s DeliveryStatus) Valid() bool {
+	switch s {
+	case DeliveryPending, DeliverySucceeded, DeliveryFailed, DeliveryDead:
+		return true
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent, or being sent, to one webhook.
+type WebhookDelivery struct {
+	ID            string         `json:"id"`
+	WebhookID     int            `json:"webhook_id"`
+	EventID       string         `json:"event_id"`
+	Event         EventType      `json:"event"`
+	TodoID        int            `json:"todo_id"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	StatusCode    int            `json:"status_code,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	LastAttemptAt *time.Time     `json:"last_attempt_at,omitempty"`
+	NextAttemptAt *time.Time     `json:"next_attempt_at,omitempty"`
+}
@@ -0,0 +1,183 @@
+// Package eventbus is an in-process publish/subscribe bus for todo events.
+// TodoService publishes to it like to any other event sink, and the rest of
+// the server subscribes to it.
+package eventbus
+
+import (
+	"context"
+	"io"
+	"runtime/debug"
+	"sync"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+// Handler handles one event. A panic in a handler is recovered and logged,
+// so it neither reaches the publisher nor stops later events.
+type Handler func(event domain.TodoEvent)
+
+type Options struct {
+	// Shards is how many goroutines an asynchronous subscriber handles
+	// events on. Events of one todo always go to the same one.
+	Shards int
+	// QueueSize bounds the events waiting for a single shard; events for a
+	// full shard are dropped and logged rather than holding up publishers.
+	QueueSize int
+
+	Logger *logger.Logger
+}
+
+// Bus implements service.EventSink. Synchronous subscribers run on the
+// publisher's goroutine before Publish returns, in the order they
+// subscribed, so they should be quick; they may publish events themselves.
+// Asynchronous subscribers run on their own goroutines and receive the
+// events of each todo one at a time, in the order they were published.
+type Bus struct {
+	opts Options
+	log  *logger.Logger
+
+	mu     sync.RWMutex
+	subs   []*subscriber
+	closed bool
+
+	// shards serialize handing an event of a todo to every asynchronous
+	// subscriber, so they all see the same order.
+	shards []sync.Mutex
+	wg     sync.WaitGroup
+}
+
+type subscriber struct {
+	name   string
+	handle Handler
+	queues []chan domain.TodoEvent // nil when synchronous
+}
+
+func NewBus(opts Options) *Bus {
+	if opts.Shards <= 0 {
+		opts.Shards = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	log := opts.Logger
+	if log == nil {
+		log = logger.New("error", io.Discard, "text")
+	}
+	return &Bus{opts: opts, log: log, shards: make([]sync.Mutex, opts.Shards)}
+}
+
+// Subscribe adds a synchronous subscriber; name identifies it in logs.
+func (b *Bus) Subscribe(name string, handle Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, &subscriber{name: name, handle: handle})
+}
+
+// SubscribeAsync adds an asynchronous subscriber.
+func (b *Bus) SubscribeAsync(name string, handle Handler) {
+	sub := &subscriber{name: name, handle: handle, queues: make([]chan domain.TodoEvent, b.opts.Shards)}
+	for i := range sub.queues {
+		queue := make(chan domain.TodoEvent, b.opts.QueueSize)
+		sub.queues[i] = queue
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for event := range queue {
+				b.deliver(sub, event)
+			}
+		}()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		for _, queue := range sub.queues {
+			close(queue)
+		}
+		return
+	}
+	b.subs = append(b.subs, sub)
+}
+
+// Publish hands event to every subscriber. Events published after Close
+// are dropped.
+func (b *Bus) Publish(event domain.TodoEvent) {
+	subs, ok := b.enqueue(event)
+	if !ok {
+		return
+	}
+
+	// not holding b.mu, so handlers can publish
+	for _, sub := range subs {
+		if sub.queues == nil {
+			b.deliver(sub, event)
+		}
+	}
+}
+
+// enqueue queues event for the asynchronous subscribers and returns all of
+// them, or false once the bus is closed.
+func (b *Bus) enqueue(event domain.TodoEvent) ([]*subscriber, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return nil, false
+	}
+
+	shard := event.Todo.ID % len(b.shards)
+	b.shards[shard].Lock()
+	defer b.shards[shard].Unlock()
+	for _, sub := range b.subs {
+		if sub.queues == nil {
+			continue
+		}
+		select {
+		case sub.queues[shard] <- event:
+		default:
+			b.log.Error("event dropped, subscriber is too slow",
+				"subscriber", sub.name, "type", event.Type, "todo_id", event.Todo.ID)
+		}
+	}
+	return b.subs, true
+}
+
+func (b *Bus) deliver(sub *subscriber, event domain.TodoEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.Error("event subscriber panicked",
+				"subscriber", sub.name, "type", event.Type, "todo_id", event.Todo.ID,
+				"panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	sub.handle(event)
+}
+
+// Close stops accepting events and waits until the asynchronous subscribers
+// have handled the ones already published, or ctx is done.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, sub := range b.subs {
+			for _, queue := range sub.queues {
+				close(queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
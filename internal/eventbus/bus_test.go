@@ -0,0 +1,144 @@
+package eventbus
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// event numbers its events through Occurrence, which is otherwise unused
+// here.
+func event(todoID, n int) domain.TodoEvent {
+	return domain.TodoEvent{Type: domain.EventTodoUpdated, Todo: domain.Todo{ID: todoID, Occurrence: n}}
+}
+
+func TestBus_SyncSubscribersRunBeforePublishReturns(t *testing.T) {
+	b := NewBus(Options{})
+	var got []string
+	b.Subscribe("first", func(e domain.TodoEvent) {
+		got = append(got, "first")
+		if e.Todo.ID == 1 {
+			// publishing from a handler must not deadlock
+			b.Publish(event(2, 1))
+		}
+	})
+	b.Subscribe("second", func(domain.TodoEvent) { got = append(got, "second") })
+
+	b.Publish(event(1, 1))
+	want := []string{"first", "first", "second", "second"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestBus_AsyncKeepsOrderPerTodo(t *testing.T) {
+	b := NewBus(Options{Shards: 3, QueueSize: 1000})
+
+	var mu sync.Mutex
+	seen := map[int][]int{}
+	record := func(e domain.TodoEvent) {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[e.Todo.ID] = append(seen[e.Todo.ID], e.Todo.Occurrence)
+	}
+	b.SubscribeAsync("a", record)
+	b.SubscribeAsync("b", func(e domain.TodoEvent) { record(domain.TodoEvent{Todo: domain.Todo{ID: -e.Todo.ID, Occurrence: e.Todo.Occurrence}}) })
+
+	// each todo's events come from its own goroutine, so publish order is
+	// known per todo
+	var wg sync.WaitGroup
+	for id := 1; id <= 10; id++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 1; n <= 50; n++ {
+				b.Publish(event(id, n))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(seen) != 20 {
+		t.Fatalf("expected events of 10 todos for both subscribers, got %d", len(seen))
+	}
+	for id, ns := range seen {
+		if len(ns) != 50 {
+			t.Fatalf("todo %d: expected 50 events, got %d", id, len(ns))
+		}
+		for i, n := range ns {
+			if n != i+1 {
+				t.Fatalf("todo %d: events out of order: %v", id, ns)
+			}
+		}
+	}
+}
+
+func TestBus_IsolatesPanics(t *testing.T) {
+	b := NewBus(Options{})
+
+	var syncGot, asyncGot []int
+	var mu sync.Mutex
+	b.Subscribe("bad", func(e domain.TodoEvent) { panic("boom") })
+	b.Subscribe("good", func(e domain.TodoEvent) { syncGot = append(syncGot, e.Todo.ID) })
+	b.SubscribeAsync("bad async", func(e domain.TodoEvent) {
+		if e.Todo.ID == 1 {
+			panic("boom")
+		}
+		mu.Lock()
+		asyncGot = append(asyncGot, e.Todo.ID)
+		mu.Unlock()
+	})
+
+	b.Publish(event(1, 1))
+	b.Publish(event(2, 1))
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(syncGot) != 2 {
+		t.Errorf("expected the good subscriber to get both events, got %v", syncGot)
+	}
+	if len(asyncGot) != 1 || asyncGot[0] != 2 {
+		t.Errorf("expected the async subscriber to go on after a panic, got %v", asyncGot)
+	}
+}
+
+func TestBus_DropsWhenFullAndAfterClose(t *testing.T) {
+	b := NewBus(Options{Shards: 1, QueueSize: 1})
+	release := make(chan struct{})
+	var got []int
+	b.SubscribeAsync("slow", func(e domain.TodoEvent) {
+		<-release
+		got = append(got, e.Todo.ID)
+	})
+
+	// the first is being handled, the second waits and the third is dropped
+	b.Publish(event(1, 1))
+	time.Sleep(10 * time.Millisecond)
+	b.Publish(event(2, 1))
+	b.Publish(event(3, 1))
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	b.Publish(event(4, 1))
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("expected events 1 and 2, got %v", got)
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/jsonpatch"
+)
+
+// batchRequest is the body of POST /todos:batch. The todo of a create is
+// the body of POST /todos, that of an update a merge patch as PATCH takes.
+type batchRequest struct {
+	Atomic     bool `json:"atomic"`
+	Operations []struct {
+		Op       domain.BatchOpType `json:"op"`
+		ID       int                `json:"id"`
+		Todo     json.RawMessage    `json:"todo"`
+		Version  int                `json:"version"`
+		Children domain.ChildPolicy `json:"children"`
+	} `json:"operations"`
+}
+
+type batchResult struct {
+	Status int          `json:"status"`
+	ID     int          `json:"id,omitempty"`
+	Todo   *domain.Todo `json:"todo,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// batchErrorResponse tells which operation made an atomic batch fail.
+type batchErrorResponse struct {
+	Error string `json:"error"`
+	Index int    `json:"index"`
+	ID    int    `json:"id,omitempty"`
+}
+
+func (h *TodoHandler) batchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if key := r.Header.Get("Idempotency-Key"); key != "" && h.idempotency != nil {
+		h.idempotent(ctx, w, r, key, func(w http.ResponseWriter, r *http.Request) {
+			h.batch(ctx, w, r)
+		})
+		return
+	}
+	h.batch(ctx, w, r)
+}
+
+func (h *TodoHandler) batch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	ops := make([]domain.BatchOp, len(req.Operations))
+	for i, o := range req.Operations {
+		op := domain.BatchOp{Op: o.Op, ID: o.ID, Version: o.Version, Children: o.Children}
+		var err error
+		switch o.Op {
+		case domain.BatchCreate:
+			if o.ID != 0 {
+				err = errors.New("create takes no id")
+				break
+			}
+			dec := json.NewDecoder(bytes.NewReader(o.Todo))
+			dec.DisallowUnknownFields()
+			if err = dec.Decode(&op.Input); err != nil {
+				err = fmt.Errorf("invalid todo: %v", err)
+			}
+		case domain.BatchUpdate:
+			patch := o.Todo
+			if len(patch) == 0 || !json.Valid(patch) {
+				err = errors.New("update needs a merge patch as todo")
+				break
+			}
+			op.Patch = func(doc []byte) ([]byte, error) {
+				return jsonpatch.MergePatch(doc, patch)
+			}
+		case domain.BatchDelete:
+			if len(o.Todo) != 0 {
+				err = errors.New("delete takes no todo")
+			}
+		default:
+			err = fmt.Errorf("unknown op %q", o.Op)
+		}
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("operation %d: %v", i, err))
+			return
+		}
+		ops[i] = op
+	}
+
+	results, err := h.service.Batch(ctx, ops, req.Atomic)
+	if err != nil {
+		h.handleBatchError(w, err)
+		return
+	}
+
+	success := make([]int, len(ops))
+	for i, op := range ops {
+		switch op.Op {
+		case domain.BatchCreate:
+			success[i] = http.StatusCreated
+		case domain.BatchDelete:
+			success[i] = http.StatusNoContent
+		default:
+			success[i] = http.StatusOK
+		}
+	}
+	h.respondBatch(w, results, func(i int) int { return success[i] })
+}
+
+// completeHandler serves POST /todos:complete, which completes the open
+// todos matching the filter parameters of GET /todos.
+func (h *TodoHandler) completeHandler(w http.ResponseWriter, r *http.Request) {
+	h.filteredBatch(w, r, func(ctx context.Context, filter domain.TodoFilter, atomic bool) ([]domain.BatchResult, error) {
+		return h.service.CompleteMatching(ctx, filter, atomic)
+	}, http.StatusOK)
+}
+
+// deleteCompletedHandler serves POST /todos:delete-completed, which deletes
+// the completed todos matching the filter parameters of GET /todos.
+func (h *TodoHandler) deleteCompletedHandler(w http.ResponseWriter, r *http.Request) {
+	children := domain.ChildPolicy(r.URL.Query().Get("children"))
+	h.filteredBatch(w, r, func(ctx context.Context, filter domain.TodoFilter, atomic bool) ([]domain.BatchResult, error) {
+		return h.service.DeleteCompleted(ctx, filter, children, atomic)
+	}, http.StatusNoContent)
+}
+
+// filteredBatch runs a batch over the todos matching the query parameters,
+// atomically when atomic=true.
+func (h *TodoHandler) filteredBatch(w http.ResponseWriter, r *http.Request, run func(ctx context.Context, filter domain.TodoFilter, atomic bool) ([]domain.BatchResult, error), success int) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	if r.Method != http.MethodPost {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parseTodoFilter(query, domain.TodoFilter{})
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := run(ctx, filter, query.Get("atomic") == "true")
+	if err != nil {
+		h.handleBatchError(w, err)
+		return
+	}
+	h.respondBatch(w, results, func(int) int { return success })
+}
+
+// respondBatch reports the result of every operation, with the status it
+// would have had on its own; success gives that of operation i when it
+// succeeded.
+func (h *TodoHandler) respondBatch(w http.ResponseWriter, results []domain.BatchResult, success func(i int) int) {
+	resp := batchResponse{Results: make([]batchResult, len(results))}
+	for i, res := range results {
+		item := batchResult{Status: success(i), ID: res.ID, Todo: res.Todo}
+		if res.Err != nil {
+			item.Status, item.Error = todoErrorStatus(res.Err)
+			item.Todo = nil
+			if item.Status == http.StatusInternalServerError {
+				h.log.Error("batch operation failed", "error", res.Err, "index", i)
+			}
+		}
+		resp.Results[i] = item
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// handleBatchError responds to a failed batch; when an operation failed an
+// atomic batch the response tells which, with the status it got.
+func (h *TodoHandler) handleBatchError(w http.ResponseWriter, err error) {
+	var batchErr *domain.BatchError
+	if !errors.As(err, &batchErr) {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	status, msg := todoErrorStatus(batchErr.Err)
+	if status == http.StatusInternalServerError {
+		h.log.Error("batch operation failed", "error", batchErr.Err, "index", batchErr.Index)
+	}
+	h.respondJSON(w, status, batchErrorResponse{
+		Error: fmt.Sprintf("operation %d: %s", batchErr.Index, msg),
+		Index: batchErr.Index,
+		ID:    batchErr.ID,
+	})
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// todoETag is the entity tag of a todo's representation. Besides the
+// version it hashes the JSON, since some fields change without an update of
+// the todo itself: blocked when a blocker is completed, tags when one is
+// renamed.
+func todoETag(todo *domain.Todo) string {
+	data, _ := json.Marshal(todo)
+	sum := sha256.Sum256(data)
+	return `"` + strconv.Itoa(todo.Version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// matchETag reports whether the If-Match or If-None-Match header lists tag
+// or is "*". If-None-Match compares weakly, ignoring W/ prefixes.
+func matchETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
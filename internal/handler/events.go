@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/stream"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+type EventStream interface {
+	Subscribe(lastEventID string) *stream.Subscription
+}
+
+// EventsHandler streams todo events as Server-Sent Events.
+type EventsHandler struct {
+	base
+	stream    EventStream
+	heartbeat time.Duration
+}
+
+func NewEventsHandler(stream EventStream, log *logger.Logger, heartbeat time.Duration) *EventsHandler {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &EventsHandler{
+		base:      base{log: log},
+		stream:    stream,
+		heartbeat: heartbeat,
+	}
+}
+
+func (h *EventsHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/todos/events", h.loggingMiddleware(h.eventsHandler))
+}
+
+// eventsHandler serves /todos/events. It takes the filter parameters of
+// GET /todos, except search, plus type to pick event types, and resumes
+// after the Last-Event-ID header or last_event_id parameter. The stream has
+// no request timeout; it ends when the client goes away or the stream is
+// closed.
+func (h *EventsHandler) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("search") != "" {
+		h.respondError(w, http.StatusBadRequest, "search is not supported on the event stream")
+		return
+	}
+	filter, err := parseTodoFilter(query, domain.TodoFilter{})
+	if err == nil {
+		err = checkStreamFilter(&filter)
+	}
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var types []domain.EventType
+	if typeStr := query.Get("type"); typeStr != "" {
+		for _, t := range strings.Split(typeStr, ",") {
+			types = append(types, domain.EventType(strings.TrimSpace(t)))
+		}
+		if types, err = domain.NormalizeEventTypes(types); err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	wants := func(e stream.Event) bool {
+		if len(types) > 0 && !containsEventType(types, e.Type) {
+			return false
+		}
+		filter.Now = time.Now()
+		return e.Matches(filter.Match)
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	sub := h.stream.Subscribe(lastEventID)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	// the server's write timeout would cut the stream off
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if sub.Reset {
+		fmt.Fprintf(w, "id: %s\nevent: reset\ndata: {}\n\n", sub.LastID)
+	} else {
+		// a comment, so the client sees the stream open before any event
+		io.WriteString(w, ": connected\n\n")
+	}
+	// skipped is the ID of the latest event filtered out since the last one
+	// sent, so heartbeats can move the client's Last-Event-ID past it.
+	var skipped string
+	for _, e := range sub.Backlog {
+		if wants(e) {
+			h.writeEvent(w, e)
+			skipped = ""
+		} else {
+			skipped = e.ID
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// closed on shutdown, or because the client fell behind and
+				// has to resume
+				return
+			}
+			if !wants(e) {
+				skipped = e.ID
+				continue
+			}
+			h.writeEvent(w, e)
+			skipped = ""
+		case <-heartbeat.C:
+			if skipped != "" {
+				// an id without data is not dispatched as an event
+				fmt.Fprintf(w, "id: %s\n\n", skipped)
+				skipped = ""
+			} else {
+				io.WriteString(w, ": heartbeat\n\n")
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (h *EventsHandler) writeEvent(w io.Writer, e stream.Event) {
+	data, err := json.Marshal(e.TodoEvent)
+	if err != nil {
+		h.log.Error("failed to encode event", "error", err, "id", e.ID)
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+// checkStreamFilter validates what TodoService.List would for a listing.
+func checkStreamFilter(filter *domain.TodoFilter) error {
+	for _, p := range filter.Priorities {
+		if !p.Valid() {
+			return domain.ErrInvalidPriority
+		}
+	}
+
+	tags, err := domain.NormalizeTagNames(filter.Tags)
+	if err != nil {
+		return err
+	}
+	filter.Tags = tags
+	switch filter.TagMatch {
+	case "":
+		filter.TagMatch = domain.TagMatchAny
+	case domain.TagMatchAny, domain.TagMatchAll:
+	default:
+		return domain.ErrInvalidTagMatch
+	}
+	return nil
+}
+
+func containsEventType(types []domain.EventType, t domain.EventType) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
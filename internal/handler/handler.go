@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+// base holds what every resource handler shares: logging, the request
+// timeout and the JSON helpers.
+type base struct {
+	log            *logger.Logger
+	requestTimeout time.Duration
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *base) handleRequestError(w http.ResponseWriter, err error) {
+	var syntaxErr *json.SyntaxError
+	var unmarshalTypeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		h.respondError(w, http.StatusBadRequest, "malformed JSON at position "+strconv.Itoa(int(syntaxErr.Offset)))
+	case errors.As(err, &unmarshalTypeErr):
+		h.respondError(w, http.StatusBadRequest, "invalid value for field "+unmarshalTypeErr.Field)
+	case errors.Is(err, io.EOF):
+		h.respondError(w, http.StatusBadRequest, "empty request body")
+	default:
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+	}
+}
+
+func (h *base) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// extractID reads the id that follows prefix in path, e.g. /todos/{id}.
+func (h *base) extractID(path, prefix string) (int, error) {
+	path = strings.TrimPrefix(path, prefix)
+	path = strings.TrimSuffix(path, "/")
+
+	if path == "" {
+		return 0, domain.ErrInvalidPath
+	}
+
+	id, err := strconv.Atoi(path)
+	if err != nil || id <= 0 {
+		return 0, domain.ErrInvalidID
+	}
+
+	return id, nil
+}
+
+func (h *base) respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if data != nil {
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			h.log.Error("failed to encode response", "error", err)
+		}
+	}
+}
+
+func (h *base) respondError(w http.ResponseWriter, status int, message string) {
+	h.respondJSON(w, status, errorResponse{Error: message})
+}
+
+func (h *base) loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		userAgent := r.UserAgent()
+		sw := &statusResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		h.log.Info("incoming request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote", r.RemoteAddr,
+			"user_agent", userAgent,
+		)
+
+		next(sw, r)
+
+		h.log.Info("request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start),
+		)
+	}
+}
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush a stream.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,18 +1,28 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/idempotency"
 	"github.com/yokitheyo/todo/internal/repository/memory"
 	"github.com/yokitheyo/todo/internal/service"
+	"github.com/yokitheyo/todo/internal/stream"
+	"github.com/yokitheyo/todo/internal/websocket"
 	"github.com/yokitheyo/todo/pkg/logger"
 )
 
@@ -24,6 +34,15 @@ func setupTestHandler(t *testing.T) (*TodoHandler, *memory.TodoRepository) {
 	return handler, repo
 }
 
+// mergePatch sends a JSON Merge Patch of target to mux.
+func mergePatch(mux http.Handler, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
 type TodoServiceMock struct {
 	Repo *memory.TodoRepository
 }
@@ -168,12 +187,1288 @@ func TestTodoHandler_GetAllEmpty(t *testing.T) {
 		t.Fatalf("expected 200 OK, got %d", w.Code)
 	}
 
-	var todos []domain.Todo
-	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+	var page domain.TodoPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	if page.Items == nil || len(page.Items) != 0 || page.Total != 0 || page.NextCursor != "" {
+		t.Errorf("expected empty page, got %+v", page)
+	}
+}
+
+func TestTodoHandler_Pagination(t *testing.T) {
+	handler, repo := setupTestHandler(t)
+	for i := 0; i < 5; i++ {
+		_, _ = repo.Create(context.Background(), domain.CreateTodoInput{Title: "Task " + strconv.Itoa(i)})
+	}
+
+	var ids []int
+	url := "/todos?limit=2"
+	for pages := 0; url != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler.todosHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", w.Code)
+		}
+
+		var page domain.TodoPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("expected total 5, got %d", page.Total)
+		}
+		for _, todo := range page.Items {
+			ids = append(ids, todo.ID)
+		}
+
+		url = ""
+		if page.NextCursor != "" {
+			url = "/todos?limit=2&cursor=" + page.NextCursor
+		}
+	}
+
+	if len(ids) != 5 {
+		t.Fatalf("expected 5 todos across pages, got %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("expected ascending IDs across pages, got %v", ids)
+		}
+	}
+}
+
+func TestTodoHandler_PaginationErrors(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	for _, url := range []string{"/todos?limit=abc", "/todos?limit=-1", "/todos?limit=1000", "/todos?cursor=not-a-cursor"} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler.todosHandler(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 Bad Request, got %d", url, w.Code)
+		}
+	}
+}
+
+func TestTodoHandler_FilterExpression(t *testing.T) {
+	handler, repo := setupTestHandler(t)
+	ctx := context.Background()
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "Buy milk"})
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "Buy bread", Completed: true})
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "Call mom"})
+
+	req := httptest.NewRequest(http.MethodGet, "/todos?filter="+url.QueryEscape(`title prefix buy AND NOT completed = true`), nil)
+	w := httptest.NewRecorder()
+	handler.todosHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var page domain.TodoPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if page.Total != 1 || page.Items[0].Title != "Buy milk" {
+		t.Errorf("expected only Buy milk, got %+v", page.Items)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/todos?filter="+url.QueryEscape(`title prefix buy AND`), nil)
+	w = httptest.NewRecorder()
+	handler.todosHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 Bad Request, got %d", w.Code)
+	}
+	var errResp errorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
 		t.Fatalf("decode failed: %v", err)
 	}
+	if !strings.Contains(errResp.Error, "position 21") {
+		t.Errorf("expected error to point at position 21, got %q", errResp.Error)
+	}
+}
+
+func TestTodoHandler_PriorityAndDueDate(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.todosHandler(w, req)
+		return w
+	}
+
+	w := post(`{"title": "Pay rent", "priority": "urgent", "due_at": "2000-01-01T09:00:00+02:00"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	var created domain.Todo
+	_ = json.NewDecoder(w.Body).Decode(&created)
+	if created.Priority != domain.PriorityUrgent || created.DueAt == nil || !created.DueAt.Equal(time.Date(2000, 1, 1, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected urgent todo due 2000-01-01T07:00Z, got %+v", created)
+	}
+
+	post(`{"title": "Someday"}`)
+
+	if w := post(`{"title": "Bad", "priority": "critical"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for unknown priority, got %d", w.Code)
+	}
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"overdue=true", 1},
+		{"overdue=false", 1},
+		{"priority=urgent,high", 1},
+		{"priority=none", 1},
+		{"due_before=2000-01-02", 1},
+		{"due_after=2000-01-02", 0},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/todos?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		handler.todosHandler(w, req)
+
+		var page domain.TodoPage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		if w.Code != http.StatusOK || page.Total != tc.want {
+			t.Errorf("%s: expected 200 with %d todos, got %d with %d", tc.query, tc.want, w.Code, page.Total)
+		}
+	}
+
+	for _, query := range []string{"priority=critical", "due_before=tomorrow", "due_after=2000-13-01"} {
+		req := httptest.NewRequest(http.MethodGet, "/todos?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.todosHandler(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 Bad Request, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestTagHandler_TagsOnTodos(t *testing.T) {
+	todos, repo := setupTestHandler(t)
+	tags := NewTagHandler(service.NewTagService(repo), todos.log, 2*time.Second)
+	mux := http.NewServeMux()
+	todos.RegisterRoutes(mux)
+	tags.RegisterRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/tags", `{"name": " Work ", "color": "#AABBCC"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	var work domain.Tag
+	_ = json.NewDecoder(w.Body).Decode(&work)
+	if work.Name != "work" || work.Color != "#aabbcc" {
+		t.Errorf("expected normalized tag, got %+v", work)
+	}
+
+	do(http.MethodPost, "/tags", `{"name": "urgent"}`)
+	if w := do(http.MethodPost, "/tags", `{"name": "work"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 Conflict for a duplicate name, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/tags", `{"name": "no spaces"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an invalid name, got %d", w.Code)
+	}
+
+	do(http.MethodPost, "/todos", `{"title": "Both", "tags": ["urgent", "WORK"]}`)
+	do(http.MethodPost, "/todos", `{"title": "Work only", "tags": ["work"]}`)
+	if w := do(http.MethodPost, "/todos", `{"title": "Unknown", "tags": ["nope"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown tag, got %d", w.Code)
+	}
+
+	total := func(query string) int {
+		w := do(http.MethodGet, "/todos?"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK, got %d", query, w.Code)
+		}
+		var page domain.TodoPage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		return page.Total
+	}
+	if n := total("tag=work&tag=urgent"); n != 2 {
+		t.Errorf("expected 2 todos with any tag, got %d", n)
+	}
+	if n := total("tag=work&tag=urgent&tag_match=all"); n != 1 {
+		t.Errorf("expected 1 todo with all tags, got %d", n)
+	}
+	if w := do(http.MethodGet, "/todos?tag=work&tag_match=some", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown tag_match, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPut, "/tags/"+strconv.Itoa(work.ID), `{"name": "job"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK renaming, got %d", w.Code)
+	}
+	if n := total("tag=job"); n != 2 {
+		t.Errorf("expected the rename to reach both todos, got %d", n)
+	}
+
+	if w := do(http.MethodDelete, "/tags/"+strconv.Itoa(work.ID), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/tags/"+strconv.Itoa(work.ID), ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found after delete, got %d", w.Code)
+	}
+	if n := total("tag=urgent"); n != 1 {
+		t.Errorf("expected other tags untouched, got %d", n)
+	}
+
+	w = do(http.MethodGet, "/tags", "")
+	var list []domain.Tag
+	_ = json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].Name != "urgent" {
+		t.Errorf("expected only urgent left, got %+v", list)
+	}
+}
+
+func TestListHandler_NestedTodos(t *testing.T) {
+	todos, repo := setupTestHandler(t)
+	lists := NewListHandler(service.NewListService(repo), todos, todos.log, 2*time.Second)
+	mux := http.NewServeMux()
+	todos.RegisterRoutes(mux)
+	lists.RegisterRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/lists", `{"name": "Work"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	var work domain.TodoList
+	_ = json.NewDecoder(w.Body).Decode(&work)
+	base := "/lists/" + strconv.Itoa(work.ID)
+
+	if w := do(http.MethodPost, "/lists", `{"name": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an empty name, got %d", w.Code)
+	}
+
+	w = do(http.MethodPost, base+"/todos", `{"title": "Report"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	var report domain.Todo
+	_ = json.NewDecoder(w.Body).Decode(&report)
+	if report.ListID != work.ID {
+		t.Errorf("expected the todo in list %d, got %d", work.ID, report.ListID)
+	}
+	do(http.MethodPost, base+"/todos", `{"title": "Email", "completed": true}`)
+	do(http.MethodPost, "/todos", `{"title": "Loose"}`)
+
+	if w := do(http.MethodPost, "/lists/999/todos", `{"title": "Lost"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for a missing list, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/todos", `{"title": "Lost", "list_id": 999}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown list_id, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, base+"/tasks", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for an unknown sub-resource, got %d", w.Code)
+	}
+
+	total := func(target string) int {
+		w := do(http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK, got %d", target, w.Code)
+		}
+		var page domain.TodoPage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		return page.Total
+	}
+	if n := total(base + "/todos"); n != 2 {
+		t.Errorf("expected 2 todos in the list, got %d", n)
+	}
+	if n := total(base + "/todos?completed=false"); n != 1 {
+		t.Errorf("expected the usual filters to apply, got %d", n)
+	}
+	if n := total("/todos?list_id=0"); n != 1 {
+		t.Errorf("expected 1 todo outside any list, got %d", n)
+	}
+
+	w = do(http.MethodGet, base, "")
+	var got domain.TodoList
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if got.OpenCount != 1 || got.CompletedCount != 1 {
+		t.Errorf("expected 1 open and 1 completed, got %+v", got)
+	}
+
+	// move the report out of the list
+	if w := mergePatch(mux, "/todos/"+strconv.Itoa(report.ID), `{"list_id": 0}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK moving, got %d", w.Code)
+	}
+	if n := total(base + "/todos"); n != 1 {
+		t.Errorf("expected 1 todo left in the list, got %d", n)
+	}
+
+	if w := do(http.MethodDelete, base, ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 Conflict deleting a list with todos, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, base+"?cascade=true", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+	if n := total("/todos"); n != 2 {
+		t.Errorf("expected the cascade to delete only the list's todos, got %d left", n)
+	}
+	if w := do(http.MethodGet, base+"/todos", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found after delete, got %d", w.Code)
+	}
+}
+
+func TestTodoHandler_Subtasks(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	create := func(body string) domain.Todo {
+		w := do(http.MethodPost, "/todos", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+		}
+		var todo domain.Todo
+		_ = json.NewDecoder(w.Body).Decode(&todo)
+		return todo
+	}
+
+	root := create(`{"title": "Root"}`)
+	rootPath := "/todos/" + strconv.Itoa(root.ID)
+	child := create(`{"title": "Child", "parent_id": ` + strconv.Itoa(root.ID) + `}`)
+	create(`{"title": "Grandchild", "parent_id": ` + strconv.Itoa(child.ID) + `}`)
+
+	if w := do(http.MethodPost, "/todos", `{"title": "Lost", "parent_id": 999}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown parent, got %d", w.Code)
+	}
+	if w := mergePatch(mux, rootPath, `{"parent_id": `+strconv.Itoa(child.ID)+`}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for a cycle, got %d", w.Code)
+	}
+
+	w := do(http.MethodGet, rootPath+"/children", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var page domain.TodoPage
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if page.Total != 1 || page.Items[0].ID != child.ID {
+		t.Errorf("expected only the direct child, got %+v", page.Items)
+	}
+
+	w = do(http.MethodGet, rootPath+"/subtree", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var tree domain.TodoTree
+	_ = json.NewDecoder(w.Body).Decode(&tree)
+	if tree.ID != root.ID || len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 {
+		t.Errorf("unexpected subtree %+v", tree)
+	}
+
+	if w := do(http.MethodGet, "/todos/999/children", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for a missing todo, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, rootPath+"/parents", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for an unknown sub-resource, got %d", w.Code)
+	}
+
+	if w := do(http.MethodDelete, rootPath, ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 Conflict deleting a todo with subtasks, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, rootPath+"?children=drop", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown policy, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, rootPath+"?children=cascade", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+	w = do(http.MethodGet, "/todos", "")
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if page.Total != 0 {
+		t.Errorf("expected the whole subtree deleted, got %d left", page.Total)
+	}
+}
+
+func TestTodoHandler_Blockers(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	do(http.MethodPost, "/todos", `{"title": "Deploy"}`)
+	do(http.MethodPost, "/todos", `{"title": "Build"}`)
+
+	w := do(http.MethodPost, "/todos/1/blockers", `{"blocker_id": 2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	var todo domain.Todo
+	_ = json.NewDecoder(w.Body).Decode(&todo)
+	if !todo.Blocked || len(todo.BlockedBy) != 1 || todo.BlockedBy[0] != 2 {
+		t.Errorf("expected todo blocked by 2, got %+v", todo)
+	}
+
+	if w := do(http.MethodPost, "/todos/1/blockers", `{"blocker_id": 2}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 Conflict for a duplicate, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/todos/2/blockers", `{"blocker_id": 1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for a cycle, got %d", w.Code)
+	}
+	if w := mergePatch(mux, "/todos/1", `{"completed": true}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 Conflict completing a blocked todo, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/todos/1/blockers", "")
+	var blockers []domain.Todo
+	_ = json.NewDecoder(w.Body).Decode(&blockers)
+	if len(blockers) != 1 || blockers[0].Title != "Build" {
+		t.Errorf("expected Build as the blocker, got %+v", blockers)
+	}
+
+	w = do(http.MethodGet, "/todos/next", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var page domain.TodoPage
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Items) != 2 || page.Items[0].ID != 2 {
+		t.Errorf("expected the blocker first, got %+v", page.Items)
+	}
+
+	if w := do(http.MethodDelete, "/todos/1/blockers/2", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/todos/1/blockers/2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for a missing dependency, got %d", w.Code)
+	}
+	if w := mergePatch(mux, "/todos/1", `{"completed": true}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK once unblocked, got %d", w.Code)
+	}
+}
+
+func TestTodoHandler_Recurrence(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/todos", `{"title": "Bad", "due_at": "2025-01-06T09:00:00Z", "recurrence": "FREQ=SECONDLY"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unsupported rule, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/todos", `{"title": "Undated", "recurrence": "FREQ=DAILY"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request without due_at, got %d", w.Code)
+	}
+
+	w := do(http.MethodPost, "/todos", `{"title": "Standup", "due_at": "2025-01-06T09:00:00Z", "recurrence": "FREQ=WEEKLY;BYDAY=MO,TH"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+
+	w = mergePatch(mux, "/todos/1", `{"completed": true}`)
+	var todo domain.Todo
+	_ = json.NewDecoder(w.Body).Decode(&todo)
+	if todo.NextOccurrenceID != 2 {
+		t.Fatalf("expected next occurrence 2, got %+v", todo)
+	}
+
+	w = do(http.MethodGet, "/todos/2", "")
+	todo = domain.Todo{}
+	_ = json.NewDecoder(w.Body).Decode(&todo)
+	want := time.Date(2025, 1, 9, 9, 0, 0, 0, time.UTC)
+	if todo.DueAt == nil || !todo.DueAt.Equal(want) || todo.PreviousOccurrenceID != 1 || todo.Occurrence != 2 {
+		t.Errorf("expected occurrence 2 due %s after 1, got %+v", want, todo)
+	}
+}
+
+func TestTodoHandler_ETags(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/todos", `{"title": "Task"}`)
+	created := w.Header().Get("ETag")
+	if w.Code != http.StatusCreated || !strings.HasPrefix(created, `"1-`) {
+		t.Fatalf("expected 201 Created with a version 1 ETag, got %d %q", w.Code, created)
+	}
+
+	w = do(http.MethodGet, "/todos/1", "")
+	if etag := w.Header().Get("ETag"); etag != created {
+		t.Fatalf("expected GET to return ETag %s, got %s", created, etag)
+	}
+	if w := do(http.MethodGet, "/todos/1", "", "If-None-Match", `"0-stale", W/`+created); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 Not Modified without a body, got %d: %s", w.Code, w.Body)
+	}
+
+	w = do(http.MethodPut, "/todos/1", `{"title": "Mine"}`, "If-Match", created)
+	updated := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(updated, `"2-`) {
+		t.Fatalf("expected 200 OK with a version 2 ETag, got %d %q: %s", w.Code, updated, w.Body)
+	}
+	if w := do(http.MethodPut, "/todos/1", `{"title": "Theirs"}`, "If-Match", created); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 Precondition Failed for a stale If-Match, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/todos/1", `{"title": "Theirs", "version": 1}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 Precondition Failed for a stale version, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/todos/1", "", "If-None-Match", created); w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK once the todo changed, got %d", w.Code)
+	}
+
+	if w := do(http.MethodDelete, "/todos/1", "", "If-Match", created); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 Precondition Failed for a stale delete, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/todos/1", "", "If-Match", updated); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodDelete, "/todos/1", "", "If-Match", "*"); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 Precondition Failed for If-Match: * on a deleted todo, got %d", w.Code)
+	}
+}
+
+func TestTodoHandler_Batch(t *testing.T) {
+	handler, repo := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	post := func(target, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		resp := map[string]interface{}{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+	statuses := func(resp map[string]interface{}) []int {
+		var got []int
+		results, _ := resp["results"].([]interface{})
+		for _, r := range results {
+			status, _ := r.(map[string]interface{})["status"].(float64)
+			got = append(got, int(status))
+		}
+		return got
+	}
+
+	existing, _ := repo.Create(context.Background(), domain.CreateTodoInput{Title: "Existing"})
+	ops := `[
+		{"op": "create", "todo": {"title": "New"}},
+		{"op": "update", "id": 1, "todo": {"title": "Renamed"}},
+		{"op": "delete", "id": 999}
+	]`
+
+	w, resp := post("/todos:batch", `{"atomic": true, "operations": `+ops+`}`)
+	if w.Code != http.StatusNotFound || resp["index"] != 2.0 {
+		t.Fatalf("expected the atomic batch to fail at operation 2 with 404, got %d %s", w.Code, w.Body)
+	}
+	if all, _ := repo.GetAll(context.Background()); len(all) != 1 || all[0].Title != "Existing" {
+		t.Errorf("expected nothing applied, got %+v", all)
+	}
+
+	w, resp = post("/todos:batch", `{"operations": `+ops+`}`)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(statuses(resp), []int{201, 200, 404}) {
+		t.Fatalf("expected per operation statuses 201, 200, 404, got %d %s", w.Code, w.Body)
+	}
+	if got, _ := repo.GetByID(context.Background(), existing.ID); got.Title != "Renamed" {
+		t.Errorf("expected the update applied, got %+v", got)
+	}
+
+	for _, body := range []string{
+		`{"operations": []}`,
+		`{"operations": [{"op": "frobnicate"}]}`,
+		`{"operations": [{"op": "create", "todo": {"title": "x", "bogus": 1}}]}`,
+		`{"operations": [{"op": "update", "id": 1}]}`,
+	} {
+		if w, _ := post("/todos:batch", body); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	// todos 1 and 2 are open
+	w, resp = post("/todos:complete?search=renamed", "")
+	if w.Code != http.StatusOK || !reflect.DeepEqual(statuses(resp), []int{200}) {
+		t.Fatalf("expected one todo completed, got %d %s", w.Code, w.Body)
+	}
+	w, resp = post("/todos:delete-completed?atomic=true", "")
+	if w.Code != http.StatusOK || !reflect.DeepEqual(statuses(resp), []int{204}) {
+		t.Fatalf("expected one todo deleted, got %d %s", w.Code, w.Body)
+	}
+	if all, _ := repo.GetAll(context.Background()); len(all) != 1 || all[0].Title != "New" {
+		t.Errorf("expected only the open todo left, got %+v", all)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/todos:batch", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestTodoHandler_IdempotencyKey(t *testing.T) {
+	handler, repo := setupTestHandler(t)
+	handler.SetIdempotencyStore(idempotency.NewStore(idempotency.Options{}))
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("a", `{"title": "Pay rent"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", first.Code, first.Body)
+	}
+	retry := post("a", `{"title": "Pay rent"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected the first response replayed, got %d %q: %s", retry.Code, retry.Header().Get("Idempotent-Replayed"), retry.Body)
+	}
+	if retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("expected the replay to keep the ETag %s, got %s", first.Header().Get("ETag"), retry.Header().Get("ETag"))
+	}
+	if w := post("a", `{"title": "Pay bills"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 Unprocessable Entity for a reused key, got %d", w.Code)
+	}
+
+	// failures that are not the server's are replayed as well
+	if w := post("b", `{"title": ""}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 Bad Request, got %d", w.Code)
+	}
+	if w := post("b", `{"title": ""}`); w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected the 400 replayed, got %d", w.Code)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := post("c", `{"title": "Once"}`); w.Code != http.StatusCreated {
+				t.Errorf("expected 201 Created, got %d: %s", w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	todos, _ := repo.GetAll(context.Background())
+	if len(todos) != 2 {
+		t.Errorf("expected 2 todos created, got %d", len(todos))
+	}
+}
+
+func TestTodoHandler_PutAndPatch(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	do := func(method, body, contentType string) (*httptest.ResponseRecorder, domain.Todo) {
+		req := httptest.NewRequest(method, "/todos/1", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var todo domain.Todo
+		if w.Code == http.StatusOK {
+			_ = json.Unmarshal(w.Body.Bytes(), &todo)
+		}
+		return w, todo
+	}
+	const (
+		mergePatchType = "application/merge-patch+json"
+		jsonPatchType  = "application/json-patch+json"
+	)
+
+	handler.todosHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/todos",
+		strings.NewReader(`{"title": "Report", "description": "Q1", "priority": "high", "due_at": "2025-01-06T09:00:00Z"}`)))
+
+	// a GET body can be sent back, read-only fields and all
+	w, _ := do(http.MethodGet, "", "")
+	doc := map[string]interface{}{}
+	_ = json.Unmarshal(w.Body.Bytes(), &doc)
+	doc["title"] = "Quarterly report"
+	body, _ := json.Marshal(doc)
+	w, todo := do(http.MethodPut, string(body), "")
+	if w.Code != http.StatusOK || todo.Title != "Quarterly report" || todo.Priority != domain.PriorityHigh || todo.DueAt == nil {
+		t.Fatalf("expected the round trip to change only the title, got %d %+v: %s", w.Code, todo, w.Body)
+	}
+
+	w, todo = do(http.MethodPut, `{"title": "Report"}`, "")
+	if w.Code != http.StatusOK || todo.Description != "" || todo.Priority != domain.PriorityNone || todo.DueAt != nil {
+		t.Errorf("expected PUT to reset the fields left out, got %d %+v", w.Code, todo)
+	}
+
+	w, todo = do(http.MethodPatch, `{"description": "Q2", "due_at": "2025-04-01T09:00:00Z"}`, mergePatchType)
+	if w.Code != http.StatusOK || todo.Title != "Report" || todo.Description != "Q2" || todo.DueAt == nil {
+		t.Fatalf("expected the merge patch applied, got %d %+v: %s", w.Code, todo, w.Body)
+	}
+	w, todo = do(http.MethodPatch, `{"due_at": null}`, mergePatchType)
+	if w.Code != http.StatusOK || todo.DueAt != nil || todo.Description != "Q2" {
+		t.Errorf("expected null to clear the due date only, got %d %+v", w.Code, todo)
+	}
+
+	w, todo = do(http.MethodPatch, `[
+		{"op": "test", "path": "/title", "value": "Report"},
+		{"op": "test", "path": "/version", "value": `+strconv.Itoa(todo.Version)+`},
+		{"op": "add", "path": "/priority", "value": "urgent"},
+		{"op": "copy", "from": "/title", "path": "/description"}
+	]`, jsonPatchType)
+	if w.Code != http.StatusOK || todo.Priority != domain.PriorityUrgent || todo.Description != "Report" {
+		t.Fatalf("expected the JSON patch applied, got %d %+v: %s", w.Code, todo, w.Body)
+	}
+
+	tests := []struct {
+		name, body, contentType string
+		want                    int
+	}{
+		{"failed test", `[{"op": "test", "path": "/title", "value": "Other"}, {"op": "remove", "path": "/description"}]`, jsonPatchType, http.StatusConflict},
+		{"stale version", `{"version": 1, "title": "Stale"}`, mergePatchType, http.StatusPreconditionFailed},
+		{"read-only field", `{"created_at": "2020-01-01T00:00:00Z"}`, mergePatchType, http.StatusUnprocessableEntity},
+		{"read-only path", `[{"op": "replace", "path": "/id", "value": 2}]`, jsonPatchType, http.StatusUnprocessableEntity},
+		{"validation", `[{"op": "remove", "path": "/title"}]`, jsonPatchType, http.StatusBadRequest},
+		{"invalid value", `{"priority": "whenever"}`, mergePatchType, http.StatusBadRequest},
+		{"unknown field", `{"colour": "red"}`, mergePatchType, http.StatusBadRequest},
+		{"wrong type", `{"title": 5}`, mergePatchType, http.StatusBadRequest},
+		{"missing path", `[{"op": "replace", "path": "/nope/deeper", "value": 1}]`, jsonPatchType, http.StatusBadRequest},
+		{"bad op", `[{"op": "frobnicate", "path": "/title"}]`, jsonPatchType, http.StatusBadRequest},
+		{"malformed", `{"title": `, mergePatchType, http.StatusBadRequest},
+		{"plain JSON", `{"title": "Plain"}`, "application/json", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		if w, _ := do(http.MethodPatch, tt.body, tt.contentType); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body)
+		}
+	}
+	if w, _ := do(http.MethodPatch, `{}`, "text/plain"); w.Header().Get("Accept-Patch") == "" {
+		t.Errorf("expected a 415 to list the accepted patch formats")
+	}
+
+	w, _ = do(http.MethodGet, "", "")
+	_ = json.Unmarshal(w.Body.Bytes(), &todo)
+	if todo.Title != "Report" || todo.Description != "Report" {
+		t.Errorf("expected failed patches to leave the todo alone, got %+v", todo)
+	}
+}
+
+func TestTodoHandler_TrashAndRestore(t *testing.T) {
+	handler, repo := setupTestHandler(t)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+	ctx := context.Background()
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	parent, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Parent"})
+	child, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Child", ParentID: parent.ID})
+	if w := do(http.MethodDelete, "/todos/"+strconv.Itoa(parent.ID)+"?children=cascade"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/todos/"+strconv.Itoa(parent.ID)); w.Code != http.StatusNotFound {
+		t.Errorf("expected a trashed todo to be 404, got %d", w.Code)
+	}
+
+	w := do(http.MethodGet, "/trash")
+	var trash []domain.Todo
+	if err := json.Unmarshal(w.Body.Bytes(), &trash); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected the trash, got %d %s", w.Code, w.Body)
+	}
+	if len(trash) != 2 || trash[0].DeletedAt == nil {
+		t.Errorf("expected both todos trashed with deleted_at, got %+v", trash)
+	}
+	if w := do(http.MethodPost, "/trash"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "/todos/"+strconv.Itoa(child.ID)+"/restore"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 restoring under a trashed parent, got %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/todos/"+strconv.Itoa(parent.ID)+"/restore"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/todos/999/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+
+	w = do(http.MethodPost, "/todos/"+strconv.Itoa(parent.ID)+"/restore")
+	var restored domain.Todo
+	if err := json.Unmarshal(w.Body.Bytes(), &restored); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected the restored todo, got %d %s", w.Code, w.Body)
+	}
+	if restored.ID != parent.ID || restored.DeletedAt != nil || w.Header().Get("ETag") != todoETag(&restored) {
+		t.Errorf("unexpected restored todo %+v, etag %q", restored, w.Header().Get("ETag"))
+	}
+	if w := do(http.MethodGet, "/todos/"+strconv.Itoa(parent.ID)); w.Code != http.StatusOK {
+		t.Errorf("expected the restored todo found, got %d", w.Code)
+	}
+}
+
+type deliveryLogMock struct {
+	deliveries []domain.WebhookDelivery
+}
+
+func (l *deliveryLogMock) Deliveries(webhookID int) []domain.WebhookDelivery {
+	var out []domain.WebhookDelivery
+	for _, d := range l.deliveries {
+		if d.WebhookID == webhookID {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (l *deliveryLogMock) DeadLetters() []domain.WebhookDelivery {
+	var out []domain.WebhookDelivery
+	for _, d := range l.deliveries {
+		if d.Status == domain.DeliveryDead {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func TestWebhookHandler_CRUDAndDeliveries(t *testing.T) {
+	log := &deliveryLogMock{deliveries: []domain.WebhookDelivery{
+		{ID: "b", WebhookID: 1, Event: domain.EventTodoUpdated, Status: domain.DeliveryDead, Attempts: 6},
+		{ID: "a", WebhookID: 1, Event: domain.EventTodoCreated, Status: domain.DeliverySucceeded, Attempts: 1},
+	}}
+	svc := service.NewWebhookService(memory.NewTodoRepository(), log)
+	handler := NewWebhookHandler(svc, logger.New("error", nil, "json"), 2*time.Second)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/webhooks", `{"url": "not a url", "secret": "0123456789abcdef"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for a bad url, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/webhooks", `{"url": "http://169.254.169.254/latest", "secret": "0123456789abcdef"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for a link-local url, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/webhooks", `{"url": "https://example.com", "secret": "0123456789abcdef", "events": ["todo.moved"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown event, got %d", w.Code)
+	}
+
+	w := do(http.MethodPost, "/webhooks", `{"url": "https://example.com/hook", "secret": "0123456789abcdef", "events": ["todo.completed"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("expected the secret left out, got %s", w.Body)
+	}
+
+	w = do(http.MethodPut, "/webhooks/1", `{"active": false}`)
+	var hook domain.Webhook
+	_ = json.NewDecoder(w.Body).Decode(&hook)
+	if w.Code != http.StatusOK || hook.Active || len(hook.Events) != 1 {
+		t.Errorf("expected an inactive webhook, got %d %+v", w.Code, hook)
+	}
+
+	w = do(http.MethodGet, "/webhooks/1/deliveries?status=succeeded", "")
+	var deliveries []domain.WebhookDelivery
+	_ = json.NewDecoder(w.Body).Decode(&deliveries)
+	if w.Code != http.StatusOK || len(deliveries) != 1 || deliveries[0].ID != "a" {
+		t.Errorf("expected the succeeded delivery, got %d %+v", w.Code, deliveries)
+	}
+	if w := do(http.MethodGet, "/webhooks/1/deliveries?status=lost", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown status, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/webhooks/dead-letters", "")
+	deliveries = nil
+	_ = json.NewDecoder(w.Body).Decode(&deliveries)
+	if len(deliveries) != 1 || deliveries[0].ID != "b" {
+		t.Errorf("expected one dead letter, got %+v", deliveries)
+	}
+
+	if w := do(http.MethodDelete, "/webhooks/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 No Content, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/webhooks/1/deliveries", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for a deleted webhook, got %d", w.Code)
+	}
+}
+
+// sseEvent is one message read off an event stream; comments are kept as
+// event "comment".
+type sseEvent struct {
+	id, event, data string
+}
+
+func readEvents(body *bufio.Reader, events chan<- sseEvent) {
+	var e sseEvent
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			close(events)
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			events <- e
+			e = sseEvent{}
+		case strings.HasPrefix(line, ":"):
+			e.event = "comment"
+			e.data = strings.TrimSpace(line[1:])
+		default:
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				e.id = value
+			case "event":
+				e.event = value
+			case "data":
+				e.data = value
+			}
+		}
+	}
+}
+
+func TestEventsHandler_StreamAndResume(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	svc := service.NewTodoService(repo)
+	broker := stream.NewBroker(stream.Options{})
+	svc.AddEventSink(broker)
+
+	mux := http.NewServeMux()
+	NewEventsHandler(broker, logger.New("error", nil, "json"), 50*time.Millisecond).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	connect := func(query, lastEventID string) (chan sseEvent, func()) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/todos/events"+query, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("connect failed: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		events := make(chan sseEvent, 16)
+		go readEvents(bufio.NewReader(resp.Body), events)
+		return events, func() { resp.Body.Close() }
+	}
+	next := func(events <-chan sseEvent) sseEvent {
+		t.Helper()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					t.Fatal("stream ended")
+				}
+				if e.event != "comment" {
+					return e
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for an event")
+			}
+		}
+	}
+
+	if resp, _ := http.Get(srv.URL + "/todos/events?type=todo.moved"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an unknown type, got %d", resp.StatusCode)
+	}
+
+	events, disconnect := connect("?completed=true", "")
+	open, disconnectOpen := connect("?completed=false", "")
+	ctx := context.Background()
+	todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Ship"})
+	done := true
+	svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+
+	updated := next(events)
+	var payload domain.TodoEvent
+	if err := json.Unmarshal([]byte(updated.data), &payload); err != nil {
+		t.Fatalf("bad data %q: %v", updated.data, err)
+	}
+	if updated.event != "todo.updated" || payload.Todo.ID != todo.ID || !payload.Todo.Completed || updated.id == "" {
+		t.Errorf("expected the completed todo first, skipping its creation, got %+v", updated)
+	}
+	if e := next(events); e.event != "todo.completed" {
+		t.Errorf("expected todo.completed, got %+v", e)
+	}
+	disconnect()
+
+	// a stream of open todos sees the todo leave
+	for _, want := range []string{"todo.created", "todo.updated", "todo.completed"} {
+		if e := next(open); e.event != want {
+			t.Errorf("expected %s on the open stream, got %+v", want, e)
+		}
+	}
+	disconnectOpen()
+
+	// missed while disconnected
+	svc.Delete(ctx, todo.ID, "")
+
+	events, disconnect = connect("", updated.id)
+	if e := next(events); e.event != "todo.completed" {
+		t.Errorf("expected to resume with todo.completed, got %+v", e)
+	}
+	if e := next(events); e.event != "todo.deleted" {
+		t.Errorf("expected todo.deleted, got %+v", e)
+	}
+
+	select {
+	case e := <-events:
+		if e.event != "comment" || e.data != "heartbeat" {
+			t.Errorf("expected a heartbeat, got %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timed out waiting for a heartbeat")
+	}
+	disconnect()
+
+	events, disconnect = connect("", "unknown-1")
+	defer disconnect()
+	if e := next(events); e.event != "reset" {
+		t.Errorf("expected a reset for an unknown id, got %+v", e)
+	}
+
+	broker.Close()
+	for range events {
+	}
+}
+
+func TestEventsHandler_EndsOnShutdown(t *testing.T) {
+	broker := stream.NewBroker(stream.Options{})
+	mux := http.NewServeMux()
+	NewEventsHandler(broker, logger.New("error", nil, "json"), time.Minute).RegisterRoutes(mux)
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Config.RegisterOnShutdown(broker.Close)
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/todos/events")
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer resp.Body.Close()
+	waitForSubscribers(t, broker, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("expected the stream to end cleanly, got %v", err)
+	}
+}
+
+func waitForSubscribers(t *testing.T, broker *stream.Broker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for broker.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", n, broker.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWSHandler_CommandsAndEvents(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	svc := service.NewTodoService(repo)
+	broker := stream.NewBroker(stream.Options{})
+	svc.AddEventSink(broker)
+	ctx := context.Background()
+	list, _ := repo.CreateList(ctx, domain.CreateListInput{Name: "Work"})
+
+	handler := NewWSHandler(svc, broker, logger.New("error", nil, "json"), 2*time.Second)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dialCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	conn, err := websocket.Dial(dialCtx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.ReadTimeout = 2 * time.Second
+
+	send := func(msg string) {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+	}
+	read := func() wsReply {
+		t.Helper()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		var reply wsReply
+		if err := json.Unmarshal(data, &reply); err != nil {
+			t.Fatalf("bad reply %s: %v", data, err)
+		}
+		return reply
+	}
+
+	send(`{"id": 1, "type": "subscribe", "list_ids": [` + strconv.Itoa(list.ID) + `]}`)
+	if r := read(); r.Type != "ack" || string(r.ID) != "1" || len(r.ListIDs) != 1 {
+		t.Fatalf("expected the subscription acked, got %+v", r)
+	}
+
+	// outside the list, so no event
+	send(`{"id": "a", "type": "create", "todo": {"title": "Elsewhere"}}`)
+	if r := read(); r.Type != "ack" || r.Todo == nil || r.Todo.Title != "Elsewhere" {
+		t.Fatalf("expected the created todo, got %+v", r)
+	}
+
+	send(`{"id": "b", "type": "create", "todo": {"title": "Ship", "list_id": ` + strconv.Itoa(list.ID) + `}}`)
+	// the event may overtake the ack
+	var ack, event wsReply
+	for i := 0; i < 2; i++ {
+		if r := read(); r.Type == "event" {
+			event = r
+		} else {
+			ack = r
+		}
+	}
+	if ack.Type != "ack" || string(ack.ID) != `"b"` || ack.Todo == nil {
+		t.Fatalf("expected the create acked, got %+v", ack)
+	}
+	if event.Event == nil || event.Event.Type != domain.EventTodoCreated || event.Event.Todo.ID != ack.Todo.ID || event.EventID == "" {
+		t.Errorf("expected a todo.created event, got %+v", event)
+	}
+
+	send(`{"id": "c", "type": "update", "todo_id": 999, "todo": {"completed": true}}`)
+	if r := read(); r.Type != "error" || r.Status != http.StatusNotFound || string(r.ID) != `"c"` {
+		t.Errorf("expected 404 for a missing todo, got %+v", r)
+	}
+	send(`{"id": "d", "type": "create", "todo": {"title": ""}}`)
+	if r := read(); r.Type != "error" || r.Status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty title, got %+v", r)
+	}
+	send(`{"id": "e", "type": "create", "todo": {"name": "x"}}`)
+	if r := read(); r.Type != "error" || r.Status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown field, got %+v", r)
+	}
+	send(`{"type": "rename"}`)
+	if r := read(); r.Type != "error" || r.Status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown command, got %+v", r)
+	}
+	send(`not json`)
+	if r := read(); r.Type != "error" || r.Status != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad JSON, got %+v", r)
+	}
+
+	send(`{"id": "f", "type": "delete", "todo_id": ` + strconv.Itoa(ack.Todo.ID) + `}`)
+	for i := 0; i < 2; i++ {
+		if r := read(); r.Type == "event" && (r.Event == nil || r.Event.Type != domain.EventTodoDeleted) {
+			t.Errorf("expected a todo.deleted event, got %+v", r)
+		} else if r.Type != "event" && (r.Type != "ack" || string(r.ID) != `"f"`) {
+			t.Errorf("expected the delete acked, got %+v", r)
+		}
+	}
+	if _, err := repo.GetByID(ctx, ack.Todo.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected the todo deleted, got %v", err)
+	}
+
+	// shutting down closes the session
+	broker.Close()
+	var cerr *websocket.CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &cerr) || cerr.Code != websocket.CloseGoingAway {
+		t.Errorf("expected a going away close, got %v", err)
+	}
+	waitCtx, cancelWait := context.WithTimeout(ctx, 2*time.Second)
+	defer cancelWait()
+	if err := handler.Wait(waitCtx); err != nil {
+		t.Errorf("expected the session to end, got %v", err)
+	}
+}
+
+func TestWSHandler_ChecksOrigin(t *testing.T) {
+	svc := service.NewTodoService(memory.NewTodoRepository())
+	broker := stream.NewBroker(stream.Options{})
+	defer broker.Close()
+
+	handler := NewWSHandler(svc, broker, logger.New("error", nil, "json"), 2*time.Second)
+	handler.SetAllowedOrigins([]string{"https://app.example.com"})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
 
-	if len(todos) != 0 {
-		t.Errorf("expected 0 todos, got %d", len(todos))
+	for origin, want := range map[string]int{
+		"https://app.example.com": http.StatusSwitchingProtocols,
+		"https://evil.example":    http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("origin %s: expected %d, got %d", origin, want, resp.StatusCode)
+		}
 	}
 }
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/yokitheyo/todo/internal/idempotency"
+)
+
+const maxIdempotencyKeyLength = 255
+
+type IdempotencyStore interface {
+	Begin(ctx context.Context, key, fingerprint string) (*idempotency.Response, error)
+	Finish(key string, resp idempotency.Response)
+	Abandon(key string)
+}
+
+// SetIdempotencyStore makes creating a todo honor the Idempotency-Key
+// header, remembering responses in store.
+func (h *TodoHandler) SetIdempotencyStore(store IdempotencyStore) {
+	h.idempotency = store
+}
+
+// idempotent runs next once per Idempotency-Key and replays its response to
+// retries with the same method, path and body. Responses with a 5xx status
+// are not kept, so the request can be retried.
+func (h *TodoHandler) idempotent(ctx context.Context, w http.ResponseWriter, r *http.Request, key string, next http.HandlerFunc) {
+	if len(key) > maxIdempotencyKeyLength {
+		h.respondError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		h.respondError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.New()
+	io.WriteString(sum, r.Method+" "+r.URL.Path+"\n")
+	sum.Write(body)
+	fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+	resp, err := h.idempotency.Begin(ctx, key, fingerprint)
+	switch {
+	case errors.Is(err, idempotency.ErrKeyReused):
+		h.respondError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return
+	case err != nil:
+		h.respondError(w, http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+		return
+	case resp != nil:
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(resp.Status)
+		w.Write(resp.Body)
+		return
+	}
+
+	rec := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	finished := false
+	defer func() {
+		if !finished {
+			h.idempotency.Abandon(key)
+		}
+	}()
+
+	next(rec, r)
+
+	if rec.status >= http.StatusInternalServerError {
+		return
+	}
+	h.idempotency.Finish(key, idempotency.Response{Status: rec.status, Header: rec.Header().Clone(), Body: rec.body.Bytes()})
+	finished = true
+}
+
+// recordingResponseWriter keeps a copy of the response it writes.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingResponseWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
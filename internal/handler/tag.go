@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+type TagService interface {
+	Create(ctx context.Context, input domain.CreateTagInput) (*domain.Tag, error)
+	GetByID(ctx context.Context, id int) (*domain.Tag, error)
+	List(ctx context.Context) ([]domain.Tag, error)
+	Update(ctx context.Context, id int, input domain.UpdateTagInput) (*domain.Tag, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type TagHandler struct {
+	base
+	service TagService
+}
+
+func NewTagHandler(service TagService, log *logger.Logger, timeout time.Duration) *TagHandler {
+	return &TagHandler{
+		base:    base{log: log, requestTimeout: timeout},
+		service: service,
+	}
+}
+
+func (h *TagHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/tags", h.loggingMiddleware(h.tagsHandler))
+	mux.HandleFunc("/tags/", h.loggingMiddleware(h.tagByIDHandler))
+}
+
+func (h *TagHandler) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodPost:
+		h.createTag(ctx, w, r)
+	case http.MethodGet:
+		h.listTags(ctx, w, r)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *TagHandler) tagByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	id, err := h.extractID(r.URL.Path, "/tags/")
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getTag(ctx, w, r, id)
+	case http.MethodPut:
+		h.updateTag(ctx, w, r, id)
+	case http.MethodDelete:
+		h.deleteTag(ctx, w, r, id)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *TagHandler) createTag(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var input domain.CreateTagInput
+	if err := h.decodeJSON(w, r, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	tag, err := h.service.Create(ctx, input)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, tag)
+}
+
+func (h *TagHandler) listTags(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+	tags, err := h.service.List(ctx)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, tags)
+}
+
+func (h *TagHandler) getTag(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
+	tag, err := h.service.GetByID(ctx, id)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, tag)
+}
+
+func (h *TagHandler) updateTag(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	var input domain.UpdateTagInput
+	if err := h.decodeJSON(w, r, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	tag, err := h.service.Update(ctx, id, input)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, tag)
+}
+
+func (h *TagHandler) deleteTag(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
+	if err := h.service.Delete(ctx, id); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TagHandler) handleServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrTagNotFound):
+		h.respondError(w, http.StatusNotFound, "tag not found")
+	case errors.Is(err, domain.ErrTagExists):
+		h.respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrInvalidTagName),
+		errors.Is(err, domain.ErrInvalidTagColor),
+		errors.Is(err, domain.ErrInvalidID):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.log.Error("service error", "error", err, "operation", "tag")
+		h.respondError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -4,46 +4,59 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/jsonpatch"
 	"github.com/yokitheyo/todo/pkg/logger"
 )
 
 type TodoService interface {
 	Create(ctx context.Context, input domain.CreateTodoInput) (*domain.Todo, error)
 	GetByID(ctx context.Context, id int) (*domain.Todo, error)
-	GetAll(ctx context.Context) ([]domain.Todo, error)
-	Update(ctx context.Context, id int, input domain.UpdateTodoInput) (*domain.Todo, error)
-	Delete(ctx context.Context, id int) error
-	GetFiltered(ctx context.Context, completed *bool, search string) ([]domain.Todo, error)
+	Replace(ctx context.Context, id int, input domain.ReplaceTodoInput) (*domain.Todo, error)
+	Patch(ctx context.Context, id int, patch domain.TodoPatch, version int) (*domain.Todo, error)
+	DeleteVersion(ctx context.Context, id int, children domain.ChildPolicy, version int) error
+	Subtree(ctx context.Context, id int) (*domain.TodoTree, error)
+	AddBlocker(ctx context.Context, id, blockerID int) (*domain.Todo, error)
+	RemoveBlocker(ctx context.Context, id, blockerID int) error
+	Blockers(ctx context.Context, id int) ([]domain.Todo, error)
+	Next(ctx context.Context, limit int) (*domain.TodoPage, error)
+	List(ctx context.Context, filter domain.TodoFilter, sort string, page domain.PageRequest) (*domain.TodoPage, error)
+	Batch(ctx context.Context, ops []domain.BatchOp, atomic bool) ([]domain.BatchResult, error)
+	CompleteMatching(ctx context.Context, filter domain.TodoFilter, atomic bool) ([]domain.BatchResult, error)
+	DeleteCompleted(ctx context.Context, filter domain.TodoFilter, children domain.ChildPolicy, atomic bool) ([]domain.BatchResult, error)
+	Trash(ctx context.Context) ([]domain.Todo, error)
+	Restore(ctx context.Context, id int) (*domain.Todo, error)
 }
 
 type TodoHandler struct {
-	service        TodoService
-	log            *logger.Logger
-	requestTimeout time.Duration
+	base
+	service     TodoService
+	idempotency IdempotencyStore
 }
 
 func NewTodoHandler(service TodoService, log *logger.Logger, timeout time.Duration) *TodoHandler {
 	return &TodoHandler{
-		service:        service,
-		log:            log,
-		requestTimeout: timeout,
+		base:    base{log: log, requestTimeout: timeout},
+		service: service,
 	}
 }
 
-type errorResponse struct {
-	Error string `json:"error"`
-}
-
 func (h *TodoHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/todos", h.loggingMiddleware(h.todosHandler))
 	mux.HandleFunc("/todos/", h.loggingMiddleware(h.todoByIDHandler))
+	mux.HandleFunc("/todos:batch", h.loggingMiddleware(h.batchHandler))
+	mux.HandleFunc("/todos:complete", h.loggingMiddleware(h.completeHandler))
+	mux.HandleFunc("/todos:delete-completed", h.loggingMiddleware(h.deleteCompletedHandler))
+	mux.HandleFunc("/trash", h.loggingMiddleware(h.trashHandler))
 	mux.HandleFunc("/health", h.healthHandler)
 }
 
@@ -53,13 +66,9 @@ func (h *TodoHandler) todosHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodPost:
-		h.createTodo(ctx, w, r)
+		h.createTodo(ctx, w, r, 0)
 	case http.MethodGet:
-		if r.URL.Query().Get("completed") != "" || r.URL.Query().Get("search") != "" {
-			h.getFilteredTodos(ctx, w, r)
-			return
-		}
-		h.getAllTodos(ctx, w, r)
+		h.listTodos(ctx, w, r, domain.TodoFilter{})
 	default:
 		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
 	}
@@ -69,17 +78,40 @@ func (h *TodoHandler) todoByIDHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
 	defer cancel()
 
-	id, err := h.extractID(r.URL.Path)
+	path, sub, nested := strings.Cut(strings.TrimPrefix(r.URL.Path, "/todos/"), "/")
+	if path == "next" && !nested {
+		h.nextTodos(ctx, w, r)
+		return
+	}
+
+	id, err := h.extractID(path, "")
 	if err != nil {
 		h.respondError(w, http.StatusBadRequest, "invalid todo id")
 		return
 	}
 
+	if nested {
+		resource, rest, _ := strings.Cut(strings.TrimSuffix(sub, "/"), "/")
+		switch {
+		case (resource == "children" || resource == "subtree") && rest == "":
+			h.subtaskHandler(ctx, w, r, id, resource)
+		case resource == "blockers":
+			h.blockersHandler(ctx, w, r, id, rest)
+		case resource == "restore" && rest == "":
+			h.restoreTodo(ctx, w, r, id)
+		default:
+			h.respondError(w, http.StatusNotFound, "not found")
+		}
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.getTodoByID(ctx, w, r, id)
 	case http.MethodPut:
-		h.updateTodo(ctx, w, r, id)
+		h.replaceTodo(ctx, w, r, id)
+	case http.MethodPatch:
+		h.patchTodo(ctx, w, r, id)
 	case http.MethodDelete:
 		h.deleteTodo(ctx, w, r, id)
 	default:
@@ -87,202 +119,439 @@ func (h *TodoHandler) todoByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *TodoHandler) createTodo(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	var input domain.CreateTodoInput
-	if err := h.decodeJSON(w, r, &input); err != nil {
-		h.handleRequestError(w, err)
+// subtaskHandler serves /todos/{id}/children, listed like /todos, and
+// /todos/{id}/subtree, the todo with its subtasks nested.
+func (h *TodoHandler) subtaskHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id int, sub string) {
+	if r.Method != http.MethodGet {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
-	todo, err := h.service.Create(ctx, input)
-	if err != nil {
-		h.handleServiceError(w, err)
+	if sub == "subtree" {
+		tree, err := h.service.Subtree(ctx, id)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		h.respondJSON(w, http.StatusOK, tree)
 		return
 	}
 
-	h.respondJSON(w, http.StatusCreated, todo)
+	if _, err := h.service.GetByID(ctx, id); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.listTodos(ctx, w, r, domain.TodoFilter{ParentID: &id})
 }
 
-func (h *TodoHandler) getAllTodos(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
-	todos, err := h.service.GetAll(ctx)
-	if err != nil {
-		h.log.Error("failed to get todos", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "internal server error")
+// blockersHandler serves /todos/{id}/blockers, the todos id waits for, and
+// /todos/{id}/blockers/{blocker_id} to remove one of them.
+func (h *TodoHandler) blockersHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id int, blocker string) {
+	if blocker != "" {
+		blockerID, err := h.extractID(blocker, "")
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid blocker id")
+			return
+		}
+		if r.Method != http.MethodDelete {
+			h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if err := h.service.RemoveBlocker(ctx, id, blockerID); err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, todos)
+	switch r.Method {
+	case http.MethodGet:
+		blockers, err := h.service.Blockers(ctx, id)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		h.respondJSON(w, http.StatusOK, blockers)
+	case http.MethodPost:
+		var input domain.AddBlockerInput
+		if err := h.decodeJSON(w, r, &input); err != nil {
+			h.handleRequestError(w, err)
+			return
+		}
+		todo, err := h.service.AddBlocker(ctx, id, input.BlockerID)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		h.respondJSON(w, http.StatusCreated, todo)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
 }
 
-func (h *TodoHandler) getTodoByID(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
-	todo, err := h.service.GetByID(ctx, id)
+// nextTodos serves /todos/next, the open todos in an order they can be done.
+func (h *TodoHandler) nextTodos(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var limit int
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	todos, err := h.service.Next(ctx, limit)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, todo)
+	h.respondJSON(w, http.StatusOK, todos)
+}
+
+// createTodo creates a todo in list listID, or in the list named by the
+// body when listID is 0.
+func (h *TodoHandler) createTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, listID int) {
+	if key := r.Header.Get("Idempotency-Key"); key != "" && h.idempotency != nil {
+		h.idempotent(ctx, w, r, key, func(w http.ResponseWriter, r *http.Request) {
+			h.create(ctx, w, r, listID)
+		})
+		return
+	}
+	h.create(ctx, w, r, listID)
 }
 
-func (h *TodoHandler) updateTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
-	var input domain.UpdateTodoInput
+func (h *TodoHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request, listID int) {
+	var input domain.CreateTodoInput
 	if err := h.decodeJSON(w, r, &input); err != nil {
 		h.handleRequestError(w, err)
 		return
 	}
+	if listID != 0 {
+		input.ListID = listID
+	}
 
-	todo, err := h.service.Update(ctx, id, input)
+	todo, err := h.service.Create(ctx, input)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, todo)
+	w.Header().Set("ETag", todoETag(todo))
+	h.respondJSON(w, http.StatusCreated, todo)
 }
 
-func (h *TodoHandler) deleteTodo(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
-	err := h.service.Delete(ctx, id)
+func (h *TodoHandler) getTodoByID(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	todo, err := h.service.GetByID(ctx, id)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (h *TodoHandler) healthHandler(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-}
-
-func (h *TodoHandler) handleRequestError(w http.ResponseWriter, err error) {
-	var syntaxErr *json.SyntaxError
-	var unmarshalTypeErr *json.UnmarshalTypeError
-
-	switch {
-	case errors.As(err, &syntaxErr):
-		h.respondError(w, http.StatusBadRequest, "malformed JSON at position "+strconv.Itoa(int(syntaxErr.Offset)))
-	case errors.As(err, &unmarshalTypeErr):
-		h.respondError(w, http.StatusBadRequest, "invalid value for field "+unmarshalTypeErr.Field)
-	case errors.Is(err, io.EOF):
-		h.respondError(w, http.StatusBadRequest, "empty request body")
-	default:
-		h.respondError(w, http.StatusBadRequest, "invalid request body")
+	etag := todoETag(todo)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matchETag(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
+	h.respondJSON(w, http.StatusOK, todo)
 }
 
-func (h *TodoHandler) getFilteredTodos(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	completedStr := query.Get("completed")
-	search := query.Get("search")
+// replaceTodo serves PUT, which replaces every writable field. The body may
+// be a todo as returned by GET; its read-only fields are ignored.
+func (h *TodoHandler) replaceTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	var doc map[string]json.RawMessage
+	if err := h.decodeJSON(w, r, &doc); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+	for _, field := range domain.ReadOnlyTodoFields {
+		delete(doc, field)
+	}
+	// re-encoding decoded raw messages cannot fail
+	data, _ := json.Marshal(doc)
+	var input domain.ReplaceTodoInput
+	if err := strictUnmarshal(data, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
 
-	var completed *bool
-	if completedStr != "" {
-		b := completedStr == "true"
-		completed = &b
+	version, ok := h.checkIfMatch(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	if input.Version == nil && version != 0 {
+		input.Version = &version
 	}
 
-	todos, err := h.service.GetFiltered(ctx, completed, search)
+	todo, err := h.service.Replace(ctx, id, input)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, todos)
+	w.Header().Set("ETag", todoETag(todo))
+	h.respondJSON(w, http.StatusOK, todo)
 }
 
-func (h *TodoHandler) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(v); err != nil {
-		return err
+const acceptPatch = "application/merge-patch+json, application/json-patch+json"
+
+// patchTodo serves PATCH with a JSON Merge Patch or a JSON Patch, applied to
+// the todo as GET returns it.
+func (h *TodoHandler) patchTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json-patch+json" {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		h.respondError(w, http.StatusUnsupportedMediaType, "PATCH needs Content-Type "+acceptPatch)
+		return
 	}
-	if err := dec.Decode(&struct{}{}); err != io.EOF {
-		return errors.New("request body must contain a single JSON object")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		h.respondError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
 	}
-	return nil
-}
 
-func (h *TodoHandler) extractID(path string) (int, error) {
-	path = strings.TrimPrefix(path, "/todos/")
-	path = strings.TrimSuffix(path, "/")
+	var patch domain.TodoPatch
+	if mediaType == "application/merge-patch+json" {
+		if !json.Valid(body) {
+			h.respondError(w, http.StatusBadRequest, "invalid merge patch")
+			return
+		}
+		patch = func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, body)
+		}
+	} else {
+		ops, err := jsonpatch.Decode(body)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid JSON patch: "+err.Error())
+			return
+		}
+		patch = func(doc []byte) ([]byte, error) {
+			out, err := ops.Apply(doc)
+			if errors.Is(err, jsonpatch.ErrTestFailed) {
+				return nil, fmt.Errorf("%w: %v", domain.ErrPatchTestFailed, err)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPatch, err)
+			}
+			return out, nil
+		}
+	}
 
-	if path == "" {
-		return 0, domain.ErrInvalidPath
+	version, ok := h.checkIfMatch(ctx, w, r, id)
+	if !ok {
+		return
 	}
 
-	id, err := strconv.Atoi(path)
-	if err != nil || id <= 0 {
-		return 0, domain.ErrInvalidID
+	todo, err := h.service.Patch(ctx, id, patch, version)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
 	}
 
-	return id, nil
+	w.Header().Set("ETag", todoETag(todo))
+	h.respondJSON(w, http.StatusOK, todo)
 }
 
-func (h *TodoHandler) handleServiceError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, domain.ErrTodoNotFound):
-		h.respondError(w, http.StatusNotFound, "todo not found")
-	case errors.Is(err, domain.ErrTitleRequired),
-		errors.Is(err, domain.ErrTitleTooLong),
-		errors.Is(err, domain.ErrDescriptionTooLong),
-		errors.Is(err, domain.ErrInvalidID):
-		h.respondError(w, http.StatusBadRequest, err.Error())
-	default:
-		h.log.Error("service error", "error", err, "operation", "unknown")
-		h.respondError(w, http.StatusInternalServerError, "internal server error")
+func (h *TodoHandler) deleteTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	version, ok := h.checkIfMatch(ctx, w, r, id)
+	if !ok {
+		return
 	}
+
+	err := h.service.DeleteVersion(ctx, id, domain.ChildPolicy(r.URL.Query().Get("children")), version)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *TodoHandler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+// checkIfMatch evaluates the If-Match header of a write to todo id and
+// returns the version the write has to apply to, 0 without the header. The
+// service then fails the write if the todo changes after the check.
+func (h *TodoHandler) checkIfMatch(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) (int, bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return 0, true
+	}
 
-	if data != nil {
-		if err := json.NewEncoder(w).Encode(data); err != nil {
-			h.log.Error("failed to encode response", "error", err)
-		}
+	current, err := h.service.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrTodoNotFound) {
+		h.respondError(w, http.StatusPreconditionFailed, "todo not found")
+		return 0, false
 	}
+	if err != nil {
+		h.handleServiceError(w, err)
+		return 0, false
+	}
+	if !matchETag(ifMatch, todoETag(current), false) {
+		h.handleServiceError(w, domain.ErrVersionMismatch)
+		return 0, false
+	}
+	return current.Version, true
 }
 
-func (h *TodoHandler) respondError(w http.ResponseWriter, status int, message string) {
-	h.respondJSON(w, status, errorResponse{Error: message})
+func (h *TodoHandler) healthHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
 }
 
-func (h *TodoHandler) loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// listTodos lists todos matching the query parameters within scope, whose
+// ListID and ParentID take the place of the list_id and parent_id parameters.
+func (h *TodoHandler) listTodos(ctx context.Context, w http.ResponseWriter, r *http.Request, scope domain.TodoFilter) {
+	query := r.URL.Query()
 
-		userAgent := r.UserAgent()
-		sw := &statusResponseWriter{
-			ResponseWriter: w,
-			status:         http.StatusOK,
+	filter, err := parseTodoFilter(query, scope)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page := domain.PageRequest{Cursor: query.Get("cursor")}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid limit")
+			return
 		}
+		page.Limit = limit
+	}
 
-		h.log.Info("incoming request",
-			"method", r.Method,
-			"path", r.URL.Path,
-			"remote", r.RemoteAddr,
-			"user_agent", userAgent,
-		)
+	todos, err := h.service.List(ctx, filter, query.Get("sort"), page)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
 
-		next(sw, r)
+	h.respondJSON(w, http.StatusOK, todos)
+}
 
-		h.log.Info("request completed",
-			"method", r.Method,
-			"path", r.URL.Path,
-			"status", sw.status,
-			"duration", time.Since(start),
-		)
+// parseTodoFilter reads the filter parameters of a todo listing; see
+// listTodos for scope.
+func parseTodoFilter(query url.Values, scope domain.TodoFilter) (domain.TodoFilter, error) {
+	filter := domain.TodoFilter{Search: query.Get("search"), ListID: scope.ListID, ParentID: scope.ParentID}
+	if completedStr := query.Get("completed"); completedStr != "" {
+		b := completedStr == "true"
+		filter.Completed = &b
+	}
+	if overdueStr := query.Get("overdue"); overdueStr != "" {
+		b := overdueStr == "true"
+		filter.Overdue = &b
+	}
+	filter.Tags = query["tag"]
+	filter.TagMatch = domain.TagMatch(query.Get("tag_match"))
+	if priorityStr := query.Get("priority"); priorityStr != "" {
+		for _, p := range strings.Split(priorityStr, ",") {
+			filter.Priorities = append(filter.Priorities, domain.Priority(strings.TrimSpace(p)))
+		}
+	}
+	if beforeStr := query.Get("due_before"); beforeStr != "" {
+		t, err := domain.ParseTime(beforeStr)
+		if err != nil {
+			return filter, errors.New("invalid due_before")
+		}
+		filter.DueBefore = &t
+	}
+	if afterStr := query.Get("due_after"); afterStr != "" {
+		t, err := domain.ParseTime(afterStr)
+		if err != nil {
+			return filter, errors.New("invalid due_after")
+		}
+		filter.DueAfter = &t
+	}
+	if listStr := query.Get("list_id"); listStr != "" && filter.ListID == nil {
+		id, err := strconv.Atoi(listStr)
+		if err != nil {
+			return filter, errors.New("invalid list_id")
+		}
+		filter.ListID = &id
+	}
+	if parentStr := query.Get("parent_id"); parentStr != "" && filter.ParentID == nil {
+		id, err := strconv.Atoi(parentStr)
+		if err != nil {
+			return filter, errors.New("invalid parent_id")
+		}
+		filter.ParentID = &id
+	}
+	if exprStr := query.Get("filter"); strings.TrimSpace(exprStr) != "" {
+		expr, err := domain.ParseFilter(exprStr)
+		if err != nil {
+			return filter, err
+		}
+		filter.Expr = expr
 	}
+	return filter, nil
 }
 
-type statusResponseWriter struct {
-	http.ResponseWriter
-	status int
+func (h *TodoHandler) handleServiceError(w http.ResponseWriter, err error) {
+	status, msg := todoErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		h.log.Error("service error", "error", err, "operation", "unknown")
+	}
+	h.respondError(w, status, msg)
 }
 
-func (w *statusResponseWriter) WriteHeader(code int) {
-	w.status = code
-	w.ResponseWriter.WriteHeader(code)
+// todoErrorStatus maps an error from TodoService to a status and a message
+// fit for the client.
+func todoErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, domain.ErrTodoNotFound):
+		return http.StatusNotFound, "todo not found"
+	case errors.Is(err, domain.ErrDependencyNotFound):
+		return http.StatusNotFound, "dependency not found"
+	case errors.Is(err, domain.ErrDependencyExists),
+		errors.Is(err, domain.ErrTodoBlocked):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, domain.ErrPatchTestFailed):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, domain.ErrReadOnlyField):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, domain.ErrParentDeleted):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, domain.ErrHasChildren):
+		return http.StatusConflict, "todo has subtasks, delete with children=cascade or children=orphan"
+	case errors.Is(err, domain.ErrTitleRequired),
+		errors.Is(err, domain.ErrTitleTooLong),
+		errors.Is(err, domain.ErrDescriptionTooLong),
+		errors.Is(err, domain.ErrInvalidPriority),
+		errors.Is(err, domain.ErrInvalidDueAt),
+		errors.Is(err, domain.ErrDueAtConflict),
+		errors.Is(err, domain.ErrInvalidRemindAt),
+		errors.Is(err, domain.ErrRemindAtConflict),
+		errors.Is(err, domain.ErrUnknownTag),
+		errors.Is(err, domain.ErrInvalidTagName),
+		errors.Is(err, domain.ErrInvalidTagMatch),
+		errors.Is(err, domain.ErrUnknownList),
+		errors.Is(err, domain.ErrUnknownParent),
+		errors.Is(err, domain.ErrParentCycle),
+		errors.Is(err, domain.ErrDependencyCycle),
+		errors.Is(err, domain.ErrInvalidChildPolicy),
+		errors.Is(err, domain.ErrInvalidRecurrence),
+		errors.Is(err, domain.ErrRecurrenceNeedsDueAt),
+		errors.Is(err, domain.ErrInvalidID),
+		errors.Is(err, domain.ErrInvalidVersion),
+		errors.Is(err, domain.ErrInvalidPatch),
+		errors.Is(err, domain.ErrInvalidLimit),
+		errors.Is(err, domain.ErrInvalidCursor),
+		errors.Is(err, domain.ErrInvalidSort),
+		errors.Is(err, domain.ErrEmptyBatch),
+		errors.Is(err, domain.ErrBatchTooLarge),
+		errors.Is(err, domain.ErrInvalidBatchOp):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, domain.ErrTransactionsUnsupported):
+		return http.StatusNotImplemented, err.Error()
+	default:
+		return http.StatusInternalServerError, "internal server error"
+	}
 }
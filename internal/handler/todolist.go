@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+type ListService interface {
+	Create(ctx context.Context, input domain.CreateListInput) (*domain.TodoList, error)
+	GetByID(ctx context.Context, id int) (*domain.TodoList, error)
+	List(ctx context.Context) ([]domain.TodoList, error)
+	Update(ctx context.Context, id int, input domain.UpdateListInput) (*domain.TodoList, error)
+	Delete(ctx context.Context, id int, cascade bool) error
+}
+
+// ListHandler serves /lists and hands /lists/{id}/todos to the todo handler.
+type ListHandler struct {
+	base
+	service ListService
+	todos   *TodoHandler
+}
+
+func NewListHandler(service ListService, todos *TodoHandler, log *logger.Logger, timeout time.Duration) *ListHandler {
+	return &ListHandler{
+		base:    base{log: log, requestTimeout: timeout},
+		service: service,
+		todos:   todos,
+	}
+}
+
+func (h *ListHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/lists", h.loggingMiddleware(h.listsHandler))
+	mux.HandleFunc("/lists/", h.loggingMiddleware(h.listByIDHandler))
+}
+
+func (h *ListHandler) listsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodPost:
+		h.createList(ctx, w, r)
+	case http.MethodGet:
+		h.listLists(ctx, w, r)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *ListHandler) listByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	path, sub, nested := strings.Cut(strings.TrimPrefix(r.URL.Path, "/lists/"), "/")
+	id, err := h.extractID(path, "")
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid list id")
+		return
+	}
+
+	if nested {
+		if strings.TrimSuffix(sub, "/") != "todos" {
+			h.respondError(w, http.StatusNotFound, "not found")
+			return
+		}
+		h.listTodosHandler(ctx, w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getList(ctx, w, r, id)
+	case http.MethodPut:
+		h.updateList(ctx, w, r, id)
+	case http.MethodDelete:
+		h.deleteList(ctx, w, r, id)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// listTodosHandler serves /lists/{id}/todos like /todos, scoped to the list.
+func (h *ListHandler) listTodosHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if _, err := h.service.GetByID(ctx, id); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		h.todos.createTodo(ctx, w, r, id)
+		return
+	}
+	h.todos.listTodos(ctx, w, r, domain.TodoFilter{ListID: &id})
+}
+
+func (h *ListHandler) createList(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var input domain.CreateListInput
+	if err := h.decodeJSON(w, r, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	list, err := h.service.Create(ctx, input)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, list)
+}
+
+func (h *ListHandler) listLists(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+	lists, err := h.service.List(ctx)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, lists)
+}
+
+func (h *ListHandler) getList(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
+	list, err := h.service.GetByID(ctx, id)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, list)
+}
+
+func (h *ListHandler) updateList(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	var input domain.UpdateListInput
+	if err := h.decodeJSON(w, r, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	list, err := h.service.Update(ctx, id, input)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, list)
+}
+
+func (h *ListHandler) deleteList(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	var cascade bool
+	if cascadeStr := r.URL.Query().Get("cascade"); cascadeStr != "" {
+		b, err := strconv.ParseBool(cascadeStr)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid cascade")
+			return
+		}
+		cascade = b
+	}
+
+	if err := h.service.Delete(ctx, id, cascade); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ListHandler) handleServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrListNotFound):
+		h.respondError(w, http.StatusNotFound, "list not found")
+	case errors.Is(err, domain.ErrListNotEmpty):
+		h.respondError(w, http.StatusConflict, "list still contains todos, delete with cascade=true to delete them too")
+	case errors.Is(err, domain.ErrListNameRequired),
+		errors.Is(err, domain.ErrListNameTooLong),
+		errors.Is(err, domain.ErrDescriptionTooLong),
+		errors.Is(err, domain.ErrInvalidID):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.log.Error("service error", "error", err, "operation", "list")
+		h.respondError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// trashHandler serves GET /trash, the deleted todos that were not purged
+// yet, most recently deleted first.
+func (h *TodoHandler) trashHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	todos, err := h.service.Trash(ctx)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, todos)
+}
+
+// restoreTodo serves POST /todos/{id}/restore, which takes a deleted todo
+// out of the trash.
+func (h *TodoHandler) restoreTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	todo, err := h.service.Restore(ctx, id)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", todoETag(todo))
+	h.respondJSON(w, http.StatusOK, todo)
+}
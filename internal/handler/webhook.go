@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+type WebhookService interface {
+	Create(ctx context.Context, input domain.CreateWebhookInput) (*domain.Webhook, error)
+	GetByID(ctx context.Context, id int) (*domain.Webhook, error)
+	List(ctx context.Context) ([]domain.Webhook, error)
+	Update(ctx context.Context, id int, input domain.UpdateWebhookInput) (*domain.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, id int, status domain.DeliveryStatus) ([]domain.WebhookDelivery, error)
+	DeadLetters(ctx context.Context) ([]domain.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	base
+	service WebhookService
+}
+
+func NewWebhookHandler(service WebhookService, log *logger.Logger, timeout time.Duration) *WebhookHandler {
+	return &WebhookHandler{
+		base:    base{log: log, requestTimeout: timeout},
+		service: service,
+	}
+}
+
+func (h *WebhookHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/webhooks", h.loggingMiddleware(h.webhooksHandler))
+	mux.HandleFunc("/webhooks/", h.loggingMiddleware(h.webhookByIDHandler))
+}
+
+func (h *WebhookHandler) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodPost:
+		h.createWebhook(ctx, w, r)
+	case http.MethodGet:
+		h.listWebhooks(ctx, w, r)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// webhookByIDHandler serves /webhooks/{id}, /webhooks/{id}/deliveries and
+// /webhooks/dead-letters.
+func (h *WebhookHandler) webhookByIDHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	rest := strings.TrimPrefix(r.URL.Path, "/webhooks/")
+	if strings.TrimSuffix(rest, "/") == "dead-letters" {
+		if r.Method != http.MethodGet {
+			h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.listDeadLetters(ctx, w, r)
+		return
+	}
+
+	path, sub, nested := strings.Cut(rest, "/")
+	id, err := h.extractID(path, "")
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	if nested {
+		if strings.TrimSuffix(sub, "/") != "deliveries" {
+			h.respondError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if r.Method != http.MethodGet {
+			h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.listDeliveries(ctx, w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getWebhook(ctx, w, r, id)
+	case http.MethodPut:
+		h.updateWebhook(ctx, w, r, id)
+	case http.MethodDelete:
+		h.deleteWebhook(ctx, w, r, id)
+	default:
+		h.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *WebhookHandler) createWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var input domain.CreateWebhookInput
+	if err := h.decodeJSON(w, r, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	hook, err := h.service.Create(ctx, input)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, hook)
+}
+
+func (h *WebhookHandler) listWebhooks(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+	hooks, err := h.service.List(ctx)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, hooks)
+}
+
+func (h *WebhookHandler) getWebhook(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
+	hook, err := h.service.GetByID(ctx, id)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, hook)
+}
+
+func (h *WebhookHandler) updateWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	var input domain.UpdateWebhookInput
+	if err := h.decodeJSON(w, r, &input); err != nil {
+		h.handleRequestError(w, err)
+		return
+	}
+
+	hook, err := h.service.Update(ctx, id, input)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, hook)
+}
+
+func (h *WebhookHandler) deleteWebhook(ctx context.Context, w http.ResponseWriter, _ *http.Request, id int) {
+	if err := h.service.Delete(ctx, id); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) listDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) {
+	status := domain.DeliveryStatus(r.URL.Query().Get("status"))
+
+	deliveries, err := h.service.Deliveries(ctx, id, status)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) listDeadLetters(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+	deliveries, err := h.service.DeadLetters(ctx)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) handleServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		h.respondError(w, http.StatusNotFound, "webhook not found")
+	case errors.Is(err, domain.ErrInvalidWebhookURL),
+		errors.Is(err, domain.ErrWebhookURLNotPublic),
+		errors.Is(err, domain.ErrWebhookSecret),
+		errors.Is(err, domain.ErrInvalidEventType),
+		errors.Is(err, domain.ErrInvalidDeliveryStatus),
+		errors.Is(err, domain.ErrInvalidID):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.log.Error("service error", "error", err, "operation", "webhook")
+		h.respondError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/stream"
+	"github.com/yokitheyo/todo/internal/websocket"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+// TodoCommandService is the part of TodoService WebSocket clients can use.
+type TodoCommandService interface {
+	Create(ctx context.Context, input domain.CreateTodoInput) (*domain.Todo, error)
+	Update(ctx context.Context, id int, input domain.UpdateTodoInput) (*domain.Todo, error)
+	DeleteVersion(ctx context.Context, id int, children domain.ChildPolicy, version int) error
+}
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsCloseTimeout = 5 * time.Second
+)
+
+// WSHandler serves /ws, where clients subscribe to lists, receive their
+// todo events and send commands.
+type WSHandler struct {
+	base
+	service      TodoCommandService
+	stream       EventStream
+	pingInterval time.Duration
+	upgrader     websocket.Upgrader
+
+	sessions sync.WaitGroup
+}
+
+func NewWSHandler(service TodoCommandService, stream EventStream, log *logger.Logger, timeout time.Duration) *WSHandler {
+	return &WSHandler{
+		base:         base{log: log, requestTimeout: timeout},
+		service:      service,
+		stream:       stream,
+		pingInterval: wsPingInterval,
+	}
+}
+
+// SetAllowedOrigins lets pages from origins, besides the server's own, open
+// connections.
+func (h *WSHandler) SetAllowedOrigins(origins []string) {
+	h.upgrader.AllowedOrigins = origins
+}
+
+func (h *WSHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/ws", h.loggingMiddleware(h.wsHandler))
+}
+
+// Wait blocks until every session has ended. Sessions end once the event
+// stream is closed, which server.Shutdown does not do for them: it does not
+// track upgraded connections.
+func (h *WSHandler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.sessions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wsCommand is a message from a client. ID is echoed in the reply and may be
+// any JSON value.
+type wsCommand struct {
+	ID       json.RawMessage    `json:"id,omitempty"`
+	Type     string             `json:"type"`
+	ListIDs  []int              `json:"list_ids,omitempty"`
+	TodoID   int                `json:"todo_id,omitempty"`
+	Todo     json.RawMessage    `json:"todo,omitempty"`
+	Children domain.ChildPolicy `json:"children,omitempty"`
+	// Version makes a delete conditional, like the version of an update.
+	Version int `json:"version,omitempty"`
+}
+
+// wsReply is a message to a client: an ack or error for a command, or an
+// event.
+type wsReply struct {
+	Type    string            `json:"type"`
+	ID      json.RawMessage   `json:"id,omitempty"`
+	Todo    *domain.Todo      `json:"todo,omitempty"`
+	ListIDs []int             `json:"list_ids,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	EventID string            `json:"event_id,omitempty"`
+	Event   *domain.TodoEvent `json:"event,omitempty"`
+}
+
+// wsSession is one client connection and the lists it subscribed to.
+type wsSession struct {
+	h    *WSHandler
+	conn *websocket.Conn
+
+	mu    sync.Mutex
+	lists map[int]bool
+}
+
+func (h *WSHandler) wsHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r)
+	if err != nil {
+		h.log.Debug("websocket handshake failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	conn.ReadTimeout = 2 * h.pingInterval
+	conn.WriteTimeout = wsWriteTimeout
+
+	h.sessions.Add(1)
+	defer h.sessions.Done()
+
+	s := &wsSession{h: h, conn: conn, lists: map[int]bool{}}
+	sub := h.stream.Subscribe("")
+	defer sub.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.pump(sub, done)
+
+	for {
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if typ != websocket.TextMessage {
+			s.send(wsReply{Type: "error", Status: http.StatusBadRequest, Error: "commands must be JSON text messages"})
+			continue
+		}
+		s.handle(r.Context(), msg)
+	}
+}
+
+// pump sends the events of subscribed lists and keeps the connection alive
+// until done. When the stream ends, on shutdown or because the client fell
+// behind, it closes the connection; the client reconnects and reloads.
+func (s *wsSession) pump(sub *stream.Subscription, done <-chan struct{}) {
+	ping := time.NewTicker(s.h.pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				s.conn.CloseGracefully(websocket.CloseGoingAway, "event stream closed", wsCloseTimeout)
+				return
+			}
+			// a todo moved between lists shows up in both
+			if e.Matches(s.subscribed) {
+				s.send(wsReply{Type: "event", EventID: e.ID, Event: &e.TodoEvent})
+			}
+		case <-ping.C:
+			s.conn.Ping()
+		}
+	}
+}
+
+func (s *wsSession) handle(ctx context.Context, msg []byte) {
+	var cmd wsCommand
+	if err := strictUnmarshal(msg, &cmd); err != nil {
+		s.send(wsReply{Type: "error", Status: http.StatusBadRequest, Error: "invalid command: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.h.requestTimeout)
+	defer cancel()
+
+	reply, err := s.run(ctx, cmd)
+	if err != nil {
+		status, text := todoErrorStatus(err)
+		var bad *wsBadCommand
+		if errors.As(err, &bad) {
+			status, text = http.StatusBadRequest, bad.Error()
+		} else if status == http.StatusInternalServerError {
+			s.h.log.Error("service error", "error", err, "operation", "ws_"+cmd.Type)
+		}
+		reply = wsReply{Type: "error", Status: status, Error: text}
+	}
+	reply.ID = cmd.ID
+	s.send(reply)
+}
+
+// wsBadCommand is a command that is malformed before it reaches the service.
+type wsBadCommand struct{ msg string }
+
+func (e *wsBadCommand) Error() string { return e.msg }
+
+func (s *wsSession) run(ctx context.Context, cmd wsCommand) (wsReply, error) {
+	switch cmd.Type {
+	case "subscribe", "unsubscribe":
+		for _, id := range cmd.ListIDs {
+			if id < 0 {
+				return wsReply{}, &wsBadCommand{"invalid list id"}
+			}
+		}
+		return wsReply{Type: "ack", ListIDs: s.subscribe(cmd.ListIDs, cmd.Type == "subscribe")}, nil
+
+	case "create":
+		var input domain.CreateTodoInput
+		if err := decodeTodo(cmd.Todo, &input); err != nil {
+			return wsReply{}, err
+		}
+		todo, err := s.h.service.Create(ctx, input)
+		if err != nil {
+			return wsReply{}, err
+		}
+		return wsReply{Type: "ack", Todo: todo}, nil
+
+	case "update":
+		var input domain.UpdateTodoInput
+		if err := decodeTodo(cmd.Todo, &input); err != nil {
+			return wsReply{}, err
+		}
+		todo, err := s.h.service.Update(ctx, cmd.TodoID, input)
+		if err != nil {
+			return wsReply{}, err
+		}
+		return wsReply{Type: "ack", Todo: todo}, nil
+
+	case "delete":
+		if err := s.h.service.DeleteVersion(ctx, cmd.TodoID, cmd.Children, cmd.Version); err != nil {
+			return wsReply{}, err
+		}
+		return wsReply{Type: "ack"}, nil
+
+	default:
+		return wsReply{}, &wsBadCommand{fmt.Sprintf("unknown command type %q", cmd.Type)}
+	}
+}
+
+// subscribe adds or removes lists and returns the subscribed ones.
+func (s *wsSession) subscribe(ids []int, add bool) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if add {
+			s.lists[id] = true
+		} else {
+			delete(s.lists, id)
+		}
+	}
+
+	lists := make([]int, 0, len(s.lists))
+	for id := range s.lists {
+		lists = append(lists, id)
+	}
+	sort.Ints(lists)
+	return lists
+}
+
+func (s *wsSession) subscribed(todo domain.Todo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists[todo.ListID]
+}
+
+func (s *wsSession) send(reply wsReply) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		s.h.log.Error("failed to encode websocket reply", "error", err)
+		return
+	}
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil && !errors.Is(err, websocket.ErrClosed) {
+		// ends the session through ReadMessage
+		s.conn.Close()
+	}
+}
+
+func decodeTodo(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return &wsBadCommand{"todo is required"}
+	}
+	if err := strictUnmarshal(raw, v); err != nil {
+		return &wsBadCommand{"invalid todo: " + err.Error()}
+	}
+	return nil
+}
+
+// strictUnmarshal decodes like decodeJSON, rejecting unknown fields.
+func strictUnmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data")
+	}
+	return nil
+}
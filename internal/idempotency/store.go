@@ -0,0 +1,142 @@
+// Package idempotency remembers the responses to requests sent with an
+// Idempotency-Key, so clients can retry them without repeating their effect.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrKeyReused is returned for a key that was used for a different request.
+var ErrKeyReused = errors.New("idempotency key was already used for a different request")
+
+// Response is a stored response, replayed to retries.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type Options struct {
+	// TTL is how long a key is remembered after its request finished.
+	TTL time.Duration
+}
+
+// Store keeps keys in memory, so they are forgotten on restart.
+type Store struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+}
+
+type entry struct {
+	fingerprint string
+	// done is closed once the request finished or was abandoned.
+	done     chan struct{}
+	response *Response
+	expires  time.Time
+}
+
+func NewStore(opts Options) *Store {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	return &Store{ttl: opts.TTL, now: time.Now, entries: make(map[string]*entry)}
+}
+
+// Begin claims key for the request identified by fingerprint and returns
+// nil; the caller then runs the request and must call Finish or Abandon.
+// If the key was already used for the same request it returns that
+// request's response instead, waiting while the request is still in flight,
+// and if it was used for another request, ErrKeyReused.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		s.sweep(now)
+
+		e, exists := s.entries[key]
+		if exists && e.response != nil && !now.Before(e.expires) {
+			delete(s.entries, key)
+			exists = false
+		}
+		if !exists {
+			s.entries[key] = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.mu.Unlock()
+			return nil, nil
+		}
+		if e.fingerprint != fingerprint {
+			s.mu.Unlock()
+			return nil, ErrKeyReused
+		}
+		if e.response != nil {
+			resp := *e.response
+			s.mu.Unlock()
+			return &resp, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-e.done:
+			// look again: finished, or abandoned and free to claim
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Finish stores the response to the request that claimed key.
+func (s *Store) Finish(key string, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, exists := s.entries[key]
+	if !exists || e.response != nil {
+		return
+	}
+	e.response = &resp
+	e.expires = s.now().Add(s.ttl)
+	close(e.done)
+}
+
+// Abandon forgets key without a response, so a retry runs the request
+// again. It is meant for requests that failed in a way worth retrying.
+func (s *Store) Abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, exists := s.entries[key]
+	if !exists || e.response != nil {
+		return
+	}
+	delete(s.entries, key)
+	close(e.done)
+}
+
+// Len reports how many keys are remembered or in flight.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// sweep drops expired keys, at most once a minute or TTL.
+func (s *Store) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(min(s.ttl, time.Minute))
+
+	for key, e := range s.entries {
+		if e.response != nil && !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStore_ReplaysAndRejectsReuse(t *testing.T) {
+	s := NewStore(Options{})
+	ctx := context.Background()
+
+	resp, err := s.Begin(ctx, "k", "a")
+	if resp != nil || err != nil {
+		t.Fatalf("expected to claim a new key, got %v, %v", resp, err)
+	}
+	s.Finish("k", Response{Status: 201, Body: []byte("created")})
+
+	resp, err = s.Begin(ctx, "k", "a")
+	if err != nil || resp == nil || resp.Status != 201 || string(resp.Body) != "created" {
+		t.Fatalf("expected the stored response, got %v, %v", resp, err)
+	}
+	if _, err := s.Begin(ctx, "k", "b"); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("expected ErrKeyReused for another request, got %v", err)
+	}
+}
+
+func TestStore_WaitsForInFlightRequest(t *testing.T) {
+	s := NewStore(Options{})
+	ctx := context.Background()
+
+	if resp, err := s.Begin(ctx, "k", "a"); resp != nil || err != nil {
+		t.Fatalf("expected to claim a new key, got %v, %v", resp, err)
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	results := make(chan *Response, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.Begin(ctx, "k", "a")
+			if err != nil {
+				t.Errorf("Begin failed: %v", err)
+			}
+			results <- resp
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	s.Finish("k", Response{Status: 201})
+	wg.Wait()
+	close(results)
+
+	for resp := range results {
+		if resp == nil || resp.Status != 201 {
+			t.Errorf("expected every duplicate to get the response, got %v", resp)
+		}
+	}
+
+	if _, err := s.Begin(ctx, "other", "a"); err != nil {
+		t.Fatal(err)
+	}
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Begin(short, "other", "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected to give up waiting with the context, got %v", err)
+	}
+}
+
+func TestStore_AbandonFreesKey(t *testing.T) {
+	s := NewStore(Options{})
+	ctx := context.Background()
+
+	s.Begin(ctx, "k", "a")
+	claimed := make(chan *Response, 1)
+	go func() {
+		resp, _ := s.Begin(ctx, "k", "a")
+		claimed <- resp
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Abandon("k")
+	if resp := <-claimed; resp != nil {
+		t.Errorf("expected the waiting retry to claim the key, got %v", resp)
+	}
+}
+
+func TestStore_KeysExpire(t *testing.T) {
+	s := NewStore(Options{TTL: time.Hour})
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	s.Begin(ctx, "k", "a")
+	s.Finish("k", Response{Status: 201})
+	s.Begin(ctx, "in-flight", "a")
+
+	now = now.Add(59 * time.Minute)
+	if resp, _ := s.Begin(ctx, "k", "a"); resp == nil {
+		t.Fatalf("expected the key remembered within the TTL")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if resp, err := s.Begin(ctx, "k", "b"); resp != nil || err != nil {
+		t.Fatalf("expected an expired key to be free, got %v, %v", resp, err)
+	}
+	s.Abandon("k")
+
+	s.Begin(ctx, "other", "a")
+	s.Finish("other", Response{Status: 201})
+	now = now.Add(2 * time.Hour)
+	s.Begin(ctx, "new", "a")
+	// the expired key was swept, requests still in flight never expire
+	if s.Len() != 2 {
+		t.Errorf("expected 2 keys after the sweep, got %d", s.Len())
+	}
+}
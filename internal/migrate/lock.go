@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const lockTableName = "schema_migrations_lock"
+
+const lockPollInterval = 100 * time.Millisecond
+
+// withLock runs fn on a dedicated connection while holding the migration
+// lock, so concurrent instances starting up migrate one at a time.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	if m.opts.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.opts.LockTimeout)
+		defer cancel()
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.lock(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		// unlock must run even when ctx is what made fn fail
+		if uerr := m.unlock(context.Background(), conn); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	if m.dialect == DialectPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+			if ctx.Err() != nil {
+				return ErrLocked
+			}
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+lockTableName+` (
+		id        INTEGER   PRIMARY KEY,
+		locked_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create %s: %w", lockTableName, err)
+	}
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UTC()
+
+		// a lock row this old belongs to a process that died mid-migration
+		if _, err := conn.ExecContext(ctx, `DELETE FROM `+lockTableName+` WHERE locked_at < ?`, now.Add(-m.opts.StaleLock)); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+
+		res, err := conn.ExecContext(ctx, `INSERT INTO `+lockTableName+` (id, locked_at) VALUES (1, ?) ON CONFLICT (id) DO NOTHING`, now)
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrLocked
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Migrator) unlock(ctx context.Context, conn *sql.Conn) error {
+	var err error
+	if m.dialect == DialectPostgres {
+		_, err = conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, advisoryLockKey)
+	} else {
+		_, err = conn.ExecContext(ctx, `DELETE FROM `+lockTableName+` WHERE id = 1`)
+	}
+	if err != nil {
+		return fmt.Errorf("release migration lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,417 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDirty          = errors.New("database is dirty, fix the schema manually and run force")
+	ErrLocked         = errors.New("migration lock is held by another process")
+	ErrUnknownVersion = errors.New("unknown migration version")
+	ErrNoDown         = errors.New("migration has no down script")
+)
+
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+const (
+	tableName = "schema_migrations"
+
+	// advisory lock key for postgres, any constant shared by all instances works
+	advisoryLockKey = 7_423_001
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+type Options struct {
+	// LockTimeout bounds how long Up, Down and Force wait for another
+	// instance to release the lock. Zero waits until ctx is done.
+	LockTimeout time.Duration
+	// StaleLock is how old a sqlite lock row must be before it is taken
+	// over; postgres advisory locks die with their session instead.
+	StaleLock time.Duration
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+	opts       Options
+}
+
+// New reads migrations from fsys, named NNNN_description.up.sql and the
+// optional NNNN_description.down.sql.
+func New(db *sql.DB, dialect string, fsys fs.FS, opts Options) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.StaleLock <= 0 {
+		opts.StaleLock = 10 * time.Minute
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+		opts:       opts,
+	}, nil
+}
+
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version prefix", name)
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: strings.TrimSuffix(rest, "."+direction+".sql")}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			if m.Up != "" {
+				return nil, fmt.Errorf("migration %d: duplicate up script", version)
+			}
+			m.Up = string(b)
+		} else {
+			if m.Down != "" {
+				return nil, fmt.Errorf("migration %d: duplicate down script", version)
+			}
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d: missing up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies up to n pending migrations in order, all of them when n <= 0.
+// It returns the versions it applied.
+func (m *Migrator) Up(ctx context.Context, n int) ([]int, error) {
+	var done []int
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkDirty(applied); err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			if err := m.run(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the n most recently applied migrations, one when n <= 0.
+// It returns the versions it reverted.
+func (m *Migrator) Down(ctx context.Context, n int) ([]int, error) {
+	if n <= 0 {
+		n = 1
+	}
+
+	var done []int
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkDirty(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("migration %d: %w", mig.Version, ErrNoDown)
+			}
+
+			if err := m.run(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every known migration along with applied versions that have
+// no matching file.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			st.Applied = true
+			st.Dirty = a.Dirty
+			st.AppliedAt = a.AppliedAt
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, st)
+	}
+	for _, a := range applied {
+		statuses = append(statuses, a)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Force records version as the current schema without running any SQL:
+// migrations up to it are marked applied and clean, later ones removed.
+// Version 0 marks everything as not applied.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, m.bind(`DELETE FROM `+tableName+` WHERE version > ?`), version); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, m.bind(`UPDATE `+tableName+` SET dirty = ?`), false); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			var exists int
+			err := tx.QueryRowContext(ctx, m.bind(`SELECT COUNT(*) FROM `+tableName+` WHERE version = ?`), mig.Version).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists > 0 {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, m.bind(`INSERT INTO `+tableName+` (version, dirty, applied_at) VALUES (?, ?, ?)`),
+				mig.Version, false, now); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// run applies one migration in its own transaction. The version row is
+// flagged dirty beforehand in a separate statement, so a script that fails
+// half way on a database without transactional DDL leaves a visible trace.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	var err error
+	if up {
+		_, err = conn.ExecContext(ctx, m.bind(`INSERT INTO `+tableName+` (version, dirty, applied_at) VALUES (?, ?, ?)`),
+			mig.Version, true, time.Now().UTC())
+	} else {
+		_, err = conn.ExecContext(ctx, m.bind(`UPDATE `+tableName+` SET dirty = ? WHERE version = ?`), true, mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("migration %d: %w", mig.Version, err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script := mig.Up
+	if !up {
+		script = mig.Down
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d %s: %w", mig.Version, mig.Name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, m.bind(`UPDATE `+tableName+` SET dirty = ? WHERE version = ?`), false, mig.Version)
+	} else {
+		_, err = tx.ExecContext(ctx, m.bind(`DELETE FROM `+tableName+` WHERE version = ?`), mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("migration %d: %w", mig.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+tableName+` (
+		version    INTEGER   PRIMARY KEY,
+		dirty      BOOLEAN   NOT NULL DEFAULT FALSE,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", tableName, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]Status, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, dirty, applied_at FROM `+tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]Status)
+	for rows.Next() {
+		st := Status{Applied: true}
+		if err := rows.Scan(&st.Version, &st.Dirty, &st.AppliedAt); err != nil {
+			return nil, err
+		}
+		if mig := m.find(st.Version); mig != nil {
+			st.Name = mig.Name
+		}
+		applied[st.Version] = st
+	}
+
+	return applied, rows.Err()
+}
+
+func checkDirty(applied map[int]Status) error {
+	for _, st := range applied {
+		if st.Dirty {
+			return fmt.Errorf("%w (version %d)", ErrDirty, st.Version)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) bind(query string) string {
+	if m.dialect != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_create_items.up.sql":   {Data: []byte(`CREATE TABLE items (id INTEGER PRIMARY KEY);`)},
+	"0001_create_items.down.sql": {Data: []byte(`DROP TABLE items;`)},
+	"0002_add_name.up.sql":       {Data: []byte(`ALTER TABLE items ADD COLUMN name TEXT;`)},
+	"0002_add_name.down.sql":     {Data: []byte(`ALTER TABLE items DROP COLUMN name;`)},
+	"0003_add_index.up.sql":      {Data: []byte(`CREATE INDEX idx_items_name ON items (name);`)},
+	"0003_add_index.down.sql":    {Data: []byte(`DROP INDEX idx_items_name;`)},
+	"README.md":                  {Data: []byte(`ignored`)},
+}
+
+func openTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	m, err := New(db, DialectSQLite, fsys, Options{LockTimeout: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return m
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testMigrations)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("expected 3 migrations, got %d", len(migrations))
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "add_name" || migrations[1].Down == "" {
+		t.Errorf("unexpected migration: %+v", migrations[1])
+	}
+
+	_, err = Load(fstest.MapFS{"0001_x.down.sql": {Data: []byte(`SELECT 1;`)}})
+	if err == nil {
+		t.Error("expected error for migration without up script")
+	}
+
+	_, err = Load(fstest.MapFS{"first_x.up.sql": {Data: []byte(`SELECT 1;`)}})
+	if err == nil {
+		t.Error("expected error for invalid version prefix")
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	m := newTestMigrator(t, db, testMigrations)
+	ctx := context.Background()
+
+	applied, err := m.Up(ctx, 2)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Errorf("expected versions [1 2], got %v", applied)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(statuses) != 3 || !statuses[0].Applied || !statuses[1].Applied || statuses[2].Applied {
+		t.Errorf("unexpected status: %+v", statuses)
+	}
+
+	applied, err = m.Up(ctx, 0)
+	if err != nil || len(applied) != 1 || applied[0] != 3 {
+		t.Fatalf("expected version 3 to be applied, got %v, %v", applied, err)
+	}
+
+	reverted, err := m.Down(ctx, 2)
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(reverted) != 2 || reverted[0] != 3 || reverted[1] != 2 {
+		t.Errorf("expected versions [3 2] reverted, got %v", reverted)
+	}
+
+	if _, err := db.Exec(`INSERT INTO items (name) VALUES ('x')`); err == nil {
+		t.Error("expected name column to be dropped")
+	}
+	if _, err := db.Exec(`INSERT INTO items (id) VALUES (1)`); err != nil {
+		t.Errorf("expected items table to remain: %v", err)
+	}
+}
+
+func TestMigrator_DirtyAndForce(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	ctx := context.Background()
+
+	broken := fstest.MapFS{
+		"0001_create_items.up.sql": testMigrations["0001_create_items.up.sql"],
+		"0002_broken.up.sql":       {Data: []byte(`ALTER TABLE missing ADD COLUMN name TEXT;`)},
+	}
+	m := newTestMigrator(t, db, broken)
+
+	if _, err := m.Up(ctx, 0); err == nil {
+		t.Fatal("expected broken migration to fail")
+	}
+
+	statuses, _ := m.Status(ctx)
+	if !statuses[1].Dirty {
+		t.Errorf("expected version 2 to be dirty, got %+v", statuses[1])
+	}
+
+	if _, err := m.Up(ctx, 0); !errors.Is(err, ErrDirty) {
+		t.Errorf("expected ErrDirty, got %v", err)
+	}
+
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatalf("Force failed: %v", err)
+	}
+	statuses, _ = m.Status(ctx)
+	if !statuses[0].Applied || statuses[0].Dirty || statuses[1].Applied {
+		t.Errorf("unexpected status after force: %+v", statuses)
+	}
+
+	if err := m.Force(ctx, 42); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	holder := newTestMigrator(t, openTestDB(t, path), testMigrations)
+	waiter := newTestMigrator(t, openTestDB(t, path), testMigrations)
+
+	release := make(chan struct{})
+	locked := make(chan struct{})
+	go func() {
+		_ = holder.withLock(ctx, func(conn *sql.Conn) error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	if _, err := waiter.Up(ctx, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked while another migrator holds the lock, got %v", err)
+	}
+
+	close(release)
+
+	if _, err := waiter.Up(ctx, 0); err != nil {
+		t.Errorf("expected Up to succeed after release, got %v", err)
+	}
+}
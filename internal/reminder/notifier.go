@@ -0,0 +1,90 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+// Notifier delivers the reminder of a todo. An error means it was not
+// delivered and the scheduler will try again.
+type Notifier interface {
+	Notify(ctx context.Context, todo domain.Todo) error
+}
+
+// LogNotifier writes reminders to the log.
+type LogNotifier struct {
+	log *logger.Logger
+}
+
+func NewLogNotifier(log *logger.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, todo domain.Todo) error {
+	n.log.Info("todo reminder", "id", todo.ID, "title", todo.Title, "remind_at", todo.RemindAt, "due_at", todo.DueAt)
+	return nil
+}
+
+// WebhookNotifier posts reminders as JSON to a URL. Delivery is at least
+// once, so receivers should drop repeats of an X-Reminder-Id they have seen.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier uses client, or a client with a 10 second timeout when
+// it is nil.
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &WebhookNotifier{url: url, client: client}
+}
+
+type webhookPayload struct {
+	Event string      `json:"event"`
+	Todo  domain.Todo `json:"todo"`
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, todo domain.Todo) error {
+	body, err := json.Marshal(webhookPayload{Event: "todo.reminder", Todo: todo})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Reminder-Id", reminderID(todo))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// reminderID is the same for every attempt at one reminder and changes when
+// the reminder is moved.
+func reminderID(todo domain.Todo) string {
+	id := strconv.Itoa(todo.ID)
+	if todo.RemindAt != nil {
+		id += "-" + strconv.FormatInt(todo.RemindAt.Unix(), 10)
+	}
+	return id
+}
@@ -0,0 +1,311 @@
+// Package reminder sends the reminders todos ask for through a Notifier.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+// Repository is the store the scheduler reads reminders from and records
+// their delivery in.
+type Repository interface {
+	domain.TodoRepository
+	domain.ReminderRepository
+}
+
+type Options struct {
+	// RetryInterval is the wait before retrying a failed delivery. It doubles
+	// with every further failure up to MaxRetryInterval.
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration
+
+	Logger *logger.Logger
+}
+
+// entry is a reminder waiting to be sent at at, which is remindAt until a
+// delivery fails.
+type entry struct {
+	remindAt time.Time
+	at       time.Time
+	attempts int
+}
+
+// Scheduler sends each pending reminder once its time comes, retrying until
+// the notifier succeeds and the delivery is recorded on the todo. A reminder
+// whose delivery was not recorded is sent again, so delivery is at least
+// once. Before sending, the todo is read back from the repository, so a
+// reminder that was moved, cleared or deleted without telling the scheduler
+// is never sent.
+type Scheduler struct {
+	repo     Repository
+	notifier Notifier
+	opts     Options
+	log      *logger.Logger
+
+	mu      sync.Mutex
+	pending map[int]*entry
+	wake    chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewScheduler(repo Repository, notifier Notifier, opts Options) *Scheduler {
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 5 * time.Second
+	}
+	if opts.MaxRetryInterval < opts.RetryInterval {
+		opts.MaxRetryInterval = max(5*time.Minute, opts.RetryInterval)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	log := opts.Logger
+	if log == nil {
+		log = logger.New("error", io.Discard, "text")
+	}
+
+	return &Scheduler{
+		repo:     repo,
+		notifier: notifier,
+		opts:     opts,
+		log:      log,
+		pending:  make(map[int]*entry),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start schedules the pending reminders found in the repository, including
+// ones that came due while the server was down, and starts sending them.
+func (s *Scheduler) Start(ctx context.Context) error {
+	todos, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		s.Schedule(todo)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(runCtx)
+
+	s.log.Info("reminder scheduler started", "pending", s.Len())
+	return nil
+}
+
+// Stop cancels any delivery in flight and waits for the scheduler to finish
+// or ctx to end. Undelivered reminders stay pending in the repository.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Schedule (re)schedules the reminder of todo, or cancels it when the todo
+// has none pending.
+func (s *Scheduler) Schedule(todo domain.Todo) {
+	if !todo.ReminderPending() {
+		s.Cancel(todo.ID)
+		return
+	}
+
+	s.mu.Lock()
+	// keep the backoff of a reminder that is already being retried
+	if e, ok := s.pending[todo.ID]; !ok || !e.remindAt.Equal(*todo.RemindAt) {
+		s.pending[todo.ID] = &entry{remindAt: *todo.RemindAt, at: *todo.RemindAt}
+	}
+	s.mu.Unlock()
+	s.poke()
+}
+
+func (s *Scheduler) Cancel(id int) {
+	s.mu.Lock()
+	delete(s.pending, id)
+	s.mu.Unlock()
+	s.poke()
+}
+
+// Len returns the number of reminders waiting to be sent.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// poke makes run look at the schedule again.
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		var (
+			timer *time.Timer
+			fire  <-chan time.Time
+		)
+		if at, ok := s.next(); ok {
+			timer = time.NewTimer(time.Until(at))
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-fire:
+			s.deliverDue(ctx)
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// next returns when the earliest pending reminder is to be sent.
+func (s *Scheduler) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		earliest time.Time
+		found    bool
+	)
+	for _, e := range s.pending {
+		if !found || e.at.Before(earliest) {
+			earliest, found = e.at, true
+		}
+	}
+	return earliest, found
+}
+
+func (s *Scheduler) deliverDue(ctx context.Context) {
+	now := time.Now()
+
+	s.mu.Lock()
+	due := make(map[int]*entry)
+	for id, e := range s.pending {
+		if !e.at.After(now) {
+			due[id] = e
+		}
+	}
+	s.mu.Unlock()
+
+	for id, e := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.deliver(ctx, id, e)
+	}
+}
+
+func (s *Scheduler) deliver(ctx context.Context, id int, e *entry) {
+	todo, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrTodoNotFound) {
+		s.forget(id, e)
+		return
+	}
+	if err != nil {
+		s.retry(id, e, err)
+		return
+	}
+	if !todo.ReminderPending() {
+		s.forget(id, e)
+		return
+	}
+	if !todo.RemindAt.Equal(e.remindAt) {
+		s.forget(id, e)
+		s.Schedule(*todo)
+		return
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	err = s.notifier.Notify(attemptCtx, *todo)
+	cancel()
+	if err != nil {
+		s.retry(id, e, err)
+		return
+	}
+
+	err = s.repo.MarkReminded(ctx, id, e.remindAt, time.Now().UTC())
+	if errors.Is(err, domain.ErrReminderMoved) || errors.Is(err, domain.ErrTodoNotFound) {
+		// changed while it was being sent
+		s.reschedule(ctx, id, e)
+		return
+	}
+	if err != nil {
+		// the reminder is still pending, so it is sent again
+		s.retry(id, e, err)
+		return
+	}
+	s.forget(id, e)
+}
+
+// reschedule replaces e with the reminder the todo asks for now.
+func (s *Scheduler) reschedule(ctx context.Context, id int, e *entry) {
+	todo, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrTodoNotFound) {
+		s.forget(id, e)
+		return
+	}
+	if err != nil {
+		s.retry(id, e, err)
+		return
+	}
+	s.forget(id, e)
+	s.Schedule(*todo)
+}
+
+// forget drops e unless Schedule has replaced it in the meantime.
+func (s *Scheduler) forget(id int, e *entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[id] == e {
+		delete(s.pending, id)
+	}
+}
+
+func (s *Scheduler) retry(id int, e *entry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[id] != e {
+		return
+	}
+
+	e.attempts++
+	delay := s.opts.RetryInterval
+	for i := 1; i < e.attempts && delay < s.opts.MaxRetryInterval; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.opts.MaxRetryInterval)
+	e.at = time.Now().Add(delay)
+
+	s.log.Warn("reminder delivery failed", "id", id, "attempt", e.attempts, "retry_in", delay.String(), "error", err)
+}
@@ -0,0 +1,229 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/repository/memory"
+)
+
+// recorder is a Notifier that fails the first failures calls.
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     []int
+	notify   chan int
+}
+
+func newRecorder(failures int) *recorder {
+	return &recorder{failures: failures, notify: make(chan int, 16)}
+}
+
+func (r *recorder) Notify(ctx context.Context, todo domain.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts++
+	if r.attempts <= r.failures {
+		return errors.New("unavailable")
+	}
+	r.sent = append(r.sent, todo.ID)
+	r.notify <- todo.ID
+	return nil
+}
+
+func (r *recorder) wait(t *testing.T) int {
+	t.Helper()
+	select {
+	case id := <-r.notify:
+		return id
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a reminder")
+		return 0
+	}
+}
+
+func startScheduler(t *testing.T, repo Repository, n Notifier) *Scheduler {
+	t.Helper()
+	s := NewScheduler(repo, n, Options{RetryInterval: 10 * time.Millisecond})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { s.Stop(context.Background()) })
+	return s
+}
+
+func TestScheduler_SendsMissedRemindersOnStart(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call", RemindAt: &past})
+	repo.Create(ctx, domain.CreateTodoInput{Title: "Done", Completed: true, RemindAt: &past})
+	repo.Create(ctx, domain.CreateTodoInput{Title: "No reminder"})
+
+	n := newRecorder(0)
+	s := startScheduler(t, repo, n)
+
+	if id := n.wait(t); id != todo.ID {
+		t.Errorf("expected a reminder for %d, got %d", todo.ID, id)
+	}
+	waitFor(t, func() bool { return s.Len() == 0 })
+
+	got, _ := repo.GetByID(ctx, todo.ID)
+	if got.RemindedAt == nil || got.ReminderPending() {
+		t.Errorf("expected the delivery recorded, got %v", got.RemindedAt)
+	}
+	if got.Version != todo.Version || !got.UpdatedAt.Equal(todo.UpdatedAt) {
+		t.Errorf("expected the delivery not to count as a change, got version %d", got.Version)
+	}
+}
+
+func TestScheduler_RetriesFailedDeliveries(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call", RemindAt: &now})
+
+	n := newRecorder(2)
+	startScheduler(t, repo, n)
+
+	if id := n.wait(t); id != todo.ID {
+		t.Errorf("expected a reminder for %d, got %d", todo.ID, id)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", n.attempts)
+	}
+}
+
+func TestScheduler_Reschedule(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour)
+
+	moved, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Moved", RemindAt: &later})
+	deleted, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Deleted", RemindAt: &later})
+
+	n := newRecorder(0)
+	s := startScheduler(t, repo, n)
+	if s.Len() != 2 {
+		t.Fatalf("expected 2 pending reminders, got %d", s.Len())
+	}
+
+	soon := time.Now().Add(20 * time.Millisecond)
+	updated, _ := repo.Update(ctx, moved.ID, domain.UpdateTodoInput{RemindAt: &soon})
+	s.Schedule(*updated)
+	repo.Delete(ctx, deleted.ID)
+	s.Cancel(deleted.ID)
+
+	if id := n.wait(t); id != moved.ID {
+		t.Errorf("expected the moved reminder, got %d", id)
+	}
+	waitFor(t, func() bool { return s.Len() == 0 })
+}
+
+func TestScheduler_SkipsStaleReminders(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call", RemindAt: &now})
+	done := true
+	// completed behind the scheduler's back
+	stale := *todo
+	repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+
+	n := newRecorder(0)
+	s := NewScheduler(repo, n, Options{})
+	s.Schedule(stale)
+	s.deliverDue(ctx)
+
+	if s.Len() != 0 || len(n.sent) != 0 {
+		t.Errorf("expected the stale reminder dropped, got %d pending and %v sent", s.Len(), n.sent)
+	}
+}
+
+// mover is a Notifier that moves the reminder it sends, as a request could
+// while it is being sent.
+type mover struct {
+	repo *memory.TodoRepository
+	to   time.Time
+}
+
+func (m mover) Notify(ctx context.Context, todo domain.Todo) error {
+	_, err := m.repo.Update(ctx, todo.ID, domain.UpdateTodoInput{RemindAt: &m.to})
+	return err
+}
+
+func TestScheduler_ReschedulesReminderMovedWhileSending(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call", RemindAt: &now})
+
+	s := NewScheduler(repo, mover{repo, later}, Options{})
+	s.Schedule(*todo)
+	s.deliverDue(ctx)
+
+	got, _ := repo.GetByID(ctx, todo.ID)
+	if got.RemindedAt != nil || !got.ReminderPending() {
+		t.Errorf("expected the moved reminder left pending, got reminded %v", got.RemindedAt)
+	}
+	if at, ok := s.next(); !ok || !at.Equal(later) {
+		t.Errorf("expected the reminder rescheduled for %s, got %s", later, at)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var (
+		got     webhookPayload
+		id      string
+		healthy = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = r.Header.Get("X-Reminder-Id")
+		json.NewDecoder(r.Body).Decode(&got)
+		if !healthy {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	at := time.Unix(1736150400, 0)
+	n := NewWebhookNotifier(srv.URL, nil)
+	if err := n.Notify(context.Background(), domain.Todo{ID: 7, Title: "Call", RemindAt: &at}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got.Event != "todo.reminder" || got.Todo.ID != 7 || id != "7-1736150400" {
+		t.Errorf("unexpected delivery %+v with id %q", got, id)
+	}
+
+	healthy = false
+	if err := n.Notify(context.Background(), domain.Todo{ID: 7}); err == nil {
+		t.Errorf("expected an error for a 502 response")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
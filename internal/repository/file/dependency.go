@@ -0,0 +1,43 @@
+package file
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) AddDependency(ctx context.Context, dep domain.Dependency) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.mem.AddDependency(ctx, dep); err != nil {
+		return err
+	}
+
+	if err := r.appendLocked(record{Op: opPutDependency, Dependency: &dep}); err != nil {
+		_ = r.mem.RemoveDependency(ctx, dep)
+		return err
+	}
+
+	return nil
+}
+
+func (r *TodoRepository) RemoveDependency(ctx context.Context, dep domain.Dependency) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.mem.RemoveDependency(ctx, dep); err != nil {
+		return err
+	}
+
+	if err := r.appendLocked(record{Op: opDeleteDependency, Dependency: &dep}); err != nil {
+		r.mem.RestoreDependency(dep)
+		return err
+	}
+
+	return nil
+}
+
+func (r *TodoRepository) ListDependencies(ctx context.Context) ([]domain.Dependency, error) {
+	return r.mem.ListDependencies(ctx)
+}
@@ -0,0 +1,396 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/repository/memory"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+const (
+	walFileName      = "todos.wal"
+	snapshotFileName = "todos.snapshot"
+)
+
+type Options struct {
+	Dir          string
+	SyncPolicy   SyncPolicy
+	SyncInterval time.Duration
+
+	// SnapshotInterval and SnapshotEvery trigger a snapshot followed by log
+	// compaction on a timer and after that many log records respectively.
+	// Zero disables the trigger.
+	SnapshotInterval time.Duration
+	SnapshotEvery    int
+
+	Logger *logger.Logger
+}
+
+// TodoRepository keeps the working set in a memory.TodoRepository and
+// appends every mutation to a log in Dir. On open the latest snapshot is
+// loaded and the log tail written after it is replayed on top.
+type TodoRepository struct {
+	mu   sync.Mutex // serializes writes so log order matches apply order
+	mem  *memory.TodoRepository
+	wal  *wal
+	opts Options
+	log  *logger.Logger
+
+	// set on the repository InTx hands out, whose records are held back
+	// until the transaction commits
+	inTx    bool
+	pending []record
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewTodoRepository(opts Options) (*TodoRepository, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("data dir is required")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	log := opts.Logger
+	if log == nil {
+		log = logger.New("error", io.Discard, "text")
+	}
+
+	r := &TodoRepository{
+		mem:  memory.NewTodoRepository(),
+		opts: opts,
+		log:  log,
+	}
+
+	snap, err := readSnapshot(r.snapshotPath())
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var after uint64
+	if snap != nil {
+		for _, tag := range snap.Tags {
+			r.mem.RestoreTag(tag)
+		}
+		for _, list := range snap.Lists {
+			r.mem.RestoreList(list)
+		}
+		for _, todo := range snap.Todos {
+			r.mem.Restore(todo)
+		}
+		for _, dep := range snap.Dependencies {
+			r.mem.RestoreDependency(dep)
+		}
+		for _, hook := range snap.Webhooks {
+			r.mem.RestoreWebhook(hook)
+		}
+		r.mem.SetNextID(snap.NextID)
+		r.mem.SetNextTagID(snap.NextTagID)
+		r.mem.SetNextListID(snap.NextListID)
+		r.mem.SetNextWebhookID(snap.NextWebhookID)
+		after = snap.Seq
+	}
+
+	w, err := openWAL(filepath.Join(opts.Dir, walFileName), opts.SyncPolicy, opts.SyncInterval)
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+	r.wal = w
+
+	torn, err := w.replay(after, r.apply)
+	if err != nil {
+		w.close()
+		return nil, fmt.Errorf("replay wal: %w", err)
+	}
+	if torn {
+		log.Warn("discarded torn record at the end of the wal", "dir", opts.Dir)
+	}
+
+	if opts.SnapshotInterval > 0 {
+		r.stop = make(chan struct{})
+		r.done = make(chan struct{})
+		go r.snapshotLoop(opts.SnapshotInterval)
+	}
+
+	return r, nil
+}
+
+func (r *TodoRepository) snapshotPath() string {
+	return filepath.Join(r.opts.Dir, snapshotFileName)
+}
+
+// Snapshot writes the full state to disk and truncates the log behind it.
+func (r *TodoRepository) Snapshot() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.snapshotLocked()
+}
+
+func (r *TodoRepository) snapshotLocked() error {
+	todos, err := r.mem.GetAll(context.Background())
+	if err != nil {
+		return err
+	}
+	trash, err := r.mem.ListTrash(context.Background())
+	if err != nil {
+		return err
+	}
+	todos = append(todos, trash...)
+	tags, err := r.mem.ListTags(context.Background())
+	if err != nil {
+		return err
+	}
+	lists, err := r.mem.GetLists(context.Background())
+	if err != nil {
+		return err
+	}
+	deps, err := r.mem.ListDependencies(context.Background())
+	if err != nil {
+		return err
+	}
+	hooks, err := r.mem.ListWebhooks(context.Background())
+	if err != nil {
+		return err
+	}
+
+	snap := snapshot{
+		Seq:       r.wal.lastSeq(),
+		NextID:    r.mem.NextID(),
+		Todos:     todos,
+		NextTagID: r.mem.NextTagID(),
+		Tags:      tags,
+
+		NextListID: r.mem.NextListID(),
+		Lists:      lists,
+
+		Dependencies: deps,
+
+		NextWebhookID: r.mem.NextWebhookID(),
+		Webhooks:      hooks,
+	}
+	if err := writeSnapshot(r.snapshotPath(), snap); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	// a crash before the reset is fine, replay skips records up to snap.Seq
+	if err := r.wal.reset(); err != nil {
+		return fmt.Errorf("compact wal: %w", err)
+	}
+	return nil
+}
+
+func (r *TodoRepository) snapshotLoop(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Snapshot(); err != nil {
+				r.log.Error("periodic snapshot failed", "error", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// appendLocked logs rec and snapshots once the log has grown past
+// SnapshotEvery records. A failed snapshot does not fail the write, the
+// record is already durable in the log.
+func (r *TodoRepository) appendLocked(rec record) error {
+	if r.inTx {
+		r.pending = append(r.pending, rec)
+		return nil
+	}
+	if err := r.wal.append(rec); err != nil {
+		return err
+	}
+	r.maybeSnapshotLocked()
+	return nil
+}
+
+func (r *TodoRepository) maybeSnapshotLocked() {
+	if r.opts.SnapshotEvery > 0 && r.wal.size() >= r.opts.SnapshotEvery {
+		if err := r.snapshotLocked(); err != nil {
+			r.log.Error("snapshot failed", "error", err)
+		}
+	}
+}
+
+func (r *TodoRepository) apply(rec record) error {
+	switch rec.Op {
+	case opPut:
+		if rec.Todo == nil {
+			return fmt.Errorf("put record without todo")
+		}
+		r.mem.Restore(*rec.Todo)
+	case opDelete:
+		// deleting an id the log never created is harmless, the result is the same
+		r.mem.Purge(rec.ID)
+	case opPutTag:
+		if rec.Tag == nil {
+			return fmt.Errorf("put_tag record without tag")
+		}
+		r.mem.RestoreTag(*rec.Tag)
+	case opDeleteTag:
+		_ = r.mem.DeleteTag(context.Background(), rec.ID)
+	case opPutList:
+		if rec.List == nil {
+			return fmt.Errorf("put_list record without list")
+		}
+		r.mem.RestoreList(*rec.List)
+	case opDeleteList:
+		// logs written before lists moved their todos to the trash have no
+		// records for them, they were removed for good
+		page, _ := r.mem.List(context.Background(), domain.ListQuery{Filter: domain.TodoFilter{ListID: &rec.ID}})
+		for _, todo := range append(page.Items, r.trashedIn(rec.ID)...) {
+			r.mem.Purge(todo.ID)
+		}
+		_ = r.mem.DeleteList(context.Background(), rec.ID, rec.Cascade)
+	case opPutDependency, opDeleteDependency:
+		if rec.Dependency == nil {
+			return fmt.Errorf("%s record without dependency", rec.Op)
+		}
+		if rec.Op == opPutDependency {
+			r.mem.RestoreDependency(*rec.Dependency)
+		} else {
+			_ = r.mem.RemoveDependency(context.Background(), *rec.Dependency)
+		}
+	case opPutWebhook:
+		if rec.Webhook == nil {
+			return fmt.Errorf("put_webhook record without webhook")
+		}
+		r.mem.RestoreWebhook(*rec.Webhook)
+	case opDeleteWebhook:
+		_ = r.mem.DeleteWebhook(context.Background(), rec.ID)
+	case opBatch:
+		for _, sub := range rec.Records {
+			if err := r.apply(sub); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown op %q", rec.Op)
+	}
+	return nil
+}
+
+func (r *TodoRepository) Create(ctx context.Context, input domain.CreateTodoInput) (*domain.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, err := r.mem.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *todo
+	if err := r.appendLocked(record{Op: opPut, Todo: &saved}); err != nil {
+		_ = r.mem.Delete(ctx, todo.ID)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (r *TodoRepository) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
+	return r.mem.GetByID(ctx, id)
+}
+
+func (r *TodoRepository) GetAll(ctx context.Context) ([]domain.Todo, error) {
+	return r.mem.GetAll(ctx)
+}
+
+func (r *TodoRepository) Update(ctx context.Context, id int, input domain.UpdateTodoInput) (*domain.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	prev := *current
+
+	todo, err := r.mem.Update(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *todo
+	if err := r.appendLocked(record{Op: opPut, Todo: &saved}); err != nil {
+		r.mem.Restore(prev)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (r *TodoRepository) Delete(ctx context.Context, id int) error {
+	return r.delete(ctx, id, func() error { return r.mem.Delete(ctx, id) })
+}
+
+func (r *TodoRepository) DeleteVersion(ctx context.Context, id, version int) error {
+	return r.delete(ctx, id, func() error { return r.mem.DeleteVersion(ctx, id, version) })
+}
+
+// delete logs the move to the trash del makes in memory, restoring the todo
+// and its dependencies if that fails.
+func (r *TodoRepository) delete(ctx context.Context, id int, del func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	prev := *current
+	deps := r.mem.DependenciesOf(id)
+
+	if err := del(); err != nil {
+		return err
+	}
+
+	trashed, _ := r.mem.GetTrashed(id)
+	if err := r.appendLocked(record{Op: opPut, Todo: &trashed}); err != nil {
+		r.mem.Restore(prev)
+		for _, dep := range deps {
+			r.mem.RestoreDependency(dep)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *TodoRepository) GetFiltered(ctx context.Context, completed *bool, search string) ([]domain.Todo, error) {
+	return r.mem.GetFiltered(ctx, completed, search)
+}
+
+func (r *TodoRepository) List(ctx context.Context, query domain.ListQuery) (*domain.TodoPage, error) {
+	return r.mem.List(ctx, query)
+}
+
+func (r *TodoRepository) Close() error {
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.wal.close()
+}
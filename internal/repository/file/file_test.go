@@ -0,0 +1,685 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/repository/repositorytest"
+)
+
+func TestTodoRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) domain.TodoRepository {
+		repo := openTestRepo(t, t.TempDir(), SyncNever)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func openTestRepo(t *testing.T, dir string, policy SyncPolicy) *TodoRepository {
+	t.Helper()
+	repo, err := NewTodoRepository(Options{Dir: dir, SyncPolicy: policy, SyncInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewTodoRepository failed: %v", err)
+	}
+	return repo
+}
+
+func TestTodoRepository_ReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+
+	first, err := repo.Create(ctx, domain.CreateTodoInput{Title: "First", Description: "one"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	second, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Second"})
+	third, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Third"})
+
+	completed := true
+	if _, err := repo.Update(ctx, first.ID, domain.UpdateTodoInput{Completed: &completed}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := repo.Delete(ctx, second.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	// deleting the highest id must not let it be reused after replay
+	if err := repo.Delete(ctx, third.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("expected 1 todo after replay, got %d", len(all))
+	}
+
+	got, err := repo.GetByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !got.Completed || got.Description != "one" {
+		t.Errorf("update not replayed: %+v", got)
+	}
+
+	if _, err := repo.GetByID(ctx, second.ID); err != domain.ErrTodoNotFound {
+		t.Errorf("expected ErrTodoNotFound for deleted todo, got %v", err)
+	}
+
+	next, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Fourth"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if next.ID != third.ID+1 {
+		t.Errorf("expected ID=%d after replay, got %d", third.ID+1, next.ID)
+	}
+}
+
+func TestTodoRepository_SyncPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		dir := t.TempDir()
+
+		repo := openTestRepo(t, dir, policy)
+		if _, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Task"}); err != nil {
+			t.Fatalf("policy %d: Create failed: %v", policy, err)
+		}
+		if err := repo.Close(); err != nil {
+			t.Fatalf("policy %d: Close failed: %v", policy, err)
+		}
+
+		repo = openTestRepo(t, dir, policy)
+		all, _ := repo.GetAll(ctx)
+		if len(all) != 1 {
+			t.Errorf("policy %d: expected 1 todo, got %d", policy, len(all))
+		}
+		repo.Close()
+	}
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	cases := map[string]SyncPolicy{
+		"":         SyncAlways,
+		"always":   SyncAlways,
+		"Interval": SyncInterval,
+		"never":    SyncNever,
+	}
+	for in, want := range cases {
+		got, err := ParseSyncPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSyncPolicy(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	if _, err := ParseSyncPolicy("sometimes"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestTodoRepository_SnapshotAndLogTail(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	a, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "A"})
+	b, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "B"})
+	_ = repo.Delete(ctx, b.ID)
+
+	if err := repo.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatalf("stat wal: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected empty wal after snapshot, got %d bytes", info.Size())
+	}
+
+	title := "A2"
+	if _, err := repo.Update(ctx, a.ID, domain.UpdateTodoInput{Title: &title}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	c, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "C"})
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	got, err := repo.GetByID(ctx, a.ID)
+	if err != nil || got.Title != "A2" {
+		t.Errorf("expected tail update on top of snapshot, got %+v, %v", got, err)
+	}
+	if _, err := repo.GetByID(ctx, c.ID); err != nil {
+		t.Errorf("expected todo created after snapshot, got %v", err)
+	}
+
+	next, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "D"})
+	if next.ID != c.ID+1 {
+		t.Errorf("expected ID=%d, got %d", c.ID+1, next.ID)
+	}
+}
+
+func TestTodoRepository_SnapshotEvery(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := NewTodoRepository(Options{Dir: dir, SnapshotEvery: 3})
+	if err != nil {
+		t.Fatalf("NewTodoRepository failed: %v", err)
+	}
+	for i := 0; i < 7; i++ {
+		if _, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Task"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if n := repo.wal.size(); n != 1 {
+		t.Errorf("expected 1 record left in wal, got %d", n)
+	}
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	all, _ := repo.GetAll(ctx)
+	if len(all) != 7 {
+		t.Errorf("expected 7 todos, got %d", len(all))
+	}
+}
+
+func TestTodoRepository_CrashBetweenSnapshotAndCompaction(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	a, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "A"})
+	_ = repo.Delete(ctx, a.ID)
+	b, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "B"})
+
+	// snapshot written, but the log was never truncated
+	todos, _ := repo.mem.GetAll(ctx)
+	snap := snapshot{Seq: repo.wal.lastSeq(), NextID: repo.mem.NextID(), Todos: todos}
+	if err := writeSnapshot(filepath.Join(dir, snapshotFileName), snap); err != nil {
+		t.Fatalf("writeSnapshot failed: %v", err)
+	}
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	all, _ := repo.GetAll(ctx)
+	if len(all) != 1 || all[0].ID != b.ID {
+		t.Errorf("expected only todo %d, got %+v", b.ID, all)
+	}
+
+	c, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "C"})
+	if c.ID != b.ID+1 {
+		t.Errorf("expected ID=%d, got %d", b.ID+1, c.ID)
+	}
+}
+
+func TestTodoRepository_TornFinalRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "A"})
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "B"})
+	repo.Close()
+
+	path := filepath.Join(dir, walFileName)
+	data, _ := os.ReadFile(path)
+	intact := len(data)
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`1234abcd {"seq":3,"op":"put","todo":{"id":3,"tit`)
+	f.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	all, _ := repo.GetAll(ctx)
+	if len(all) != 2 {
+		t.Errorf("expected 2 todos after recovery, got %d", len(all))
+	}
+
+	info, _ := os.Stat(path)
+	if info.Size() != int64(intact) {
+		t.Errorf("expected torn tail to be truncated to %d bytes, got %d", intact, info.Size())
+	}
+
+	c, err := repo.Create(ctx, domain.CreateTodoInput{Title: "C"})
+	if err != nil || c.ID != 3 {
+		t.Fatalf("expected new todo with ID=3, got %+v, %v", c, err)
+	}
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+	all, _ = repo.GetAll(ctx)
+	if len(all) != 3 {
+		t.Errorf("expected 3 todos, got %d", len(all))
+	}
+}
+
+func TestTodoRepository_ReplaysLogWithoutChecksums(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// as written before records had checksums and sequence numbers
+	legacy := `{"op":"put","todo":{"id":1,"title":"A","completed":false,"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z"}}
+{"op":"put","todo":{"id":2,"title":"B","completed":false,"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z"}}
+{"op":"delete","id":1}
+`
+	if err := os.WriteFile(filepath.Join(dir, walFileName), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	all, _ := repo.GetAll(ctx)
+	if len(all) != 1 || all[0].ID != 2 || all[0].Title != "B" {
+		t.Fatalf("expected only todo 2 from the old log, got %+v", all)
+	}
+	c, err := repo.Create(ctx, domain.CreateTodoInput{Title: "C"})
+	if err != nil || c.ID != 3 {
+		t.Fatalf("expected new todo with ID=3, got %+v, %v", c, err)
+	}
+	repo.Close()
+
+	for _, fromSnapshot := range []bool{false, true} {
+		repo = openTestRepo(t, dir, SyncAlways)
+		all, _ = repo.GetAll(ctx)
+		if len(all) != 2 || all[0].ID != 2 || all[1].ID != 3 {
+			t.Errorf("fromSnapshot=%v: expected todos 2 and 3, got %+v", fromSnapshot, all)
+		}
+		if !fromSnapshot {
+			if err := repo.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		repo.Close()
+	}
+}
+
+func TestTodoRepository_CorruptRecordBeforeTail(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "A"})
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "B"})
+	repo.Close()
+
+	path := filepath.Join(dir, walFileName)
+	data, _ := os.ReadFile(path)
+	data[crcWidth+5] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write wal: %v", err)
+	}
+
+	if _, err := NewTodoRepository(Options{Dir: dir}); !errors.Is(err, errCorruptRecord) {
+		t.Errorf("expected errCorruptRecord, got %v", err)
+	}
+}
+
+func TestTodoRepository_TagsSurviveRestart(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		repo := openTestRepo(t, dir, SyncAlways)
+		work, _ := repo.CreateTag(ctx, domain.CreateTagInput{Name: "work", Color: "#123456"})
+		home, _ := repo.CreateTag(ctx, domain.CreateTagInput{Name: "home"})
+		todo, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Report", Tags: []string{"home", "work"}})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		job := "job"
+		if _, err := repo.UpdateTag(ctx, work.ID, domain.UpdateTagInput{Name: &job}); err != nil {
+			t.Fatalf("UpdateTag failed: %v", err)
+		}
+		if err := repo.DeleteTag(ctx, home.ID); err != nil {
+			t.Fatalf("DeleteTag failed: %v", err)
+		}
+		if snapshot {
+			if err := repo.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		repo.Close()
+
+		repo = openTestRepo(t, dir, SyncAlways)
+
+		got, err := repo.GetByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if len(got.Tags) != 1 || got.Tags[0] != "job" {
+			t.Errorf("snapshot=%v: expected tags [job], got %v", snapshot, got.Tags)
+		}
+
+		tags, _ := repo.ListTags(ctx)
+		if len(tags) != 1 || tags[0].Name != "job" || tags[0].Color != "#123456" {
+			t.Errorf("snapshot=%v: expected only job to survive, got %+v", snapshot, tags)
+		}
+
+		next, _ := repo.CreateTag(ctx, domain.CreateTagInput{Name: "new"})
+		if next.ID <= home.ID {
+			t.Errorf("snapshot=%v: expected tag ids not to be reused, got %d", snapshot, next.ID)
+		}
+		repo.Close()
+	}
+}
+
+func TestTodoRepository_ListsSurviveRestart(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		repo := openTestRepo(t, dir, SyncAlways)
+		work, _ := repo.CreateList(ctx, domain.CreateListInput{Name: "Work"})
+		home, _ := repo.CreateList(ctx, domain.CreateListInput{Name: "Home"})
+		report, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Report", ListID: work.ID})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		dishes, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Dishes", ListID: home.ID})
+
+		if err := repo.DeleteList(ctx, home.ID, true); err != nil {
+			t.Fatalf("DeleteList failed: %v", err)
+		}
+		if snapshot {
+			if err := repo.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		repo.Close()
+
+		repo = openTestRepo(t, dir, SyncAlways)
+
+		got, err := repo.GetByID(ctx, report.ID)
+		if err != nil || got.ListID != work.ID {
+			t.Errorf("snapshot=%v: expected the report in list %d, got %+v, %v", snapshot, work.ID, got, err)
+		}
+		if _, err := repo.GetByID(ctx, dishes.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("snapshot=%v: expected the cascade to survive, got %v", snapshot, err)
+		}
+
+		lists, _ := repo.GetLists(ctx)
+		if len(lists) != 1 || lists[0].Name != "Work" || lists[0].OpenCount != 1 {
+			t.Errorf("snapshot=%v: expected only Work to survive, got %+v", snapshot, lists)
+		}
+
+		next, _ := repo.CreateList(ctx, domain.CreateListInput{Name: "New"})
+		if next.ID <= home.ID {
+			t.Errorf("snapshot=%v: expected list ids not to be reused, got %d", snapshot, next.ID)
+		}
+		repo.Close()
+	}
+}
+
+func TestTodoRepository_DependenciesSurviveRestart(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		repo := openTestRepo(t, dir, SyncAlways)
+		deploy, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Deploy"})
+		build, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Build"})
+		review, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Review"})
+		repo.AddDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: build.ID})
+		repo.AddDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: review.ID})
+		if err := repo.RemoveDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: review.ID}); err != nil {
+			t.Fatalf("RemoveDependency failed: %v", err)
+		}
+		if snapshot {
+			if err := repo.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		repo.Close()
+
+		repo = openTestRepo(t, dir, SyncAlways)
+
+		got, err := repo.GetByID(ctx, deploy.ID)
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if len(got.BlockedBy) != 1 || got.BlockedBy[0] != build.ID || !got.Blocked {
+			t.Errorf("snapshot=%v: expected deploy blocked by build only, got %v", snapshot, got.BlockedBy)
+		}
+		repo.Close()
+	}
+}
+
+func TestTodoRepository_RemindersSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	at := time.Date(2025, 1, 6, 8, 30, 0, 0, time.UTC)
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call", RemindAt: &at})
+	sent := at.Add(time.Second)
+	if err := repo.MarkReminded(ctx, todo.ID, at, sent); err != nil {
+		t.Fatalf("MarkReminded failed: %v", err)
+	}
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+	got, err := repo.GetByID(ctx, todo.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.RemindedAt == nil || !got.RemindedAt.Equal(sent) || got.Version != todo.Version {
+		t.Errorf("expected the reminder delivered at %s at version %d, got %v at %d", sent, todo.Version, got.RemindedAt, got.Version)
+	}
+}
+
+func TestTodoRepository_WebhooksSurviveRestart(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		repo := openTestRepo(t, dir, SyncAlways)
+		kept, _ := repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: "http://a.example", Secret: "0123456789abcdef"})
+		gone, _ := repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: "http://b.example", Secret: "0123456789abcdef"})
+		off := false
+		repo.UpdateWebhook(ctx, kept.ID, domain.UpdateWebhookInput{Active: &off})
+		if err := repo.DeleteWebhook(ctx, gone.ID); err != nil {
+			t.Fatalf("DeleteWebhook failed: %v", err)
+		}
+		if snapshot {
+			if err := repo.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		repo.Close()
+
+		repo = openTestRepo(t, dir, SyncAlways)
+
+		hooks, err := repo.ListWebhooks(ctx)
+		if err != nil {
+			t.Fatalf("ListWebhooks failed: %v", err)
+		}
+		if len(hooks) != 1 || hooks[0].ID != kept.ID || hooks[0].Active || hooks[0].Secret != "0123456789abcdef" {
+			t.Errorf("snapshot=%v: expected only the inactive webhook %d, got %+v", snapshot, kept.ID, hooks)
+		}
+		next, _ := repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: "http://c.example", Secret: "0123456789abcdef"})
+		if next.ID <= gone.ID {
+			t.Errorf("snapshot=%v: expected a fresh id after %d, got %d", snapshot, gone.ID, next.ID)
+		}
+		repo.Close()
+	}
+}
+
+func TestTodoRepository_TransactionsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// the second record, the batch, triggers a snapshot that has to include it
+	repo, err := NewTodoRepository(Options{Dir: dir, SyncPolicy: SyncAlways, SnapshotEvery: 2})
+	if err != nil {
+		t.Fatalf("NewTodoRepository failed: %v", err)
+	}
+	old, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Old"})
+
+	var created *domain.Todo
+	err = repo.InTx(ctx, func(tx domain.TodoRepository) error {
+		if created, err = tx.Create(ctx, domain.CreateTodoInput{Title: "New"}); err != nil {
+			return err
+		}
+		// nested transactions join the enclosing one, or leave it untouched
+		nested := tx.(domain.Transactor)
+		nested.InTx(ctx, func(tx domain.TodoRepository) error {
+			tx.Create(ctx, domain.CreateTodoInput{Title: "Nested rolled back"})
+			return errors.New("give up")
+		})
+		return nested.InTx(ctx, func(tx domain.TodoRepository) error {
+			return tx.Delete(ctx, old.ID)
+		})
+	})
+	if err != nil {
+		t.Fatalf("InTx failed: %v", err)
+	}
+	repo.InTx(ctx, func(tx domain.TodoRepository) error {
+		tx.Create(ctx, domain.CreateTodoInput{Title: "Rolled back"})
+		return errors.New("give up")
+	})
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != 1 || all[0].ID != created.ID || all[0].Title != "New" {
+		t.Errorf("expected only the todo created in the transaction, got %+v", all)
+	}
+}
+
+func TestTodoRepository_TrashSurvivesRestart(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		repo := openTestRepo(t, dir, SyncAlways)
+		trashed, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Trashed"})
+		restored, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Restored"})
+		purged, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Purged"})
+		repo.Delete(ctx, purged.ID)
+		if n, err := repo.PurgeTrash(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+			t.Fatalf("PurgeTrash: expected 1 purged, got %d, %v", n, err)
+		}
+		repo.Delete(ctx, trashed.ID)
+		repo.Delete(ctx, restored.ID)
+		if _, err := repo.RestoreDeleted(ctx, restored.ID); err != nil {
+			t.Fatalf("RestoreDeleted failed: %v", err)
+		}
+		if snapshot {
+			if err := repo.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		repo.Close()
+
+		repo = openTestRepo(t, dir, SyncAlways)
+
+		all, _ := repo.GetAll(ctx)
+		if len(all) != 1 || all[0].ID != restored.ID || all[0].Version != 3 {
+			t.Errorf("snapshot=%v: expected only the restored todo at version 3, got %+v", snapshot, all)
+		}
+		trash, err := repo.ListTrash(ctx)
+		if err != nil {
+			t.Fatalf("ListTrash failed: %v", err)
+		}
+		if len(trash) != 1 || trash[0].ID != trashed.ID || trash[0].DeletedAt == nil {
+			t.Errorf("snapshot=%v: expected only todo %d in the trash, got %+v", snapshot, trashed.ID, trash)
+		}
+		if _, err := repo.RestoreDeleted(ctx, purged.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("snapshot=%v: expected the purged todo gone, got %v", snapshot, err)
+		}
+		repo.Close()
+	}
+}
+
+func TestTodoRepository_DeletedListKeepsItsTodosInTheTrashAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	list, _ := repo.CreateList(ctx, domain.CreateListInput{Name: "Home"})
+	earlier, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Earlier", ListID: list.ID})
+	dishes, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Dishes", ListID: list.ID})
+	repo.Delete(ctx, earlier.ID)
+	if err := repo.DeleteList(ctx, list.ID, true); err != nil {
+		t.Fatalf("DeleteList failed: %v", err)
+	}
+	before, _ := repo.ListTrash(ctx)
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	after, _ := repo.ListTrash(ctx)
+	if len(after) != 2 || len(before) != 2 {
+		t.Fatalf("expected both todos in the trash, got %+v", after)
+	}
+	for i, todo := range after {
+		if todo.ListID != 0 || !todo.DeletedAt.Equal(*before[i].DeletedAt) {
+			t.Errorf("expected todo %d in the trash without a list as before the restart, got %+v", todo.ID, todo)
+		}
+	}
+	if restored, err := repo.RestoreDeleted(ctx, dishes.ID); err != nil || restored.ListID != 0 {
+		t.Errorf("expected the todo restored without a list, got %+v, %v", restored, err)
+	}
+}
+
+func TestTodoRepository_TrashedTodoLosesDependenciesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := openTestRepo(t, dir, SyncAlways)
+	deploy, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Deploy"})
+	build, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Build"})
+	repo.AddDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: build.ID})
+	if err := repo.Delete(ctx, build.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	repo.Close()
+
+	repo = openTestRepo(t, dir, SyncAlways)
+	defer repo.Close()
+
+	deps, err := repo.ListDependencies(ctx)
+	if err != nil {
+		t.Fatalf("ListDependencies failed: %v", err)
+	}
+	if len(deps) != 0 {
+		t.Errorf("expected the trashed todo's edges gone after a restart, got %+v", deps)
+	}
+	if _, err := repo.RestoreDeleted(ctx, build.ID); err != nil {
+		t.Fatalf("RestoreDeleted failed: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, deploy.ID); got.Blocked || len(got.BlockedBy) != 0 {
+		t.Errorf("expected deploy unblocked after the restore, got %v blocked=%v", got.BlockedBy, got.Blocked)
+	}
+}
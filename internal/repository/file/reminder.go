@@ -0,0 +1,31 @@
+package file
+
+import (
+	"context"
+	"time"
+)
+
+func (r *TodoRepository) MarkReminded(ctx context.Context, id int, remindAt, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	prev := *current
+
+	if err := r.mem.MarkReminded(ctx, id, remindAt, at); err != nil {
+		return err
+	}
+	todo, err := r.mem.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.appendLocked(record{Op: opPut, Todo: todo}); err != nil {
+		r.mem.Restore(prev)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// snapshot is the full state as of log record Seq. Records up to and
+// including Seq are already reflected in it and are skipped on replay.
+type snapshot struct {
+	Seq       uint64        `json:"seq"`
+	NextID    int           `json:"next_id"`
+	Todos     []domain.Todo `json:"todos"`
+	NextTagID int           `json:"next_tag_id,omitempty"`
+	Tags      []domain.Tag  `json:"tags,omitempty"`
+
+	NextListID int               `json:"next_list_id,omitempty"`
+	Lists      []domain.TodoList `json:"lists,omitempty"`
+
+	Dependencies []domain.Dependency `json:"dependencies,omitempty"`
+
+	NextWebhookID int              `json:"next_webhook_id,omitempty"`
+	Webhooks      []domain.Webhook `json:"webhooks,omitempty"`
+}
+
+func readSnapshot(path string) (*snapshot, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// writeSnapshot replaces the snapshot at path atomically: a crash leaves
+// either the old or the new file, never a partial one.
+func writeSnapshot(path string, snap snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
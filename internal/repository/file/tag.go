@@ -0,0 +1,89 @@
+package file
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) CreateTag(ctx context.Context, input domain.CreateTagInput) (*domain.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tag, err := r.mem.CreateTag(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *tag
+	if err := r.appendLocked(record{Op: opPutTag, Tag: &saved}); err != nil {
+		_ = r.mem.DeleteTag(ctx, tag.ID)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (r *TodoRepository) GetTag(ctx context.Context, id int) (*domain.Tag, error) {
+	return r.mem.GetTag(ctx, id)
+}
+
+func (r *TodoRepository) ListTags(ctx context.Context) ([]domain.Tag, error) {
+	return r.mem.ListTags(ctx)
+}
+
+func (r *TodoRepository) UpdateTag(ctx context.Context, id int, input domain.UpdateTagInput) (*domain.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	prev := *current
+
+	tag, err := r.mem.UpdateTag(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// replaying the tag record renames it on todos again, no todo records needed
+	saved := *tag
+	if err := r.appendLocked(record{Op: opPutTag, Tag: &saved}); err != nil {
+		r.mem.RestoreTag(prev)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (r *TodoRepository) DeleteTag(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetTag(ctx, id)
+	if err != nil {
+		return err
+	}
+	prev := *current
+
+	// remember which todos carried the tag so a failed append can reattach it
+	tagged, err := r.mem.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Tags: []string{prev.Name}}})
+	if err != nil {
+		return err
+	}
+
+	if err := r.mem.DeleteTag(ctx, id); err != nil {
+		return err
+	}
+
+	if err := r.appendLocked(record{Op: opDeleteTag, ID: id}); err != nil {
+		r.mem.RestoreTag(prev)
+		for _, todo := range tagged.Items {
+			r.mem.Restore(todo)
+		}
+		return err
+	}
+
+	return nil
+}
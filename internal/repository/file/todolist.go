@@ -0,0 +1,120 @@
+package file
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) CreateList(ctx context.Context, input domain.CreateListInput) (*domain.TodoList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, err := r.mem.CreateList(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *list
+	if err := r.appendLocked(record{Op: opPutList, List: &saved}); err != nil {
+		_ = r.mem.DeleteList(ctx, list.ID, false)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (r *TodoRepository) GetList(ctx context.Context, id int) (*domain.TodoList, error) {
+	return r.mem.GetList(ctx, id)
+}
+
+func (r *TodoRepository) GetLists(ctx context.Context) ([]domain.TodoList, error) {
+	return r.mem.GetLists(ctx)
+}
+
+func (r *TodoRepository) UpdateList(ctx context.Context, id int, input domain.UpdateListInput) (*domain.TodoList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetList(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	prev := *current
+
+	list, err := r.mem.UpdateList(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *list
+	if err := r.appendLocked(record{Op: opPutList, List: &saved}); err != nil {
+		r.mem.RestoreList(prev)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (r *TodoRepository) DeleteList(ctx context.Context, id int, cascade bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.mem.GetList(ctx, id)
+	if err != nil {
+		return err
+	}
+	prev := *current
+
+	// the todos of the list move to the trash and out of the list, keep
+	// them for rollback
+	contained, err := r.mem.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{ListID: &id}})
+	if err != nil {
+		return err
+	}
+	affected := append(contained.Items, r.trashedIn(id)...)
+
+	var deps []domain.Dependency
+	if cascade {
+		for _, todo := range contained.Items {
+			deps = append(deps, r.mem.DependenciesOf(todo.ID)...)
+		}
+	}
+
+	if err := r.mem.DeleteList(ctx, id, cascade); err != nil {
+		return err
+	}
+
+	var recs []record
+	for _, todo := range affected {
+		saved, _ := r.mem.GetTrashed(todo.ID)
+		recs = append(recs, record{Op: opPut, Todo: &saved})
+	}
+	recs = append(recs, record{Op: opDeleteList, ID: id, Cascade: cascade})
+
+	if err := r.appendLocked(record{Op: opBatch, Records: recs}); err != nil {
+		r.mem.RestoreList(prev)
+		for _, todo := range affected {
+			r.mem.Restore(todo)
+		}
+		for _, dep := range deps {
+			r.mem.RestoreDependency(dep)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// trashedIn returns the todos in the trash that belong to list id.
+func (r *TodoRepository) trashedIn(id int) []domain.Todo {
+	trash, _ := r.mem.ListTrash(context.Background())
+
+	var in []domain.Todo
+	for _, todo := range trash {
+		if todo.ListID == id {
+			in = append(in, todo)
+		}
+	}
+	return in
+}
@@ -0,0 +1,71 @@
+package file
+
+import (
+	"context"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) ListTrash(ctx context.Context) ([]domain.Todo, error) {
+	return r.mem.ListTrash(ctx)
+}
+
+func (r *TodoRepository) RestoreDeleted(ctx context.Context, id int) (*domain.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, trashed := r.mem.GetTrashed(id)
+	if !trashed {
+		return nil, domain.ErrTodoNotFound
+	}
+
+	todo, err := r.mem.RestoreDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *todo
+	if err := r.appendLocked(record{Op: opPut, Todo: &saved}); err != nil {
+		r.mem.Restore(prev)
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+// PurgeTrash logs the purge as one record, so it is replayed whole or not
+// at all.
+func (r *TodoRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trash, err := r.mem.ListTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged []domain.Todo
+	var recs []record
+	for _, todo := range trash {
+		if todo.DeletedAt.Before(before) {
+			purged = append(purged, todo)
+			recs = append(recs, record{Op: opDelete, ID: todo.ID})
+		}
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	for _, todo := range purged {
+		r.mem.Purge(todo.ID)
+	}
+	if err := r.appendLocked(record{Op: opBatch, Records: recs}); err != nil {
+		for _, todo := range purged {
+			r.mem.Restore(todo)
+		}
+		return 0, err
+	}
+
+	return len(purged), nil
+}
@@ -0,0 +1,37 @@
+package file
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// InTx runs fn on a copy of the working set and, when fn succeeds, logs its
+// changes as a single record before making them visible. Writes wait until
+// fn returns, reads see the state from before the transaction.
+func (r *TodoRepository) InTx(ctx context.Context, fn func(repo domain.TodoRepository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx := &TodoRepository{mem: r.mem.Clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.pending) == 0 {
+		return nil
+	}
+	if r.inTx {
+		// nested, the changes join the enclosing transaction
+		r.pending = append(r.pending, tx.pending...)
+		r.mem.Adopt(tx.mem)
+		return nil
+	}
+
+	// logged by hand, a snapshot must not be taken before the changes are in
+	if err := r.wal.append(record{Op: opBatch, Records: tx.pending}); err != nil {
+		return err
+	}
+	r.mem.Adopt(tx.mem)
+	r.maybeSnapshotLocked()
+	return nil
+}
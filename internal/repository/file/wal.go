@@ -0,0 +1,314 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the log after every record.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval fsyncs the log in the background every SyncInterval.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+func ParseSyncPolicy(policy string) (SyncPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "", "always":
+		return SyncAlways, nil
+	case "interval":
+		return SyncInterval, nil
+	case "never", "none":
+		return SyncNever, nil
+	default:
+		return 0, fmt.Errorf("unknown sync policy %q", policy)
+	}
+}
+
+const (
+	opPut       = "put"
+	opDelete    = "delete"
+	opPutTag    = "put_tag"
+	opDeleteTag = "delete_tag"
+
+	opPutList    = "put_list"
+	opDeleteList = "delete_list"
+
+	opPutDependency    = "put_dependency"
+	opDeleteDependency = "delete_dependency"
+
+	opPutWebhook    = "put_webhook"
+	opDeleteWebhook = "delete_webhook"
+
+	// opBatch holds the records of a transaction, applied all together
+	opBatch = "batch"
+)
+
+type record struct {
+	Seq  uint64       `json:"seq"`
+	Op   string       `json:"op"`
+	ID   int          `json:"id,omitempty"`
+	Todo *domain.Todo `json:"todo,omitempty"`
+	Tag  *domain.Tag  `json:"tag,omitempty"`
+
+	List    *domain.TodoList `json:"list,omitempty"`
+	Cascade bool             `json:"cascade,omitempty"`
+
+	Dependency *domain.Dependency `json:"dependency,omitempty"`
+
+	Webhook *domain.Webhook `json:"webhook,omitempty"`
+
+	Records []record `json:"records,omitempty"`
+}
+
+var errCorruptRecord = errors.New("corrupt wal record")
+
+// Every record is written as one line: the IEEE CRC-32 of the JSON payload as
+// 8 hex digits, a space, the payload and a newline. A crash can leave the last
+// line incomplete or with a bad checksum; that tail is cut off on replay.
+//
+// Logs written before records had checksums hold bare JSON lines without a
+// sequence number. They are still read, numbered by their position.
+const crcWidth = 8
+
+type wal struct {
+	mu     sync.Mutex
+	f      *os.File
+	policy SyncPolicy
+	dirty  bool
+	seq    uint64
+	count  int // records written since the log was last reset
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func openWAL(path string, policy SyncPolicy, interval time.Duration) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &wal{f: f, policy: policy}
+	if policy == SyncInterval {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.syncLoop(interval)
+	}
+
+	return w, nil
+}
+
+// replay feeds every record with a sequence number above after to apply. A
+// torn final record is truncated away and reported through torn; damage
+// anywhere before the tail is returned as errCorruptRecord.
+func (w *wal) replay(after uint64, apply func(record) error) (torn bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.seq = after
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	rd := bufio.NewReader(w.f)
+	var (
+		offset int64
+		last   uint64
+	)
+	for line := 1; ; line++ {
+		b, rerr := rd.ReadBytes('\n')
+		if errors.Is(rerr, io.EOF) && len(b) == 0 {
+			break
+		}
+		if rerr != nil && !errors.Is(rerr, io.EOF) {
+			return false, rerr
+		}
+
+		rec, derr := decodeRecord(b)
+		if derr != nil {
+			if _, perr := rd.Peek(1); !errors.Is(perr, io.EOF) {
+				return false, fmt.Errorf("wal record %d: %w", line, derr)
+			}
+			if err := w.truncate(offset); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+
+		offset += int64(len(b))
+		w.count++
+		if rec.Seq == 0 {
+			rec.Seq = last + 1
+		}
+		last = rec.Seq
+		if rec.Seq <= after {
+			continue
+		}
+		if aerr := apply(rec); aerr != nil {
+			return false, fmt.Errorf("wal record %d: %w", line, aerr)
+		}
+		w.seq = rec.Seq
+	}
+
+	_, err = w.f.Seek(0, io.SeekEnd)
+	return false, err
+}
+
+func decodeRecord(b []byte) (record, error) {
+	var rec record
+
+	if len(b) > 0 && b[0] == '{' {
+		return decodeLegacyRecord(b)
+	}
+	if len(b) < crcWidth+2 || b[len(b)-1] != '\n' || b[crcWidth] != ' ' {
+		return rec, errCorruptRecord
+	}
+
+	sum, err := strconv.ParseUint(string(b[:crcWidth]), 16, 32)
+	if err != nil {
+		return rec, errCorruptRecord
+	}
+
+	payload := b[crcWidth+1 : len(b)-1]
+	if crc32.ChecksumIEEE(payload) != uint32(sum) {
+		return rec, errCorruptRecord
+	}
+
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+	return rec, nil
+}
+
+// decodeLegacyRecord reads a line of a log written before checksums. It has
+// no sequence number, replay gives it one.
+func decodeLegacyRecord(b []byte) (record, error) {
+	var rec record
+
+	if b[len(b)-1] != '\n' {
+		return rec, errCorruptRecord
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return rec, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+	rec.Seq = 0
+	return rec, nil
+}
+
+func (w *wal) append(rec record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec.Seq = w.seq + 1
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("%0*x %s\n", crcWidth, crc32.ChecksumIEEE(payload), payload)
+	if _, err := w.f.WriteString(line); err != nil {
+		return err
+	}
+	w.seq = rec.Seq
+	w.count++
+
+	switch w.policy {
+	case SyncAlways:
+		return w.f.Sync()
+	case SyncInterval:
+		w.dirty = true
+	}
+	return nil
+}
+
+func (w *wal) lastSeq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.seq
+}
+
+func (w *wal) size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.count
+}
+
+// reset drops every record; the caller must have made them durable elsewhere.
+func (w *wal) reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.count = 0
+	return w.truncate(0)
+}
+
+func (w *wal) truncate(size int64) error {
+	if err := w.f.Truncate(size); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	w.dirty = false
+
+	_, err := w.f.Seek(size, io.SeekStart)
+	return err
+}
+
+func (w *wal) syncLoop(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.dirty {
+				_ = w.f.Sync()
+				w.dirty = false
+			}
+			w.mu.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *wal) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
@@ -0,0 +1,75 @@
+package file
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) CreateWebhook(ctx context.Context, input domain.CreateWebhookInput) (*domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook, err := r.mem.CreateWebhook(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.appendLocked(record{Op: opPutWebhook, Webhook: hook}); err != nil {
+		_ = r.mem.DeleteWebhook(ctx, hook.ID)
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+func (r *TodoRepository) GetWebhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	return r.mem.GetWebhook(ctx, id)
+}
+
+func (r *TodoRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	return r.mem.ListWebhooks(ctx)
+}
+
+func (r *TodoRepository) UpdateWebhook(ctx context.Context, id int, input domain.UpdateWebhookInput) (*domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, err := r.mem.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := r.mem.UpdateWebhook(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.appendLocked(record{Op: opPutWebhook, Webhook: hook}); err != nil {
+		r.mem.RestoreWebhook(*prev)
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+func (r *TodoRepository) DeleteWebhook(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, err := r.mem.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.mem.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+
+	if err := r.appendLocked(record{Op: opDeleteWebhook, ID: id}); err != nil {
+		r.mem.RestoreWebhook(*prev)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) AddDependency(ctx context.Context, dep domain.Dependency) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.todos[dep.TodoID]; !exists {
+		return domain.ErrTodoNotFound
+	}
+	if _, exists := r.todos[dep.BlockerID]; !exists {
+		return domain.ErrTodoNotFound
+	}
+	if r.blockers[dep.TodoID][dep.BlockerID] {
+		return domain.ErrDependencyExists
+	}
+	if dep.TodoID == dep.BlockerID || r.dependsOn(dep.BlockerID, dep.TodoID) {
+		return domain.ErrDependencyCycle
+	}
+
+	r.addEdge(dep)
+	return nil
+}
+
+func (r *TodoRepository) RemoveDependency(ctx context.Context, dep domain.Dependency) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.blockers[dep.TodoID][dep.BlockerID] {
+		return domain.ErrDependencyNotFound
+	}
+
+	r.removeEdge(dep)
+	return nil
+}
+
+func (r *TodoRepository) ListDependencies(ctx context.Context) ([]domain.Dependency, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deps := []domain.Dependency{}
+	for todoID, blockers := range r.blockers {
+		for blockerID := range blockers {
+			deps = append(deps, domain.Dependency{TodoID: todoID, BlockerID: blockerID})
+		}
+	}
+	sortDependencies(deps)
+
+	return deps, nil
+}
+
+// DependenciesOf returns the edges into and out of the todo with id, so a
+// failed delete can put them back with RestoreDependency.
+func (r *TodoRepository) DependenciesOf(id int) []domain.Dependency {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deps []domain.Dependency
+	for blockerID := range r.blockers[id] {
+		deps = append(deps, domain.Dependency{TodoID: id, BlockerID: blockerID})
+	}
+	for todoID := range r.blocking[id] {
+		deps = append(deps, domain.Dependency{TodoID: todoID, BlockerID: id})
+	}
+	sortDependencies(deps)
+	return deps
+}
+
+// RestoreDependency puts an edge back without checking the todos exist. See
+// Restore.
+func (r *TodoRepository) RestoreDependency(dep domain.Dependency) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addEdge(dep)
+}
+
+func (r *TodoRepository) addEdge(dep domain.Dependency) {
+	if r.blockers[dep.TodoID] == nil {
+		r.blockers[dep.TodoID] = make(map[int]bool)
+	}
+	r.blockers[dep.TodoID][dep.BlockerID] = true
+
+	if r.blocking[dep.BlockerID] == nil {
+		r.blocking[dep.BlockerID] = make(map[int]bool)
+	}
+	r.blocking[dep.BlockerID][dep.TodoID] = true
+}
+
+func (r *TodoRepository) removeEdge(dep domain.Dependency) {
+	delete(r.blockers[dep.TodoID], dep.BlockerID)
+	if len(r.blockers[dep.TodoID]) == 0 {
+		delete(r.blockers, dep.TodoID)
+	}
+
+	delete(r.blocking[dep.BlockerID], dep.TodoID)
+	if len(r.blocking[dep.BlockerID]) == 0 {
+		delete(r.blocking, dep.BlockerID)
+	}
+}
+
+// dependsOn reports whether from is blocked by to, directly or through other
+// todos.
+func (r *TodoRepository) dependsOn(from, to int) bool {
+	seen := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for b := range r.blockers[id] {
+			if b == to {
+				return true
+			}
+			if !seen[b] {
+				seen[b] = true
+				queue = append(queue, b)
+			}
+		}
+	}
+	return false
+}
+
+// removeEdges drops every edge into and out of the todo with id.
+func (r *TodoRepository) removeEdges(id int) {
+	for blockerID := range r.blockers[id] {
+		r.removeEdge(domain.Dependency{TodoID: id, BlockerID: blockerID})
+	}
+	for todoID := range r.blocking[id] {
+		r.removeEdge(domain.Dependency{TodoID: todoID, BlockerID: id})
+	}
+}
+
+// view returns a copy of todo with its blockers filled in.
+func (r *TodoRepository) view(todo *domain.Todo) domain.Todo {
+	c := copyTodo(todo)
+	for blockerID := range r.blockers[todo.ID] {
+		c.BlockedBy = append(c.BlockedBy, blockerID)
+	}
+	sort.Ints(c.BlockedBy)
+	c.Blocked = r.blocked(todo.ID)
+	return c
+}
+
+// blocked reports whether any blocker of the todo with id is still open.
+func (r *TodoRepository) blocked(id int) bool {
+	for blockerID := range r.blockers[id] {
+		if blocker, exists := r.todos[blockerID]; exists && !blocker.Completed {
+			return true
+		}
+	}
+	return false
+}
+
+func sortDependencies(deps []domain.Dependency) {
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].TodoID != deps[j].TodoID {
+			return deps[i].TodoID < deps[j].TodoID
+		}
+		return deps[i].BlockerID < deps[j].BlockerID
+	})
+}
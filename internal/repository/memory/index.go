@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// BM25 parameters, the weight of a title hit relative to a description hit
+// and those of a word starting with the searched one, and of a word merely
+// containing it, relative to the word itself.
+const (
+	bm25K1        = 1.2
+	bm25B         = 0.75
+	titleWeight   = 2
+	prefixWeight  = 0.75
+	partialWeight = 0.5
+)
+
+type posting struct {
+	weight float64 // term frequency with title hits boosted
+}
+
+// searchIndex is an inverted index over the words of title and description.
+// It finds the todos having, for each word of a term, a word the term
+// matches; callers still check phrases, whose words must also come in a row.
+// Callers serialize access through the repository lock.
+type searchIndex struct {
+	postings map[string]map[int]*posting
+	terms    []string // sorted keys of postings, for prefixes
+	// grams maps every run of up to gramLen letters to the indexed words
+	// containing it, for words searched inside longer words
+	grams    map[string]map[string]struct{}
+	docLen   map[int]int
+	totalLen int
+}
+
+const gramLen = 3
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[int]*posting),
+		grams:    make(map[string]map[string]struct{}),
+		docLen:   make(map[int]int),
+	}
+}
+
+// documentTokens returns the words of a todo's title and description.
+func documentTokens(todo *domain.Todo) (title, desc []string) {
+	return domain.Tokenize(todo.Title), domain.Tokenize(todo.Description)
+}
+
+func (ix *searchIndex) add(todo *domain.Todo) {
+	title, desc := documentTokens(todo)
+
+	put := func(tok string, weight float64) {
+		docs, ok := ix.postings[tok]
+		if !ok {
+			docs = make(map[int]*posting)
+			ix.postings[tok] = docs
+			ix.insertTerm(tok)
+		}
+		p, ok := docs[todo.ID]
+		if !ok {
+			p = &posting{}
+			docs[todo.ID] = p
+		}
+		p.weight += weight
+	}
+
+	for _, tok := range title {
+		put(tok, titleWeight)
+	}
+	for _, tok := range desc {
+		put(tok, 1)
+	}
+
+	n := len(title) + len(desc)
+	ix.docLen[todo.ID] = n
+	ix.totalLen += n
+}
+
+func (ix *searchIndex) remove(todo *domain.Todo) {
+	title, desc := documentTokens(todo)
+
+	for _, tok := range append(title, desc...) {
+		docs, ok := ix.postings[tok]
+		if !ok {
+			continue
+		}
+		delete(docs, todo.ID)
+		if len(docs) == 0 {
+			delete(ix.postings, tok)
+			ix.deleteTerm(tok)
+		}
+	}
+
+	ix.totalLen -= ix.docLen[todo.ID]
+	delete(ix.docLen, todo.ID)
+}
+
+func (ix *searchIndex) insertTerm(tok string) {
+	i := sort.SearchStrings(ix.terms, tok)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = tok
+
+	for _, gram := range grams(tok) {
+		words, ok := ix.grams[gram]
+		if !ok {
+			words = make(map[string]struct{})
+			ix.grams[gram] = words
+		}
+		words[tok] = struct{}{}
+	}
+}
+
+func (ix *searchIndex) deleteTerm(tok string) {
+	i := sort.SearchStrings(ix.terms, tok)
+	if i < len(ix.terms) && ix.terms[i] == tok {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+
+	for _, gram := range grams(tok) {
+		delete(ix.grams[gram], tok)
+		if len(ix.grams[gram]) == 0 {
+			delete(ix.grams, gram)
+		}
+	}
+}
+
+// grams returns the distinct runs of 1 to gramLen letters of word.
+func grams(word string) []string {
+	runes := []rune(word)
+	seen := make(map[string]bool)
+	var out []string
+	for i := range runes {
+		for n := 1; n <= gramLen && i+n <= len(runes); n++ {
+			if gram := string(runes[i : i+n]); !seen[gram] {
+				seen[gram] = true
+				out = append(out, gram)
+			}
+		}
+	}
+	return out
+}
+
+// containing returns the indexed words containing sub. A short sub is a
+// gram itself; for a longer one the words sharing its rarest gram are
+// checked.
+func (ix *searchIndex) containing(sub string) []string {
+	runes := []rune(sub)
+	if len(runes) <= gramLen {
+		words := make([]string, 0, len(ix.grams[sub]))
+		for word := range ix.grams[sub] {
+			words = append(words, word)
+		}
+		return words
+	}
+
+	var rarest map[string]struct{}
+	for i := 0; i+gramLen <= len(runes); i++ {
+		words, ok := ix.grams[string(runes[i:i+gramLen])]
+		if !ok {
+			return nil
+		}
+		if rarest == nil || len(words) < len(rarest) {
+			rarest = words
+		}
+	}
+
+	var words []string
+	for word := range rarest {
+		if strings.Contains(word, sub) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// search returns the BM25 score of every todo that may match all terms. A
+// query without terms matches nothing here; callers treat it as no search at
+// all.
+func (ix *searchIndex) search(terms []domain.SearchTerm) map[int]float64 {
+	var scores map[int]float64
+
+	for _, term := range terms {
+		for _, tok := range term.Tokens {
+			hits := ix.score(ix.lookup(term, tok))
+			if scores == nil {
+				scores = hits
+				continue
+			}
+			for id := range scores {
+				if s, ok := hits[id]; ok {
+					scores[id] += s
+				} else {
+					delete(scores, id)
+				}
+			}
+			if len(scores) == 0 {
+				return scores
+			}
+		}
+	}
+
+	return scores
+}
+
+// lookup returns the indexed words tok of term can match, with the weight
+// of a hit on each.
+func (ix *searchIndex) lookup(term domain.SearchTerm, tok string) map[string]float64 {
+	words := make(map[string]float64)
+	switch {
+	case term.IsPhrase():
+		if _, ok := ix.postings[tok]; ok {
+			words[tok] = 1
+		}
+	case term.Prefix:
+		for i := sort.SearchStrings(ix.terms, tok); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], tok); i++ {
+			words[ix.terms[i]] = wordWeight(ix.terms[i], tok)
+		}
+	default:
+		for _, word := range ix.containing(tok) {
+			words[word] = wordWeight(word, tok)
+		}
+	}
+	return words
+}
+
+func wordWeight(word, tok string) float64 {
+	switch {
+	case word == tok:
+		return 1
+	case strings.HasPrefix(word, tok):
+		return prefixWeight
+	default:
+		return partialWeight
+	}
+}
+
+// score scores the todos with any of words as if each were the searched
+// word, weighted as given.
+func (ix *searchIndex) score(words map[string]float64) map[int]float64 {
+	tf := make(map[int]float64)
+	for word, weight := range words {
+		for id, p := range ix.postings[word] {
+			tf[id] += p.weight * weight
+		}
+	}
+
+	for id, f := range tf {
+		tf[id] = ix.bm25(f, len(tf), id)
+	}
+	return tf
+}
+
+func (ix *searchIndex) bm25(tf float64, df, id int) float64 {
+	n := float64(len(ix.docLen))
+	idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+
+	avgLen := float64(ix.totalLen) / n
+	if avgLen == 0 {
+		avgLen = 1
+	}
+	norm := 1 - bm25B + bm25B*float64(ix.docLen[id])/avgLen
+
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func TestSearch_RanksByRelevance(t *testing.T) {
+	repo := NewTodoRepository()
+	ctx := context.Background()
+
+	desc, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Groceries", Description: "eggs and milk"})
+	title, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Buy milk"})
+	partial, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Party", Description: "eggs and milkshake"})
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "Call mom"})
+
+	keys, _ := domain.ParseSort(domain.DefaultSearchSort)
+	page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Search: "milk"}, Sort: keys})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	if len(page.Items) != 3 {
+		t.Fatalf("expected the todos containing milk, got %+v", page.Items)
+	}
+	if page.Items[0].ID != title.ID || page.Items[1].ID != desc.ID || page.Items[2].ID != partial.ID {
+		t.Errorf("expected the title match first and the partial word last, got %d, %d, %d", page.Items[0].ID, page.Items[1].ID, page.Items[2].ID)
+	}
+	if page.Items[0].Score <= page.Items[1].Score || page.Items[1].Score <= page.Items[2].Score || page.Items[2].Score <= 0 {
+		t.Errorf("expected descending positive scores, got %v, %v, %v", page.Items[0].Score, page.Items[1].Score, page.Items[2].Score)
+	}
+
+	got, _ := repo.GetByID(ctx, title.ID)
+	if got.Score != 0 {
+		t.Errorf("expected score only on search results, got %v", got.Score)
+	}
+}
+
+func TestSearch_RanksWholeWordsThenPrefixesThenParts(t *testing.T) {
+	repo := NewTodoRepository()
+	ctx := context.Background()
+
+	inside, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call family"})
+	prefix, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call mild"})
+	whole, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Call mil"})
+	_, _ = repo.Create(ctx, domain.CreateTodoInput{Title: "Call mom"})
+
+	keys, _ := domain.ParseSort(domain.DefaultSearchSort)
+	for search, want := range map[string][]int{
+		"mil":  {whole.ID, prefix.ID, inside.ID},
+		"mil*": {whole.ID, prefix.ID},
+	} {
+		page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Search: search}, Sort: keys})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		var got []int
+		for _, todo := range page.Items {
+			got = append(got, todo.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %v, got %v", search, want, got)
+		}
+	}
+}
+
+func TestSearch_IndexFollowsWrites(t *testing.T) {
+	repo := NewTodoRepository()
+	ctx := context.Background()
+
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Buy milk"})
+	search := func(q string) int {
+		todos, _ := repo.GetFiltered(ctx, nil, q)
+		return len(todos)
+	}
+
+	newTitle := "Buy bread"
+	_, _ = repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Title: &newTitle})
+	if search("milk") != 0 || search("bread") != 1 {
+		t.Error("expected the index to follow the updated title")
+	}
+	if search("ilk") != 0 || search("rea") != 1 || search("brea") != 1 || search("bred") != 0 {
+		t.Error("expected words searched inside others to follow the updated title")
+	}
+
+	done := true
+	_, _ = repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+	if search(`"buy bread"`) != 1 {
+		t.Error("expected an update that keeps the text to keep the todo indexed")
+	}
+
+	_ = repo.Delete(ctx, todo.ID)
+	if search("bread") != 0 || search("b*") != 0 {
+		t.Error("expected a deleted todo to leave the index")
+	}
+	if len(repo.index.terms) != 0 || len(repo.index.postings) != 0 || len(repo.index.grams) != 0 {
+		t.Errorf("expected an empty index, got terms %v", repo.index.terms)
+	}
+}
+
+var benchWords = strings.Fields("buy milk bread call mom write report review code plan trip fix bug " +
+	"send invoice book flight clean kitchen water plants pay rent update resume read paper")
+
+func benchRepo(b *testing.B, n int) *TodoRepository {
+	b.Helper()
+	repo := NewTodoRepository()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		title := fmt.Sprintf("%s %s %d", benchWords[i%len(benchWords)], benchWords[(i/7)%len(benchWords)], i)
+		desc := fmt.Sprintf("%s %s %s", benchWords[(i*3)%len(benchWords)], benchWords[(i*5)%len(benchWords)], benchWords[(i/3)%len(benchWords)])
+		if _, err := repo.Create(ctx, domain.CreateTodoInput{Title: title, Description: desc}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+// scanSearch is the substring scan GetFiltered used before the index.
+func scanSearch(r *TodoRepository, search string) []domain.Todo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	search = strings.ToLower(search)
+	var found []domain.Todo
+	for _, todo := range r.todos {
+		if strings.Contains(strings.ToLower(todo.Title), search) ||
+			strings.Contains(strings.ToLower(todo.Description), search) {
+			found = append(found, *todo)
+		}
+	}
+	return found
+}
+
+// BenchmarkSearch compares the index with the old scan for a word found in
+// about a tenth of the todos and for one found in a single todo.
+func BenchmarkSearch(b *testing.B) {
+	queries := []struct{ name, search string }{
+		{"Common", "invoice"},
+		{"Rare", "777"},
+	}
+
+	for _, n := range []int{1000, 10000, 100000} {
+		repo := benchRepo(b, n)
+		ctx := context.Background()
+
+		for _, q := range queries {
+			b.Run(fmt.Sprintf("Scan/%s/%d", q.name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					scanSearch(repo, q.search)
+				}
+			})
+			b.Run(fmt.Sprintf("Index/%s/%d", q.name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := repo.GetFiltered(ctx, nil, q.search); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
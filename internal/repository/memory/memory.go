@@ -2,7 +2,9 @@ package memory
 
 import (
 	"context"
-	"strings"
+	"fmt"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,13 +14,42 @@ import (
 type TodoRepository struct {
 	mu     sync.RWMutex
 	todos  map[int]*domain.Todo
+	index  *searchIndex
 	nextID int
+	trash  map[int]*domain.Todo // deleted todos, kept out of todos and index
+
+	tags      map[int]*domain.Tag
+	tagIDs    map[string]int // by name
+	nextTagID int
+
+	lists      map[int]*domain.TodoList
+	nextListID int
+
+	blockers map[int]map[int]bool // todo id -> ids of its blockers
+	blocking map[int]map[int]bool // blocker id -> ids of the todos it blocks
+
+	webhooks      map[int]*domain.Webhook
+	nextWebhookID int
 }
 
 func NewTodoRepository() *TodoRepository {
 	return &TodoRepository{
-		todos:  make(map[int]*domain.Todo),
-		nextID: 1,
+		todos:     make(map[int]*domain.Todo),
+		index:     newSearchIndex(),
+		nextID:    1,
+		trash:     make(map[int]*domain.Todo),
+		tags:      make(map[int]*domain.Tag),
+		tagIDs:    make(map[string]int),
+		nextTagID: 1,
+
+		lists:      make(map[int]*domain.TodoList),
+		nextListID: 1,
+
+		blockers: make(map[int]map[int]bool),
+		blocking: make(map[int]map[int]bool),
+
+		webhooks:      make(map[int]*domain.Webhook),
+		nextWebhookID: 1,
 	}
 }
 
@@ -26,23 +57,48 @@ func (r *TodoRepository) Create(ctx context.Context, input domain.CreateTodoInpu
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if err := r.checkTags(input.Tags); err != nil {
+		return nil, err
+	}
+	if err := r.checkList(input.ListID); err != nil {
+		return nil, err
+	}
+	if err := r.checkParent(0, input.ParentID); err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	todo := &domain.Todo{
 		ID:          r.nextID,
 		Title:       input.Title,
 		Description: input.Description,
 		Completed:   input.Completed,
+		Priority:    input.Priority,
+		DueAt:       cloneTime(input.DueAt),
+		Tags:        tagSet(input.Tags),
+		ListID:      input.ListID,
+		ParentID:    input.ParentID,
+		Version:     1,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+
+		AutoComplete: input.AutoComplete,
+
+		Recurrence:           input.Recurrence,
+		Occurrence:           input.Occurrence,
+		PreviousOccurrenceID: input.PreviousOccurrenceID,
+		RemindAt:             cloneTime(input.RemindAt),
 	}
 
 	r.todos[r.nextID] = todo
+	r.index.add(todo)
 	r.nextID++
 
-	return todo, nil
+	created := r.view(todo)
+	return &created, nil
 }
 
-// указатель или копию мб датарейс 0_о
+// returns a copy, handing out the stored pointer would race with Update
 func (r *TodoRepository) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -52,7 +108,8 @@ func (r *TodoRepository) GetByID(ctx context.Context, id int) (*domain.Todo, err
 		return nil, domain.ErrTodoNotFound
 	}
 
-	return todo, nil
+	found := r.view(todo)
+	return &found, nil
 }
 
 func (r *TodoRepository) GetAll(ctx context.Context) ([]domain.Todo, error) {
@@ -61,8 +118,9 @@ func (r *TodoRepository) GetAll(ctx context.Context) ([]domain.Todo, error) {
 
 	todos := make([]domain.Todo, 0, len(r.todos))
 	for _, todo := range r.todos {
-		todos = append(todos, *todo)
+		todos = append(todos, r.view(todo))
 	}
+	sortByID(todos)
 
 	return todos, nil
 }
@@ -75,6 +133,31 @@ func (r *TodoRepository) Update(ctx context.Context, id int, input domain.Update
 	if !exists {
 		return nil, domain.ErrTodoNotFound
 	}
+	if input.Completed != nil && *input.Completed && !todo.Completed && r.blocked(id) {
+		return nil, domain.ErrTodoBlocked
+	}
+	if input.Version != nil && *input.Version != todo.Version {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	if input.Tags != nil {
+		if err := r.checkTags(*input.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if input.ListID != nil {
+		if err := r.checkList(*input.ListID); err != nil {
+			return nil, err
+		}
+	}
+	if input.ParentID != nil {
+		if err := r.checkParent(id, *input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	r.index.remove(todo)
+	defer r.index.add(todo)
 
 	if input.Title != nil {
 		todo.Title = *input.Title
@@ -88,38 +171,301 @@ func (r *TodoRepository) Update(ctx context.Context, id int, input domain.Update
 		todo.Completed = *input.Completed
 	}
 
+	if input.Priority != nil {
+		todo.Priority = *input.Priority
+	}
+
+	if input.DueAt != nil {
+		todo.DueAt = cloneTime(input.DueAt)
+	} else if input.ClearDueAt {
+		todo.DueAt = nil
+	}
+
+	if input.Tags != nil {
+		todo.Tags = tagSet(*input.Tags)
+	}
+
+	if input.ListID != nil {
+		todo.ListID = *input.ListID
+	}
+
+	if input.ParentID != nil {
+		todo.ParentID = *input.ParentID
+	}
+
+	if input.AutoComplete != nil {
+		todo.AutoComplete = *input.AutoComplete
+	}
+
+	if input.Recurrence != nil {
+		todo.Recurrence = *input.Recurrence
+	}
+
+	if input.Occurrence != nil {
+		todo.Occurrence = *input.Occurrence
+	}
+
+	if input.NextOccurrenceID != nil {
+		todo.NextOccurrenceID = *input.NextOccurrenceID
+	}
+
+	if input.RemindAt != nil {
+		todo.RemindAt = cloneTime(input.RemindAt)
+	} else if input.ClearRemindAt {
+		todo.RemindAt = nil
+	}
+
+	if input.RemindedAt != nil {
+		todo.RemindedAt = cloneTime(input.RemindedAt)
+	}
+
+	todo.Version++
 	todo.UpdatedAt = time.Now()
 
-	return todo, nil
+	updated := r.view(todo)
+	return &updated, nil
 }
 
 func (r *TodoRepository) Delete(ctx context.Context, id int) error {
+	return r.delete(id, 0)
+}
+
+// DeleteVersion deletes the todo only while it is at version.
+func (r *TodoRepository) DeleteVersion(ctx context.Context, id, version int) error {
+	if version <= 0 {
+		return domain.ErrInvalidVersion
+	}
+	return r.delete(id, version)
+}
+
+// delete moves the todo to the trash, checking its version unless version
+// is 0.
+func (r *TodoRepository) delete(id, version int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.todos[id]; !exists {
+	todo, exists := r.todos[id]
+	if !exists {
 		return domain.ErrTodoNotFound
 	}
+	if version != 0 && todo.Version != version {
+		return domain.ErrVersionMismatch
+	}
 
-	delete(r.todos, id)
+	r.trashLocked(todo, time.Now())
 	return nil
 }
 
+func (r *TodoRepository) trashLocked(todo *domain.Todo, now time.Time) {
+	r.remove(todo)
+	todo.DeletedAt = &now
+	todo.Version++
+	r.trash[todo.ID] = todo
+}
+
+// Restore puts a todo with a known ID back into the store, used when state is
+// rebuilt from durable storage or a failed write has to be rolled back. A
+// todo with DeletedAt set goes to the trash and loses its dependencies, as
+// Delete would have left it.
+func (r *TodoRepository) Restore(todo domain.Todo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, exists := r.todos[todo.ID]; exists {
+		r.index.remove(old)
+		delete(r.todos, todo.ID)
+	}
+	delete(r.trash, todo.ID)
+
+	t := copyTodo(&todo)
+	t.Score = 0
+	t.BlockedBy, t.Blocked = nil, false
+	if t.Version <= 0 {
+		// stored before todos had versions
+		t.Version = 1
+	}
+	if t.DeletedAt != nil {
+		r.removeEdges(t.ID)
+		r.trash[t.ID] = &t
+	} else {
+		r.todos[t.ID] = &t
+		r.index.add(&t)
+	}
+	if t.ID >= r.nextID {
+		r.nextID = t.ID + 1
+	}
+}
+
+// NextID reports the id the next Create will assign.
+func (r *TodoRepository) NextID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nextID
+}
+
+// SetNextID moves the id sequence forward so ids of todos deleted before a
+// restart are never handed out again. It never moves the sequence back.
+func (r *TodoRepository) SetNextID(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id > r.nextID {
+		r.nextID = id
+	}
+}
+
 func (r *TodoRepository) GetFiltered(ctx context.Context, completed *bool, search string) ([]domain.Todo, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	var filtered []domain.Todo
-	for _, todo := range r.todos {
-		if completed != nil && todo.Completed != *completed {
-			continue
+	filter := domain.TodoFilter{Completed: completed, Search: search}
+
+	candidates := r.candidates(filter.Search)
+	filtered := make([]domain.Todo, 0, len(candidates))
+	for _, todo := range candidates {
+		if filter.Match(todo) {
+			filtered = append(filtered, todo)
 		}
-		if search != "" && !strings.Contains(strings.ToLower(todo.Title), strings.ToLower(search)) &&
-			!strings.Contains(strings.ToLower(todo.Description), strings.ToLower(search)) {
+	}
+	sortByID(filtered)
+
+	return filtered, nil
+}
+
+func (r *TodoRepository) List(ctx context.Context, query domain.ListQuery) (*domain.TodoPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []domain.Todo
+	for _, todo := range r.candidates(query.Filter.Search) {
+		if query.Filter.Match(todo) {
+			matched = append(matched, todo)
+		}
+	}
+
+	keys := query.Sort
+	if len(keys) == 0 {
+		keys = domain.DefaultSort
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return domain.CompareTodos(matched[i], matched[j], keys) < 0
+	})
+
+	start := 0
+	if query.After != nil {
+		anchor := query.After.Todo()
+		start = sort.Search(len(matched), func(i int) bool {
+			return domain.CompareTodos(matched[i], anchor, keys) > 0
+		})
+	}
+
+	end := len(matched)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+
+	page := &domain.TodoPage{
+		Items: append(make([]domain.Todo, 0, end-start), matched[start:end]...),
+		Total: len(matched),
+	}
+	if end < len(matched) {
+		page.NextCursor = domain.CursorFor(matched[end-1], keys).Encode()
+	}
+
+	return page, nil
+}
+
+// candidates returns copies of the todos matching search, each carrying its
+// relevance score. Without search terms every todo is a candidate. The index
+// narrows the todos down, domain.MatchSearch decides.
+func (r *TodoRepository) candidates(search string) []domain.Todo {
+	terms := domain.ParseSearch(search)
+	if len(terms) == 0 {
+		todos := make([]domain.Todo, 0, len(r.todos))
+		for _, todo := range r.todos {
+			todos = append(todos, r.view(todo))
+		}
+		return todos
+	}
+
+	// The index matches words and prefixes exactly; only phrases, whose
+	// words must also come in a row, need checking against the todo.
+	check := slices.ContainsFunc(terms, domain.SearchTerm.IsPhrase)
+
+	scores := r.index.search(terms)
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	todos := make([]domain.Todo, 0, len(ids))
+	for _, id := range ids {
+		todo := r.view(r.todos[id])
+		if check && !domain.MatchSearch(todo, terms) {
 			continue
 		}
-		filtered = append(filtered, *todo)
+		todo.Score = scores[id]
+		todos = append(todos, todo)
 	}
+	return todos
+}
 
-	return filtered, nil
+// remove deletes a stored todo along with its index entries and edges.
+func (r *TodoRepository) remove(todo *domain.Todo) {
+	r.index.remove(todo)
+	r.removeEdges(todo.ID)
+	delete(r.todos, todo.ID)
+}
+
+// checkParent fails unless parent is 0 or names an existing todo that is
+// not id or one of its subtasks; id is 0 for a todo yet to be created.
+func (r *TodoRepository) checkParent(id, parent int) error {
+	if parent == 0 {
+		return nil
+	}
+	if _, exists := r.todos[parent]; !exists {
+		return fmt.Errorf("%w: %d", domain.ErrUnknownParent, parent)
+	}
+
+	seen := map[int]bool{}
+	for cur := parent; cur != 0 && !seen[cur]; {
+		if cur == id {
+			return domain.ErrParentCycle
+		}
+		seen[cur] = true
+		todo, exists := r.todos[cur]
+		if !exists {
+			break
+		}
+		cur = todo.ParentID
+	}
+	return nil
+}
+
+func sortByID(todos []domain.Todo) {
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID < todos[j].ID
+	})
+}
+
+// copyTodo returns a copy sharing no memory with the stored todo.
+func copyTodo(todo *domain.Todo) domain.Todo {
+	c := *todo
+	c.DueAt = cloneTime(todo.DueAt)
+	c.RemindAt = cloneTime(todo.RemindAt)
+	c.RemindedAt = cloneTime(todo.RemindedAt)
+	c.DeletedAt = cloneTime(todo.DeletedAt)
+	c.Tags = append([]string(nil), todo.Tags...)
+	return c
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
 }
@@ -6,8 +6,15 @@ import (
 	"testing"
 
 	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/repository/repositorytest"
 )
 
+func TestTodoRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) domain.TodoRepository {
+		return NewTodoRepository()
+	})
+}
+
 func TestTodoRepository_CreateGetUpdateDelete(t *testing.T) {
 	repo := NewTodoRepository()
 	ctx := context.Background()
@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) MarkReminded(ctx context.Context, id int, remindAt, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, exists := r.todos[id]
+	if !exists {
+		return domain.ErrTodoNotFound
+	}
+	if todo.RemindAt == nil || !todo.RemindAt.Equal(remindAt) {
+		return domain.ErrReminderMoved
+	}
+	todo.RemindedAt = cloneTime(&at)
+	return nil
+}
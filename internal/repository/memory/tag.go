@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) CreateTag(ctx context.Context, input domain.CreateTagInput) (*domain.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tagIDs[input.Name]; exists {
+		return nil, domain.ErrTagExists
+	}
+
+	now := time.Now()
+	tag := &domain.Tag{
+		ID:        r.nextTagID,
+		Name:      input.Name,
+		Color:     input.Color,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	r.tags[tag.ID] = tag
+	r.tagIDs[tag.Name] = tag.ID
+	r.nextTagID++
+
+	created := *tag
+	return &created, nil
+}
+
+func (r *TodoRepository) GetTag(ctx context.Context, id int) (*domain.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tag, exists := r.tags[id]
+	if !exists {
+		return nil, domain.ErrTagNotFound
+	}
+
+	found := *tag
+	return &found, nil
+}
+
+func (r *TodoRepository) ListTags(ctx context.Context) ([]domain.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tags := make([]domain.Tag, 0, len(r.tags))
+	for _, tag := range r.tags {
+		tags = append(tags, *tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (r *TodoRepository) UpdateTag(ctx context.Context, id int, input domain.UpdateTagInput) (*domain.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tag, exists := r.tags[id]
+	if !exists {
+		return nil, domain.ErrTagNotFound
+	}
+
+	if input.Name != nil && *input.Name != tag.Name {
+		if _, taken := r.tagIDs[*input.Name]; taken {
+			return nil, domain.ErrTagExists
+		}
+		r.renameTag(tag, *input.Name)
+	}
+
+	if input.Color != nil {
+		tag.Color = *input.Color
+	}
+
+	tag.UpdatedAt = time.Now()
+
+	updated := *tag
+	return &updated, nil
+}
+
+func (r *TodoRepository) DeleteTag(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tag, exists := r.tags[id]
+	if !exists {
+		return domain.ErrTagNotFound
+	}
+
+	for _, todos := range []map[int]*domain.Todo{r.todos, r.trash} {
+		for _, todo := range todos {
+			todo.Tags = removeTag(todo.Tags, tag.Name)
+		}
+	}
+	delete(r.tagIDs, tag.Name)
+	delete(r.tags, id)
+	return nil
+}
+
+// RestoreTag puts a tag with a known ID back, renaming it on todos when the
+// name changed. See Restore.
+func (r *TodoRepository) RestoreTag(tag domain.Tag) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := tag
+	if old, exists := r.tags[t.ID]; exists {
+		if old.Name != t.Name {
+			r.renameTag(old, t.Name)
+		}
+		*old = t
+	} else {
+		r.tags[t.ID] = &t
+		r.tagIDs[t.Name] = t.ID
+	}
+
+	if t.ID >= r.nextTagID {
+		r.nextTagID = t.ID + 1
+	}
+}
+
+// NextTagID and SetNextTagID are the tag counterparts of NextID and SetNextID.
+func (r *TodoRepository) NextTagID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nextTagID
+}
+
+func (r *TodoRepository) SetNextTagID(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id > r.nextTagID {
+		r.nextTagID = id
+	}
+}
+
+func (r *TodoRepository) renameTag(tag *domain.Tag, name string) {
+	for _, todos := range []map[int]*domain.Todo{r.todos, r.trash} {
+		for _, todo := range todos {
+			if todo.HasTags([]string{tag.Name}, false) {
+				todo.Tags = append(removeTag(todo.Tags, tag.Name), name)
+				sort.Strings(todo.Tags)
+			}
+		}
+	}
+	delete(r.tagIDs, tag.Name)
+	r.tagIDs[name] = tag.ID
+	tag.Name = name
+}
+
+// checkTags fails for the first name no tag has.
+func (r *TodoRepository) checkTags(names []string) error {
+	for _, name := range names {
+		if _, exists := r.tagIDs[name]; !exists {
+			return fmt.Errorf("%w: %q", domain.ErrUnknownTag, name)
+		}
+	}
+	return nil
+}
+
+// tagSet returns a sorted copy of names without duplicates.
+func tagSet(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	out := append([]string(nil), names...)
+	sort.Strings(out)
+	n := 1
+	for _, name := range out[1:] {
+		if name != out[n-1] {
+			out[n] = name
+			n++
+		}
+	}
+	return out[:n]
+}
+
+func removeTag(tags []string, name string) []string {
+	for i, tag := range tags {
+		if tag == name {
+			out := append(tags[:i:i], tags[i+1:]...)
+			if len(out) == 0 {
+				return nil
+			}
+			return out
+		}
+	}
+	return tags
+}
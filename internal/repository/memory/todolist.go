@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) CreateList(ctx context.Context, input domain.CreateListInput) (*domain.TodoList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	list := &domain.TodoList{
+		ID:          r.nextListID,
+		Name:        input.Name,
+		Description: input.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	r.lists[list.ID] = list
+	r.nextListID++
+
+	created := *list
+	return &created, nil
+}
+
+func (r *TodoRepository) GetList(ctx context.Context, id int) (*domain.TodoList, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list, exists := r.lists[id]
+	if !exists {
+		return nil, domain.ErrListNotFound
+	}
+
+	found := r.countedList(list)
+	return &found, nil
+}
+
+func (r *TodoRepository) GetLists(ctx context.Context) ([]domain.TodoList, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	open := make(map[int]int, len(r.lists))
+	completed := make(map[int]int, len(r.lists))
+	for _, todo := range r.todos {
+		if todo.Completed {
+			completed[todo.ListID]++
+		} else {
+			open[todo.ListID]++
+		}
+	}
+
+	lists := make([]domain.TodoList, 0, len(r.lists))
+	for _, list := range r.lists {
+		l := *list
+		l.OpenCount = open[l.ID]
+		l.CompletedCount = completed[l.ID]
+		lists = append(lists, l)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists, nil
+}
+
+func (r *TodoRepository) UpdateList(ctx context.Context, id int, input domain.UpdateListInput) (*domain.TodoList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, exists := r.lists[id]
+	if !exists {
+		return nil, domain.ErrListNotFound
+	}
+
+	if input.Name != nil {
+		list.Name = *input.Name
+	}
+
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+
+	list.UpdatedAt = time.Now()
+
+	updated := r.countedList(list)
+	return &updated, nil
+}
+
+func (r *TodoRepository) DeleteList(ctx context.Context, id int, cascade bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.lists[id]; !exists {
+		return domain.ErrListNotFound
+	}
+
+	var contained []*domain.Todo
+	for _, todo := range r.todos {
+		if todo.ListID == id {
+			contained = append(contained, todo)
+		}
+	}
+	if len(contained) > 0 && !cascade {
+		return domain.ErrListNotEmpty
+	}
+
+	now := time.Now()
+	for _, todo := range contained {
+		r.trashLocked(todo, now)
+	}
+	// a todo restored from the trash lands in no list
+	for _, todo := range r.trash {
+		if todo.ListID == id {
+			todo.ListID = 0
+		}
+	}
+	delete(r.lists, id)
+	return nil
+}
+
+// RestoreList puts a list with a known ID back. See Restore.
+func (r *TodoRepository) RestoreList(list domain.TodoList) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := list
+	l.OpenCount, l.CompletedCount = 0, 0
+	r.lists[l.ID] = &l
+	if l.ID >= r.nextListID {
+		r.nextListID = l.ID + 1
+	}
+}
+
+// NextListID and SetNextListID are the list counterparts of NextID and
+// SetNextID.
+func (r *TodoRepository) NextListID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nextListID
+}
+
+func (r *TodoRepository) SetNextListID(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id > r.nextListID {
+		r.nextListID = id
+	}
+}
+
+// countedList returns a copy of list with its todo counts filled in.
+func (r *TodoRepository) countedList(list *domain.TodoList) domain.TodoList {
+	l := *list
+	for _, todo := range r.todos {
+		if todo.ListID != l.ID {
+			continue
+		}
+		if todo.Completed {
+			l.CompletedCount++
+		} else {
+			l.OpenCount++
+		}
+	}
+	return l
+}
+
+// checkList fails unless id is 0 or names an existing list.
+func (r *TodoRepository) checkList(id int) error {
+	if id == 0 {
+		return nil
+	}
+	if _, exists := r.lists[id]; !exists {
+		return fmt.Errorf("%w: %d", domain.ErrUnknownList, id)
+	}
+	return nil
+}
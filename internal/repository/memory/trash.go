@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) ListTrash(ctx context.Context) ([]domain.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := make([]domain.Todo, 0, len(r.trash))
+	for _, todo := range r.trash {
+		todos = append(todos, copyTodo(todo))
+	}
+	sortTrash(todos)
+
+	return todos, nil
+}
+
+func (r *TodoRepository) RestoreDeleted(ctx context.Context, id int) (*domain.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, exists := r.trash[id]
+	if !exists {
+		return nil, domain.ErrTodoNotFound
+	}
+	if _, trashed := r.trash[todo.ParentID]; trashed {
+		return nil, domain.ErrParentDeleted
+	}
+	if _, exists := r.todos[todo.ParentID]; !exists {
+		// the parent was purged
+		todo.ParentID = 0
+	}
+
+	delete(r.trash, id)
+	todo.DeletedAt = nil
+	todo.Version++
+	todo.UpdatedAt = time.Now()
+	r.todos[id] = todo
+	r.index.add(todo)
+
+	restored := r.view(todo)
+	return &restored, nil
+}
+
+func (r *TodoRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, todo := range r.trash {
+		if todo.DeletedAt.Before(before) {
+			delete(r.trash, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// GetTrashed returns the todo with id if it is in the trash.
+func (r *TodoRepository) GetTrashed(id int) (domain.Todo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, exists := r.trash[id]
+	if !exists {
+		return domain.Todo{}, false
+	}
+	return copyTodo(todo), true
+}
+
+// Purge removes the todo with id for good, whether it is in the trash or
+// not.
+func (r *TodoRepository) Purge(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo, exists := r.todos[id]; exists {
+		r.remove(todo)
+	}
+	delete(r.trash, id)
+}
+
+// sortTrash puts the most recently deleted todos first.
+func sortTrash(todos []domain.Todo) {
+	sort.Slice(todos, func(i, j int) bool {
+		a, b := todos[i].DeletedAt, todos[j].DeletedAt
+		if !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return todos[i].ID > todos[j].ID
+	})
+}
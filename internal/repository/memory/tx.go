@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// InTx runs fn on a copy of the store and keeps the copy only when fn
+// succeeds. Other callers wait until fn returns.
+func (r *TodoRepository) InTx(ctx context.Context, fn func(repo domain.TodoRepository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx := r.cloneLocked()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	r.adoptLocked(tx)
+	return nil
+}
+
+// Clone returns a copy of the store sharing no memory with it.
+func (r *TodoRepository) Clone() *TodoRepository {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cloneLocked()
+}
+
+// Adopt replaces the contents of the store with those of c, which must not
+// be used afterwards.
+func (r *TodoRepository) Adopt(c *TodoRepository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.adoptLocked(c)
+}
+
+func (r *TodoRepository) cloneLocked() *TodoRepository {
+	c := NewTodoRepository()
+
+	for id, todo := range r.todos {
+		t := copyTodo(todo)
+		c.todos[id] = &t
+		c.index.add(&t)
+	}
+	c.nextID = r.nextID
+	for id, todo := range r.trash {
+		t := copyTodo(todo)
+		c.trash[id] = &t
+	}
+
+	for id, tag := range r.tags {
+		t := *tag
+		c.tags[id] = &t
+	}
+	for name, id := range r.tagIDs {
+		c.tagIDs[name] = id
+	}
+	c.nextTagID = r.nextTagID
+
+	for id, list := range r.lists {
+		l := *list
+		c.lists[id] = &l
+	}
+	c.nextListID = r.nextListID
+
+	for id, blockers := range r.blockers {
+		for blocker := range blockers {
+			c.addEdge(domain.Dependency{TodoID: id, BlockerID: blocker})
+		}
+	}
+
+	for id, hook := range r.webhooks {
+		h := copyWebhook(hook)
+		c.webhooks[id] = &h
+	}
+	c.nextWebhookID = r.nextWebhookID
+
+	return c
+}
+
+func (r *TodoRepository) adoptLocked(c *TodoRepository) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r.todos, r.index, r.nextID, r.trash = c.todos, c.index, c.nextID, c.trash
+	r.tags, r.tagIDs, r.nextTagID = c.tags, c.tagIDs, c.nextTagID
+	r.lists, r.nextListID = c.lists, c.nextListID
+	r.blockers, r.blocking = c.blockers, c.blocking
+	r.webhooks, r.nextWebhookID = c.webhooks, c.nextWebhookID
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) CreateWebhook(ctx context.Context, input domain.CreateWebhookInput) (*domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	hook := &domain.Webhook{
+		ID:        r.nextWebhookID,
+		URL:       input.URL,
+		Secret:    input.Secret,
+		Events:    append([]domain.EventType{}, input.Events...),
+		Active:    input.Active == nil || *input.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	r.webhooks[hook.ID] = hook
+	r.nextWebhookID++
+
+	created := copyWebhook(hook)
+	return &created, nil
+}
+
+func (r *TodoRepository) GetWebhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hook, exists := r.webhooks[id]
+	if !exists {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	found := copyWebhook(hook)
+	return &found, nil
+}
+
+func (r *TodoRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hooks := make([]domain.Webhook, 0, len(r.webhooks))
+	for _, hook := range r.webhooks {
+		hooks = append(hooks, copyWebhook(hook))
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].ID < hooks[j].ID
+	})
+
+	return hooks, nil
+}
+
+func (r *TodoRepository) UpdateWebhook(ctx context.Context, id int, input domain.UpdateWebhookInput) (*domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook, exists := r.webhooks[id]
+	if !exists {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	if input.URL != nil {
+		hook.URL = *input.URL
+	}
+	if input.Secret != nil {
+		hook.Secret = *input.Secret
+	}
+	if input.Events != nil {
+		hook.Events = append([]domain.EventType{}, *input.Events...)
+	}
+	if input.Active != nil {
+		hook.Active = *input.Active
+	}
+	hook.UpdatedAt = time.Now()
+
+	updated := copyWebhook(hook)
+	return &updated, nil
+}
+
+func (r *TodoRepository) DeleteWebhook(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.webhooks[id]; !exists {
+		return domain.ErrWebhookNotFound
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+// RestoreWebhook puts a webhook with a known ID back. See Restore.
+func (r *TodoRepository) RestoreWebhook(hook domain.Webhook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := copyWebhook(&hook)
+	r.webhooks[h.ID] = &h
+	if h.ID >= r.nextWebhookID {
+		r.nextWebhookID = h.ID + 1
+	}
+}
+
+// NextWebhookID and SetNextWebhookID are the webhook counterparts of NextID
+// and SetNextID.
+func (r *TodoRepository) NextWebhookID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nextWebhookID
+}
+
+func (r *TodoRepository) SetNextWebhookID(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id > r.nextWebhookID {
+		r.nextWebhookID = id
+	}
+}
+
+func copyWebhook(hook *domain.Webhook) domain.Webhook {
+	c := *hook
+	c.Events = append([]domain.EventType{}, hook.Events...)
+	return c
+}
@@ -0,0 +1,1682 @@
+// Package repositorytest holds the behaviour every domain.TodoRepository
+// implementation must share. Backends call Run from their own tests.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// Factory returns an empty repository. It should register any cleanup with
+// t.Cleanup.
+type Factory func(t *testing.T) domain.TodoRepository
+
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo domain.TodoRepository)
+	}{
+		{"Create", testCreate},
+		{"IDsAreMonotonic", testIDsAreMonotonic},
+		{"GetByID", testGetByID},
+		{"GetAll", testGetAll},
+		{"PartialUpdate", testPartialUpdate},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"ReturnedTodosAreCopies", testReturnedTodosAreCopies},
+		{"GetFiltered", testGetFiltered},
+		{"List", testList},
+		{"ListFiltered", testListFiltered},
+		{"ListSorted", testListSorted},
+		{"ListFilterExpr", testListFilterExpr},
+		{"ListSearch", testListSearch},
+		{"PriorityAndDueAt", testPriorityAndDueAt},
+		{"Tags", testTags},
+		{"TodoTags", testTodoTags},
+		{"Lists", testLists},
+		{"Subtasks", testSubtasks},
+		{"Dependencies", testDependencies},
+		{"Recurrence", testRecurrence},
+		{"Reminders", testReminders},
+		{"MarkReminded", testMarkReminded},
+		{"Versions", testVersions},
+		{"Webhooks", testWebhooks},
+		{"Transactions", testTransactions},
+		{"Trash", testTrash},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"ConcurrentUpdate", testConcurrentUpdate},
+		{"ConcurrentVersionedUpdate", testConcurrentVersionedUpdate},
+		{"ConcurrentCycles", testConcurrentCycles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func mustCreate(t *testing.T, repo domain.TodoRepository, input domain.CreateTodoInput) *domain.Todo {
+	t.Helper()
+	todo, err := repo.Create(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return todo
+}
+
+func testCreate(t *testing.T, repo domain.TodoRepository) {
+	input := domain.CreateTodoInput{Title: "Task", Description: "Desc", Completed: true}
+	todo := mustCreate(t, repo, input)
+
+	if todo.ID <= 0 {
+		t.Errorf("expected positive ID, got %d", todo.ID)
+	}
+	if todo.Title != input.Title || todo.Description != input.Description || todo.Completed != input.Completed {
+		t.Errorf("fields not stored: %+v", todo)
+	}
+	if todo.CreatedAt.IsZero() || !todo.UpdatedAt.Equal(todo.CreatedAt) {
+		t.Errorf("expected CreatedAt == UpdatedAt and non zero, got %v / %v", todo.CreatedAt, todo.UpdatedAt)
+	}
+}
+
+func testIDsAreMonotonic(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	first := mustCreate(t, repo, domain.CreateTodoInput{Title: "First"})
+	second := mustCreate(t, repo, domain.CreateTodoInput{Title: "Second"})
+	if second.ID <= first.ID {
+		t.Errorf("expected ID > %d, got %d", first.ID, second.ID)
+	}
+
+	if err := repo.Delete(ctx, second.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	third := mustCreate(t, repo, domain.CreateTodoInput{Title: "Third"})
+	if third.ID <= second.ID {
+		t.Errorf("expected deleted ID %d not to be reused, got %d", second.ID, third.ID)
+	}
+}
+
+func testGetByID(t *testing.T, repo domain.TodoRepository) {
+	created := mustCreate(t, repo, domain.CreateTodoInput{Title: "Task", Description: "Desc"})
+
+	got, err := repo.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.ID != created.ID || got.Title != created.Title || got.Description != created.Description ||
+		got.Completed != created.Completed || !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("expected %+v, got %+v", created, got)
+	}
+}
+
+func testGetAll(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if all == nil || len(all) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", all)
+	}
+
+	want := map[int]string{}
+	for i := 0; i < 3; i++ {
+		todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Task " + strconv.Itoa(i)})
+		want[todo.ID] = todo.Title
+	}
+
+	all, err = repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("expected %d todos, got %d", len(want), len(all))
+	}
+	for _, todo := range all {
+		if want[todo.ID] != todo.Title {
+			t.Errorf("unexpected todo %+v", todo)
+		}
+	}
+}
+
+func testPartialUpdate(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, repo, domain.CreateTodoInput{Title: "Title", Description: "Desc"})
+
+	completed := true
+	updated, err := repo.Update(ctx, created.ID, domain.UpdateTodoInput{Completed: &completed})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !updated.Completed || updated.Title != "Title" || updated.Description != "Desc" {
+		t.Errorf("only completed should change, got %+v", updated)
+	}
+
+	title := "New title"
+	updated, err = repo.Update(ctx, created.ID, domain.UpdateTodoInput{Title: &title})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Title != title || updated.Description != "Desc" || !updated.Completed {
+		t.Errorf("only title should change, got %+v", updated)
+	}
+
+	empty := ""
+	updated, err = repo.Update(ctx, created.ID, domain.UpdateTodoInput{Description: &empty})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Description != "" || updated.Title != title {
+		t.Errorf("description should be cleared, got %+v", updated)
+	}
+
+	if !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("CreatedAt changed from %v to %v", created.CreatedAt, updated.CreatedAt)
+	}
+	if updated.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("UpdatedAt moved back from %v to %v", created.UpdatedAt, updated.UpdatedAt)
+	}
+
+	got, _ := repo.GetByID(ctx, created.ID)
+	if got.Title != title || got.Description != "" || !got.Completed {
+		t.Errorf("update not persisted, got %+v", got)
+	}
+}
+
+func testDelete(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	keep := mustCreate(t, repo, domain.CreateTodoInput{Title: "Keep"})
+	drop := mustCreate(t, repo, domain.CreateTodoInput{Title: "Drop"})
+
+	if err := repo.Delete(ctx, drop.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	all, _ := repo.GetAll(ctx)
+	if len(all) != 1 || all[0].ID != keep.ID {
+		t.Errorf("expected only todo %d to remain, got %+v", keep.ID, all)
+	}
+}
+
+func testNotFound(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Task"})
+	if err := repo.Delete(ctx, todo.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	title := "x"
+	for _, id := range []int{todo.ID, todo.ID + 1000} {
+		if _, err := repo.GetByID(ctx, id); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("GetByID(%d): expected ErrTodoNotFound, got %v", id, err)
+		}
+		if _, err := repo.Update(ctx, id, domain.UpdateTodoInput{Title: &title}); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("Update(%d): expected ErrTodoNotFound, got %v", id, err)
+		}
+		if err := repo.Delete(ctx, id); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("Delete(%d): expected ErrTodoNotFound, got %v", id, err)
+		}
+	}
+
+	if _, err := repo.Update(ctx, todo.ID+1000, domain.UpdateTodoInput{}); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("empty Update: expected ErrTodoNotFound, got %v", err)
+	}
+}
+
+func testReturnedTodosAreCopies(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, repo, domain.CreateTodoInput{Title: "Original"})
+	created.Title = "changed by caller"
+
+	got, _ := repo.GetByID(ctx, created.ID)
+	got.Title = "changed by caller"
+
+	all, _ := repo.GetAll(ctx)
+	all[0].Title = "changed by caller"
+
+	got, _ = repo.GetByID(ctx, created.ID)
+	if got.Title != "Original" {
+		t.Errorf("stored todo changed through a returned value: %q", got.Title)
+	}
+}
+
+func testGetFiltered(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	milk := mustCreate(t, repo, domain.CreateTodoInput{Title: "Buy MILK", Completed: true})
+	report := mustCreate(t, repo, domain.CreateTodoInput{Title: "Write report", Description: "quarterly numbers"})
+	call := mustCreate(t, repo, domain.CreateTodoInput{Title: "Call mom", Description: "about milk"})
+
+	yes, no := true, false
+	cases := []struct {
+		name      string
+		completed *bool
+		search    string
+		want      []int
+	}{
+		{"no filter", nil, "", []int{milk.ID, report.ID, call.ID}},
+		{"completed", &yes, "", []int{milk.ID}},
+		{"not completed", &no, "", []int{report.ID, call.ID}},
+		{"search title case insensitive", nil, "milk", []int{milk.ID, call.ID}},
+		{"search description", nil, "QUARTERLY", []int{report.ID}},
+		{"search and completed", &no, "milk", []int{call.ID}},
+		{"search and completed no match", &yes, "report", nil},
+		{"search no match", nil, "nothing", nil},
+	}
+
+	for _, tc := range cases {
+		got, err := repo.GetFiltered(ctx, tc.completed, tc.search)
+		if err != nil {
+			t.Fatalf("%s: GetFiltered failed: %v", tc.name, err)
+		}
+		if ids := sortedIDs(got); !equalIDs(ids, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, ids)
+		}
+	}
+}
+
+func testList(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	var want []int
+	for i := 0; i < 5; i++ {
+		want = append(want, mustCreate(t, repo, domain.CreateTodoInput{Title: "Task " + strconv.Itoa(i)}).ID)
+	}
+
+	var got []int
+	query := domain.ListQuery{Limit: 2}
+	for pages := 1; ; pages++ {
+		page, err := repo.List(ctx, query)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if page.Total != len(want) {
+			t.Errorf("page %d: expected total %d, got %d", pages, len(want), page.Total)
+		}
+		if len(page.Items) > 2 {
+			t.Errorf("page %d: expected at most 2 items, got %d", pages, len(page.Items))
+		}
+		got = append(got, sortedIDs(page.Items)...)
+
+		if page.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("expected 3 pages, got %d", pages)
+			}
+			break
+		}
+		if pages == 3 {
+			t.Fatal("expected no cursor on the last page")
+		}
+
+		after, err := domain.DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor failed: %v", err)
+		}
+		query.After = after
+
+		// the cursor must survive its anchor todo being deleted
+		if pages == 1 {
+			if err := repo.Delete(ctx, after.ID); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			want = append(want[:1], want[2:]...)
+		}
+	}
+
+	if !equalIDs(got[:1], want[:1]) || !equalIDs(got[2:], want[1:]) {
+		t.Errorf("expected pages to cover %v in order, got %v", want, got)
+	}
+
+	page, err := repo.List(ctx, domain.ListQuery{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(page.Items) != len(want) || page.NextCursor != "" {
+		t.Errorf("zero limit should return everything, got %d items, cursor %q", len(page.Items), page.NextCursor)
+	}
+}
+
+func testListFiltered(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	for i := 0; i < 6; i++ {
+		mustCreate(t, repo, domain.CreateTodoInput{Title: "Task " + strconv.Itoa(i), Completed: i%2 == 0})
+	}
+
+	yes := true
+	page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Completed: &yes}, Limit: 2})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected 2 of 3 completed todos and a cursor, got %+v", page)
+	}
+	for _, todo := range page.Items {
+		if !todo.Completed {
+			t.Errorf("unexpected open todo %d", todo.ID)
+		}
+	}
+
+	empty, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Search: "nothing"}})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if empty.Items == nil || len(empty.Items) != 0 || empty.Total != 0 {
+		t.Errorf("expected empty non-nil page, got %+v", empty)
+	}
+}
+
+func testListSorted(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	titles := []string{"b", "a", "C", "a", "c", "b"}
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range titles {
+		input := domain.CreateTodoInput{Title: title, Completed: i%3 == 0, Priority: domain.Priorities[i%3*2]}
+		if i%3 != 1 {
+			due := base.Add(time.Duration(i%4) * time.Hour)
+			input.DueAt = &due
+		}
+		todo := mustCreate(t, repo, input)
+		if i%2 == 0 {
+			desc := "touched"
+			if _, err := repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Description: &desc}); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+		}
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	for _, spec := range []string{"", "-id", "title", "-title", "completed,-title", "-completed,created_at", "-updated_at", "title,-id",
+		"priority", "-priority,title", "due_at", "-due_at", "due_at,-priority"} {
+		keys, err := domain.ParseSort(spec)
+		if err != nil {
+			t.Fatalf("ParseSort(%q) failed: %v", spec, err)
+		}
+
+		want := append([]domain.Todo(nil), all...)
+		sort.SliceStable(want, func(i, j int) bool {
+			return domain.CompareTodos(want[i], want[j], keys) < 0
+		})
+
+		var got []domain.Todo
+		query := domain.ListQuery{Sort: keys, Limit: 4}
+		for pages := 0; ; pages++ {
+			if pages > len(all) {
+				t.Fatalf("sort %q: pagination did not terminate", spec)
+			}
+			page, err := repo.List(ctx, query)
+			if err != nil {
+				t.Fatalf("sort %q: List failed: %v", spec, err)
+			}
+			got = append(got, page.Items...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.After, err = domain.DecodeCursor(page.NextCursor)
+			if err != nil {
+				t.Fatalf("sort %q: DecodeCursor failed: %v", spec, err)
+			}
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("sort %q: expected %d todos, got %d", spec, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID {
+				t.Errorf("sort %q: expected order %v, got %v", spec, todoIDs(want), todoIDs(got))
+				break
+			}
+		}
+	}
+}
+
+func testListFilterExpr(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	mustCreate(t, repo, domain.CreateTodoInput{Title: "Buy milk", Description: "2% fat"})
+	mid := mustCreate(t, repo, domain.CreateTodoInput{Title: "buy bread", Completed: true})
+	mustCreate(t, repo, domain.CreateTodoInput{Title: "Write report", Description: "Q3 numbers"})
+	mustCreate(t, repo, domain.CreateTodoInput{Title: "Call Bob", Completed: true})
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	at := mid.CreatedAt.UTC().Format(time.RFC3339Nano)
+	exprs := []string{
+		`title prefix "buy"`,
+		`title = "buy bread"`,
+		`title != "Call Bob"`,
+		`description contains "2%"`,
+		`id in (` + strconv.Itoa(mid.ID) + `, 9999)`,
+		`id > ` + strconv.Itoa(mid.ID) + ` OR completed = false`,
+		`NOT (completed = true AND title prefix buy)`,
+		`created_at >= "` + at + `"`,
+		`created_at < "` + at + `" OR updated_at > "` + at + `"`,
+		`created_at >= 2000-01-01 AND created_at < 2999-01-01 AND NOT id = ` + strconv.Itoa(mid.ID),
+	}
+
+	for _, src := range exprs {
+		expr, err := domain.ParseFilter(src)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) failed: %v", src, err)
+		}
+
+		var want []int
+		for _, todo := range all {
+			if expr.Match(todo) {
+				want = append(want, todo.ID)
+			}
+		}
+
+		page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Expr: expr}})
+		if err != nil {
+			t.Fatalf("%q: List failed: %v", src, err)
+		}
+		if got := sortedIDs(page.Items); !equalIDs(got, want) || page.Total != len(want) {
+			t.Errorf("%q: expected %v, got %v (total %d)", src, want, got, page.Total)
+		}
+	}
+}
+
+func testListSearch(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	milk := mustCreate(t, repo, domain.CreateTodoInput{Title: "Buy milk", Description: "and fresh bread"})
+	bread := mustCreate(t, repo, domain.CreateTodoInput{Title: "Bake bread", Description: "buy flour first"})
+	call := mustCreate(t, repo, domain.CreateTodoInput{Title: "Call mom", Description: "ask about milk recipes"})
+	invoice := mustCreate(t, repo, domain.CreateTodoInput{Title: "Pay invoice"})
+	family := mustCreate(t, repo, domain.CreateTodoInput{Title: "Visit family", Description: "Don't forget, the flowers!"})
+
+	cases := []struct {
+		search string
+		want   []int
+	}{
+		{"milk", []int{milk.ID, call.ID}},
+		{"BUY bread", []int{milk.ID, bread.ID}},
+		{"bread flour", []int{bread.ID}},
+		{"reci*", []int{call.ID}},
+		{"mil* BUY", []int{milk.ID}},
+		{`"buy milk"`, []int{milk.ID}},
+		{`"fresh bread" buy`, []int{milk.ID}},
+		{`"buy bread"`, nil},
+		{"milk flour", nil},
+		// plain words match inside longer words, the same in every backend
+		{"voi", []int{invoice.ID}},
+		{"ILK", []int{milk.ID, call.ID}},
+		{"ay", []int{invoice.ID}},
+		{"mil", []int{milk.ID, call.ID, family.ID}},
+		// a prefix only matches at the start of a word
+		{"mil*", []int{milk.ID, call.ID}},
+		// phrases are whole words in a row, punctuation between them ignored
+		{`"pay inv"`, nil},
+		{`"don't forget"`, []int{family.ID}},
+		{`"forget the flowers"`, []int{family.ID}},
+		{`"flowers forget"`, nil},
+		{`"family don't"`, nil},
+	}
+
+	for _, tc := range cases {
+		page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Search: tc.search}})
+		if err != nil {
+			t.Fatalf("List(%q) failed: %v", tc.search, err)
+		}
+		if ids := sortedIDs(page.Items); !equalIDs(ids, tc.want) {
+			t.Errorf("List(%q): expected %v, got %v", tc.search, tc.want, ids)
+		}
+	}
+}
+
+func testPriorityAndDueAt(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	overdue := mustCreate(t, repo, domain.CreateTodoInput{Title: "Overdue", Priority: domain.PriorityHigh, DueAt: &past})
+	done := mustCreate(t, repo, domain.CreateTodoInput{Title: "Done late", Completed: true, Priority: domain.PriorityLow, DueAt: &past})
+	upcoming := mustCreate(t, repo, domain.CreateTodoInput{Title: "Upcoming", Priority: domain.PriorityUrgent, DueAt: &future})
+	someday := mustCreate(t, repo, domain.CreateTodoInput{Title: "Someday", Priority: domain.PriorityNone})
+
+	got, err := repo.GetByID(ctx, overdue.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.Priority != domain.PriorityHigh || got.DueAt == nil || !got.DueAt.Equal(past) {
+		t.Errorf("expected priority and due date to round trip, got %+v", got)
+	}
+
+	yes, no := true, false
+	cases := []struct {
+		name   string
+		filter domain.TodoFilter
+		want   []int
+	}{
+		{"priority", domain.TodoFilter{Priorities: []domain.Priority{domain.PriorityHigh, domain.PriorityUrgent}}, []int{overdue.ID, upcoming.ID}},
+		{"due before", domain.TodoFilter{DueBefore: &now}, []int{overdue.ID, done.ID}},
+		{"due after", domain.TodoFilter{DueAfter: &now}, []int{upcoming.ID}},
+		{"due before is exclusive", domain.TodoFilter{DueBefore: &past}, nil},
+		{"overdue", domain.TodoFilter{Overdue: &yes, Now: now}, []int{overdue.ID}},
+		{"not overdue", domain.TodoFilter{Overdue: &no, Now: now}, []int{done.ID, upcoming.ID, someday.ID}},
+	}
+	for _, tc := range cases {
+		page, err := repo.List(ctx, domain.ListQuery{Filter: tc.filter})
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", tc.name, err)
+		}
+		if ids := sortedIDs(page.Items); !equalIDs(ids, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, ids)
+		}
+	}
+
+	urgent := domain.PriorityUrgent
+	updated, err := repo.Update(ctx, someday.ID, domain.UpdateTodoInput{Priority: &urgent, DueAt: &future})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Priority != urgent || updated.DueAt == nil || !updated.DueAt.Equal(future) {
+		t.Errorf("expected priority and due date updated, got %+v", updated)
+	}
+
+	title := "Renamed"
+	updated, _ = repo.Update(ctx, someday.ID, domain.UpdateTodoInput{Title: &title})
+	if updated.Priority != urgent || updated.DueAt == nil {
+		t.Errorf("expected an unrelated update to keep priority and due date, got %+v", updated)
+	}
+
+	updated, _ = repo.Update(ctx, someday.ID, domain.UpdateTodoInput{ClearDueAt: true})
+	if updated.DueAt != nil || updated.Priority != urgent {
+		t.Errorf("expected only the due date cleared, got %+v", updated)
+	}
+}
+
+func tagRepo(t *testing.T, repo domain.TodoRepository) domain.TagRepository {
+	t.Helper()
+	tags, ok := repo.(domain.TagRepository)
+	if !ok {
+		t.Skip("repository does not implement domain.TagRepository")
+	}
+	return tags
+}
+
+func mustCreateTag(t *testing.T, repo domain.TagRepository, name string) *domain.Tag {
+	t.Helper()
+	tag, err := repo.CreateTag(context.Background(), domain.CreateTagInput{Name: name, Color: "#ff0000"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	return tag
+}
+
+func testTags(t *testing.T, repo domain.TodoRepository) {
+	tags := tagRepo(t, repo)
+	ctx := context.Background()
+
+	work := mustCreateTag(t, tags, "work")
+	if work.ID <= 0 || work.Name != "work" || work.Color != "#ff0000" || work.CreatedAt.IsZero() {
+		t.Errorf("unexpected tag %+v", work)
+	}
+	mustCreateTag(t, tags, "home")
+
+	if _, err := tags.CreateTag(ctx, domain.CreateTagInput{Name: "work"}); !errors.Is(err, domain.ErrTagExists) {
+		t.Errorf("expected ErrTagExists, got %v", err)
+	}
+
+	got, err := tags.GetTag(ctx, work.ID)
+	if err != nil || got.Name != "work" {
+		t.Fatalf("GetTag: got %+v, %v", got, err)
+	}
+
+	list, err := tags.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "home" || list[1].Name != "work" {
+		t.Errorf("expected tags ordered by name, got %+v", list)
+	}
+
+	color := "#00ff00"
+	updated, err := tags.UpdateTag(ctx, work.ID, domain.UpdateTagInput{Color: &color})
+	if err != nil {
+		t.Fatalf("UpdateTag failed: %v", err)
+	}
+	if updated.Name != "work" || updated.Color != color {
+		t.Errorf("expected only the color to change, got %+v", updated)
+	}
+
+	home := "home"
+	if _, err := tags.UpdateTag(ctx, work.ID, domain.UpdateTagInput{Name: &home}); !errors.Is(err, domain.ErrTagExists) {
+		t.Errorf("expected ErrTagExists renaming onto another tag, got %v", err)
+	}
+
+	if err := tags.DeleteTag(ctx, work.ID); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	if _, err := tags.GetTag(ctx, work.ID); !errors.Is(err, domain.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound after delete, got %v", err)
+	}
+	if err := tags.DeleteTag(ctx, work.ID); !errors.Is(err, domain.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound deleting twice, got %v", err)
+	}
+	if _, err := tags.UpdateTag(ctx, work.ID, domain.UpdateTagInput{Color: &color}); !errors.Is(err, domain.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound updating a deleted tag, got %v", err)
+	}
+}
+
+func testTodoTags(t *testing.T, repo domain.TodoRepository) {
+	tags := tagRepo(t, repo)
+	ctx := context.Background()
+
+	work := mustCreateTag(t, tags, "work")
+	mustCreateTag(t, tags, "urgent")
+	mustCreateTag(t, tags, "home")
+
+	both := mustCreate(t, repo, domain.CreateTodoInput{Title: "Report", Tags: []string{"work", "urgent"}})
+	if !equalStrings(both.Tags, []string{"urgent", "work"}) {
+		t.Errorf("expected tags sorted by name, got %v", both.Tags)
+	}
+	onlyWork := mustCreate(t, repo, domain.CreateTodoInput{Title: "Email", Tags: []string{"work"}})
+	home := mustCreate(t, repo, domain.CreateTodoInput{Title: "Dishes", Tags: []string{"home"}})
+	untagged := mustCreate(t, repo, domain.CreateTodoInput{Title: "Nothing"})
+
+	if _, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Bad", Tags: []string{"nope"}}); !errors.Is(err, domain.ErrUnknownTag) {
+		t.Errorf("expected ErrUnknownTag, got %v", err)
+	}
+	if _, err := repo.Update(ctx, untagged.ID, domain.UpdateTodoInput{Tags: &[]string{"nope"}}); !errors.Is(err, domain.ErrUnknownTag) {
+		t.Errorf("expected ErrUnknownTag on update, got %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		tags  []string
+		match domain.TagMatch
+		want  []int
+	}{
+		{"any", []string{"work", "home"}, domain.TagMatchAny, []int{both.ID, onlyWork.ID, home.ID}},
+		{"all", []string{"work", "urgent"}, domain.TagMatchAll, []int{both.ID}},
+		{"all of one", []string{"work"}, domain.TagMatchAll, []int{both.ID, onlyWork.ID}},
+		{"all missing", []string{"work", "home"}, domain.TagMatchAll, nil},
+	}
+	for _, tc := range cases {
+		page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Tags: tc.tags, TagMatch: tc.match}})
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", tc.name, err)
+		}
+		if ids := sortedIDs(page.Items); !equalIDs(ids, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, ids)
+		}
+	}
+
+	updated, err := repo.Update(ctx, untagged.ID, domain.UpdateTodoInput{Tags: &[]string{"home", "urgent"}})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !equalStrings(updated.Tags, []string{"home", "urgent"}) {
+		t.Errorf("expected tags replaced, got %v", updated.Tags)
+	}
+
+	title := "Still nothing"
+	updated, _ = repo.Update(ctx, untagged.ID, domain.UpdateTodoInput{Title: &title})
+	if !equalStrings(updated.Tags, []string{"home", "urgent"}) {
+		t.Errorf("expected an update without tags to keep them, got %v", updated.Tags)
+	}
+
+	updated, _ = repo.Update(ctx, untagged.ID, domain.UpdateTodoInput{Tags: &[]string{}})
+	if len(updated.Tags) != 0 {
+		t.Errorf("expected tags cleared, got %v", updated.Tags)
+	}
+
+	job := "job"
+	if _, err := tags.UpdateTag(ctx, work.ID, domain.UpdateTagInput{Name: &job}); err != nil {
+		t.Fatalf("UpdateTag failed: %v", err)
+	}
+	got, _ := repo.GetByID(ctx, both.ID)
+	if !equalStrings(got.Tags, []string{"job", "urgent"}) {
+		t.Errorf("expected the rename to reach todos, got %v", got.Tags)
+	}
+	page, _ := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Tags: []string{"job"}}})
+	if ids := sortedIDs(page.Items); !equalIDs(ids, []int{both.ID, onlyWork.ID}) {
+		t.Errorf("expected to filter by the new name, got %v", ids)
+	}
+
+	if err := tags.DeleteTag(ctx, work.ID); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	got, _ = repo.GetByID(ctx, both.ID)
+	if !equalStrings(got.Tags, []string{"urgent"}) {
+		t.Errorf("expected the deleted tag detached, got %v", got.Tags)
+	}
+	got, _ = repo.GetByID(ctx, onlyWork.ID)
+	if len(got.Tags) != 0 {
+		t.Errorf("expected no tags left, got %v", got.Tags)
+	}
+
+	if err := repo.Delete(ctx, both.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	page, _ = repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Tags: []string{"urgent"}}})
+	if len(page.Items) != 0 {
+		t.Errorf("expected deleted todo gone from tag filter, got %v", todoIDs(page.Items))
+	}
+}
+
+func listRepo(t *testing.T, repo domain.TodoRepository) domain.ListRepository {
+	t.Helper()
+	lists, ok := repo.(domain.ListRepository)
+	if !ok {
+		t.Skip("repository does not implement domain.ListRepository")
+	}
+	return lists
+}
+
+func testLists(t *testing.T, repo domain.TodoRepository) {
+	lists := listRepo(t, repo)
+	ctx := context.Background()
+
+	work, err := lists.CreateList(ctx, domain.CreateListInput{Name: "Work", Description: "office"})
+	if err != nil {
+		t.Fatalf("CreateList failed: %v", err)
+	}
+	home, err := lists.CreateList(ctx, domain.CreateListInput{Name: "Home"})
+	if err != nil {
+		t.Fatalf("CreateList failed: %v", err)
+	}
+
+	report := mustCreate(t, repo, domain.CreateTodoInput{Title: "Report", ListID: work.ID})
+	if report.ListID != work.ID {
+		t.Errorf("expected list %d, got %d", work.ID, report.ListID)
+	}
+	mustCreate(t, repo, domain.CreateTodoInput{Title: "Email", ListID: work.ID, Completed: true})
+	dishes := mustCreate(t, repo, domain.CreateTodoInput{Title: "Dishes", ListID: home.ID})
+	loose := mustCreate(t, repo, domain.CreateTodoInput{Title: "Loose"})
+
+	if _, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Bad", ListID: 999}); !errors.Is(err, domain.ErrUnknownList) {
+		t.Errorf("expected ErrUnknownList, got %v", err)
+	}
+	bad := 999
+	if _, err := repo.Update(ctx, loose.ID, domain.UpdateTodoInput{ListID: &bad}); !errors.Is(err, domain.ErrUnknownList) {
+		t.Errorf("expected ErrUnknownList on update, got %v", err)
+	}
+
+	got, err := lists.GetList(ctx, work.ID)
+	if err != nil {
+		t.Fatalf("GetList failed: %v", err)
+	}
+	if got.Name != "Work" || got.Description != "office" || got.OpenCount != 1 || got.CompletedCount != 1 {
+		t.Errorf("unexpected list %+v", got)
+	}
+
+	all, err := lists.GetLists(ctx)
+	if err != nil {
+		t.Fatalf("GetLists failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ID != home.ID || all[1].ID != work.ID {
+		t.Fatalf("expected lists sorted by name, got %+v", all)
+	}
+	if all[0].OpenCount != 1 || all[0].CompletedCount != 0 {
+		t.Errorf("unexpected counts for home: %+v", all[0])
+	}
+
+	none := 0
+	for _, tc := range []struct {
+		name   string
+		listID *int
+		want   []int
+	}{
+		{"work", &work.ID, []int{report.ID, report.ID + 1}},
+		{"home", &home.ID, []int{dishes.ID}},
+		{"no list", &none, []int{loose.ID}},
+	} {
+		page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{ListID: tc.listID}})
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", tc.name, err)
+		}
+		if ids := sortedIDs(page.Items); !equalIDs(ids, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, ids)
+		}
+	}
+
+	// moving a todo keeps the rest of it
+	moved, err := repo.Update(ctx, report.ID, domain.UpdateTodoInput{ListID: &home.ID})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if moved.ListID != home.ID || moved.Title != "Report" {
+		t.Errorf("unexpected moved todo %+v", moved)
+	}
+	title := "Report v2"
+	moved, _ = repo.Update(ctx, report.ID, domain.UpdateTodoInput{Title: &title})
+	if moved.ListID != home.ID {
+		t.Errorf("expected an update without list_id to keep the list, got %d", moved.ListID)
+	}
+	moved, _ = repo.Update(ctx, report.ID, domain.UpdateTodoInput{ListID: &none})
+	if moved.ListID != 0 {
+		t.Errorf("expected the todo taken out of its list, got %d", moved.ListID)
+	}
+
+	name := "Household"
+	renamed, err := lists.UpdateList(ctx, home.ID, domain.UpdateListInput{Name: &name})
+	if err != nil {
+		t.Fatalf("UpdateList failed: %v", err)
+	}
+	if renamed.Name != name || renamed.OpenCount != 1 {
+		t.Errorf("unexpected renamed list %+v", renamed)
+	}
+
+	if err := lists.DeleteList(ctx, home.ID, false); !errors.Is(err, domain.ErrListNotEmpty) {
+		t.Errorf("expected ErrListNotEmpty, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, dishes.ID); err != nil {
+		t.Errorf("expected a blocked delete to keep the todo, got %v", err)
+	}
+	if err := lists.DeleteList(ctx, home.ID, true); err != nil {
+		t.Fatalf("DeleteList with cascade failed: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, dishes.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected the cascade to delete the todo, got %v", err)
+	}
+	if _, err := lists.GetList(ctx, home.ID); !errors.Is(err, domain.ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound, got %v", err)
+	}
+
+	empty, _ := lists.CreateList(ctx, domain.CreateListInput{Name: "Empty"})
+	if err := lists.DeleteList(ctx, empty.ID, false); err != nil {
+		t.Errorf("expected an empty list to be deleted, got %v", err)
+	}
+	if err := lists.DeleteList(ctx, empty.ID, false); !errors.Is(err, domain.ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound, got %v", err)
+	}
+	if _, err := lists.UpdateList(ctx, empty.ID, domain.UpdateListInput{Name: &name}); !errors.Is(err, domain.ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound on update, got %v", err)
+	}
+}
+
+func testSubtasks(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	parent := mustCreate(t, repo, domain.CreateTodoInput{Title: "Release", AutoComplete: true})
+	if !parent.AutoComplete {
+		t.Errorf("expected auto_complete stored")
+	}
+	first := mustCreate(t, repo, domain.CreateTodoInput{Title: "Tag", ParentID: parent.ID})
+	second := mustCreate(t, repo, domain.CreateTodoInput{Title: "Publish", ParentID: parent.ID})
+	grandchild := mustCreate(t, repo, domain.CreateTodoInput{Title: "Notes", ParentID: second.ID})
+	if first.ParentID != parent.ID || grandchild.ParentID != second.ID {
+		t.Errorf("unexpected parents %d, %d", first.ParentID, grandchild.ParentID)
+	}
+
+	if _, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Bad", ParentID: 999}); !errors.Is(err, domain.ErrUnknownParent) {
+		t.Errorf("expected ErrUnknownParent, got %v", err)
+	}
+	bad := 999
+	if _, err := repo.Update(ctx, first.ID, domain.UpdateTodoInput{ParentID: &bad}); !errors.Is(err, domain.ErrUnknownParent) {
+		t.Errorf("expected ErrUnknownParent on update, got %v", err)
+	}
+	if _, err := repo.Update(ctx, parent.ID, domain.UpdateTodoInput{ParentID: &parent.ID}); !errors.Is(err, domain.ErrParentCycle) {
+		t.Errorf("expected ErrParentCycle under itself, got %v", err)
+	}
+	if _, err := repo.Update(ctx, parent.ID, domain.UpdateTodoInput{ParentID: &grandchild.ID}); !errors.Is(err, domain.ErrParentCycle) {
+		t.Errorf("expected ErrParentCycle under a subtask, got %v", err)
+	}
+
+	top := 0
+	for _, tc := range []struct {
+		name     string
+		parentID *int
+		want     []int
+	}{
+		{"children", &parent.ID, []int{first.ID, second.ID}},
+		{"grandchildren", &second.ID, []int{grandchild.ID}},
+		{"top level", &top, []int{parent.ID}},
+	} {
+		page, err := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{ParentID: tc.parentID}})
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", tc.name, err)
+		}
+		if ids := sortedIDs(page.Items); !equalIDs(ids, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, ids)
+		}
+	}
+
+	moved, err := repo.Update(ctx, grandchild.ID, domain.UpdateTodoInput{ParentID: &first.ID})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if moved.ParentID != first.ID {
+		t.Errorf("expected parent %d, got %d", first.ID, moved.ParentID)
+	}
+	title := "Release notes"
+	moved, _ = repo.Update(ctx, grandchild.ID, domain.UpdateTodoInput{Title: &title})
+	if moved.ParentID != first.ID {
+		t.Errorf("expected an update without parent_id to keep it, got %d", moved.ParentID)
+	}
+	moved, _ = repo.Update(ctx, grandchild.ID, domain.UpdateTodoInput{ParentID: &top})
+	if moved.ParentID != 0 {
+		t.Errorf("expected the todo moved to the top level, got %d", moved.ParentID)
+	}
+
+	off := false
+	updated, _ := repo.Update(ctx, parent.ID, domain.UpdateTodoInput{AutoComplete: &off})
+	if updated.AutoComplete {
+		t.Errorf("expected auto_complete cleared")
+	}
+}
+
+func depRepo(t *testing.T, repo domain.TodoRepository) domain.DependencyRepository {
+	t.Helper()
+	deps, ok := repo.(domain.DependencyRepository)
+	if !ok {
+		t.Skip("repository does not implement domain.DependencyRepository")
+	}
+	return deps
+}
+
+func testDependencies(t *testing.T, repo domain.TodoRepository) {
+	deps := depRepo(t, repo)
+	ctx := context.Background()
+
+	deploy := mustCreate(t, repo, domain.CreateTodoInput{Title: "Deploy"})
+	build := mustCreate(t, repo, domain.CreateTodoInput{Title: "Build"})
+	review := mustCreate(t, repo, domain.CreateTodoInput{Title: "Review"})
+
+	for _, dep := range []domain.Dependency{
+		{TodoID: deploy.ID, BlockerID: build.ID},
+		{TodoID: deploy.ID, BlockerID: review.ID},
+	} {
+		if err := deps.AddDependency(ctx, dep); err != nil {
+			t.Fatalf("AddDependency failed: %v", err)
+		}
+	}
+	if err := deps.AddDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: build.ID}); !errors.Is(err, domain.ErrDependencyExists) {
+		t.Errorf("expected ErrDependencyExists, got %v", err)
+	}
+	if err := deps.AddDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: 999}); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound, got %v", err)
+	}
+	if err := deps.AddDependency(ctx, domain.Dependency{TodoID: build.ID, BlockerID: build.ID}); !errors.Is(err, domain.ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle on itself, got %v", err)
+	}
+	if err := deps.AddDependency(ctx, domain.Dependency{TodoID: build.ID, BlockerID: deploy.ID}); !errors.Is(err, domain.ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle, got %v", err)
+	}
+
+	got, _ := repo.GetByID(ctx, deploy.ID)
+	if !equalIDs(got.BlockedBy, []int{build.ID, review.ID}) || !got.Blocked {
+		t.Errorf("expected deploy blocked by build and review, got %v blocked=%v", got.BlockedBy, got.Blocked)
+	}
+	got, _ = repo.GetByID(ctx, build.ID)
+	if len(got.BlockedBy) != 0 || got.Blocked {
+		t.Errorf("expected build unblocked, got %v blocked=%v", got.BlockedBy, got.Blocked)
+	}
+
+	done := true
+	repo.Update(ctx, build.ID, domain.UpdateTodoInput{Completed: &done})
+	got, _ = repo.GetByID(ctx, deploy.ID)
+	if !got.Blocked {
+		t.Errorf("expected deploy blocked while review is open")
+	}
+	if _, err := repo.Update(ctx, deploy.ID, domain.UpdateTodoInput{Completed: &done}); !errors.Is(err, domain.ErrTodoBlocked) {
+		t.Errorf("expected ErrTodoBlocked completing deploy, got %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, deploy.ID); got.Completed || got.Version != deploy.Version {
+		t.Errorf("expected the refused completion to leave deploy alone, got %+v", got)
+	}
+	repo.Update(ctx, review.ID, domain.UpdateTodoInput{Completed: &done})
+	page, _ := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{}})
+	for _, todo := range page.Items {
+		if todo.ID == deploy.ID && (todo.Blocked || len(todo.BlockedBy) != 2) {
+			t.Errorf("expected deploy listed unblocked with its blockers, got %+v", todo)
+		}
+	}
+
+	if err := deps.RemoveDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: review.ID}); err != nil {
+		t.Fatalf("RemoveDependency failed: %v", err)
+	}
+	if err := deps.RemoveDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: review.ID}); !errors.Is(err, domain.ErrDependencyNotFound) {
+		t.Errorf("expected ErrDependencyNotFound, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, build.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	all, err := deps.ListDependencies(ctx)
+	if err != nil {
+		t.Fatalf("ListDependencies failed: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("expected the deleted todo's edges gone, got %+v", all)
+	}
+	got, _ = repo.GetByID(ctx, deploy.ID)
+	if len(got.BlockedBy) != 0 {
+		t.Errorf("expected no blockers left, got %v", got.BlockedBy)
+	}
+}
+
+func testRecurrence(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	due := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+	first := mustCreate(t, repo, domain.CreateTodoInput{Title: "Standup", DueAt: &due, Recurrence: "FREQ=WEEKLY", Occurrence: 1})
+	if first.Recurrence != "FREQ=WEEKLY" || first.Occurrence != 1 || first.PreviousOccurrenceID != 0 {
+		t.Errorf("unexpected recurrence fields %+v", first)
+	}
+	second := mustCreate(t, repo, domain.CreateTodoInput{Title: "Standup", Recurrence: "FREQ=WEEKLY", Occurrence: 2, PreviousOccurrenceID: first.ID})
+	if second.Occurrence != 2 || second.PreviousOccurrenceID != first.ID {
+		t.Errorf("expected occurrence 2 after %d, got %+v", first.ID, second)
+	}
+
+	updated, err := repo.Update(ctx, first.ID, domain.UpdateTodoInput{NextOccurrenceID: &second.ID})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.NextOccurrenceID != second.ID || updated.Recurrence != "FREQ=WEEKLY" {
+		t.Errorf("expected next occurrence %d kept with the rule, got %+v", second.ID, updated)
+	}
+
+	none := ""
+	updated, _ = repo.Update(ctx, second.ID, domain.UpdateTodoInput{Recurrence: &none})
+	if updated.Recurrence != "" || updated.Occurrence != 2 {
+		t.Errorf("expected the rule cleared and the count kept, got %+v", updated)
+	}
+}
+
+func testReminders(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	at := time.Date(2025, 1, 6, 8, 30, 0, 0, time.UTC)
+
+	todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Call", RemindAt: &at})
+	if todo.RemindAt == nil || !todo.RemindAt.Equal(at) || todo.RemindedAt != nil {
+		t.Fatalf("expected reminder at %s, got %v (reminded %v)", at, todo.RemindAt, todo.RemindedAt)
+	}
+	if !todo.ReminderPending() {
+		t.Errorf("expected the reminder pending")
+	}
+
+	sent := at.Add(time.Second)
+	updated, err := repo.Update(ctx, todo.ID, domain.UpdateTodoInput{RemindedAt: &sent})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.RemindedAt == nil || !updated.RemindedAt.Equal(sent) || updated.ReminderPending() {
+		t.Errorf("expected the reminder delivered at %s, got %v", sent, updated.RemindedAt)
+	}
+
+	later := at.Add(time.Hour)
+	updated, _ = repo.Update(ctx, todo.ID, domain.UpdateTodoInput{RemindAt: &later})
+	if !updated.ReminderPending() || !updated.RemindedAt.Equal(sent) {
+		t.Errorf("expected a later reminder pending again, got %v (reminded %v)", updated.RemindAt, updated.RemindedAt)
+	}
+
+	updated, _ = repo.Update(ctx, todo.ID, domain.UpdateTodoInput{ClearRemindAt: true})
+	if updated.RemindAt != nil || updated.ReminderPending() {
+		t.Errorf("expected the reminder cleared, got %v", updated.RemindAt)
+	}
+}
+
+func testMarkReminded(t *testing.T, repo domain.TodoRepository) {
+	reminders, ok := repo.(domain.ReminderRepository)
+	if !ok {
+		t.Skip("repository does not implement domain.ReminderRepository")
+	}
+	ctx := context.Background()
+	at := time.Date(2025, 1, 6, 8, 30, 0, 0, time.UTC)
+
+	todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Call", RemindAt: &at})
+	sent := at.Add(time.Second)
+	if err := reminders.MarkReminded(ctx, todo.ID, *todo.RemindAt, sent); err != nil {
+		t.Fatalf("MarkReminded failed: %v", err)
+	}
+	got, _ := repo.GetByID(ctx, todo.ID)
+	if got.RemindedAt == nil || !got.RemindedAt.Equal(sent) || got.ReminderPending() {
+		t.Errorf("expected the reminder delivered at %s, got %v", sent, got.RemindedAt)
+	}
+	if got.Version != todo.Version || !got.UpdatedAt.Equal(todo.UpdatedAt) {
+		t.Errorf("expected version %d and updated_at %s kept, got %d and %s", todo.Version, todo.UpdatedAt, got.Version, got.UpdatedAt)
+	}
+
+	later := at.Add(time.Hour)
+	repo.Update(ctx, todo.ID, domain.UpdateTodoInput{RemindAt: &later})
+	if err := reminders.MarkReminded(ctx, todo.ID, at, later); !errors.Is(err, domain.ErrReminderMoved) {
+		t.Errorf("expected ErrReminderMoved for the old time, got %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, todo.ID); !got.ReminderPending() {
+		t.Errorf("expected the moved reminder still pending, got reminded %v", got.RemindedAt)
+	}
+
+	repo.Update(ctx, todo.ID, domain.UpdateTodoInput{ClearRemindAt: true})
+	if err := reminders.MarkReminded(ctx, todo.ID, later, later); !errors.Is(err, domain.ErrReminderMoved) {
+		t.Errorf("expected ErrReminderMoved for a cleared reminder, got %v", err)
+	}
+	if err := reminders.MarkReminded(ctx, 999, at, sent); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound, got %v", err)
+	}
+}
+
+func testVersions(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+
+	todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Task"})
+	if todo.Version != 1 {
+		t.Fatalf("expected a new todo at version 1, got %d", todo.Version)
+	}
+
+	title := "Renamed"
+	updated, err := repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Title: &title})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("expected version 2 after an update, got %d", updated.Version)
+	}
+
+	stale := 1
+	if _, err := repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Title: &title, Version: &stale}); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for a stale update, got %v", err)
+	}
+	current := 2
+	updated, err = repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Title: &title, Version: &current})
+	if err != nil {
+		t.Fatalf("versioned Update failed: %v", err)
+	}
+	if updated.Version != 3 {
+		t.Errorf("expected version 3, got %d", updated.Version)
+	}
+	if _, err := repo.Update(ctx, 999, domain.UpdateTodoInput{Title: &title, Version: &current}); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound for a missing todo, got %v", err)
+	}
+
+	if err := repo.DeleteVersion(ctx, todo.ID, 2); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for a stale delete, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, todo.ID); err != nil {
+		t.Fatalf("expected the todo to survive a stale delete, got %v", err)
+	}
+	if err := repo.DeleteVersion(ctx, todo.ID, 3); err != nil {
+		t.Fatalf("DeleteVersion failed: %v", err)
+	}
+	if err := repo.DeleteVersion(ctx, todo.ID, 3); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound after the delete, got %v", err)
+	}
+}
+
+func testTransactions(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	txr, ok := repo.(domain.Transactor)
+	if !ok {
+		t.Skip("repository does not implement domain.Transactor")
+	}
+
+	kept := mustCreate(t, repo, domain.CreateTodoInput{Title: "Kept"})
+	removed := mustCreate(t, repo, domain.CreateTodoInput{Title: "Removed"})
+
+	failed := errors.New("give up")
+	err := txr.InTx(ctx, func(tx domain.TodoRepository) error {
+		if _, err := tx.Create(ctx, domain.CreateTodoInput{Title: "Rolled back"}); err != nil {
+			return err
+		}
+		title := "Changed"
+		if _, err := tx.Update(ctx, kept.ID, domain.UpdateTodoInput{Title: &title}); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, removed.ID); err != nil {
+			return err
+		}
+		// a failed write leaves the transaction usable
+		if err := tx.Delete(ctx, 999); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound inside the transaction, got %v", err)
+		}
+		if _, err := tx.GetByID(ctx, removed.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("expected the transaction to see its own delete, got %v", err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected InTx to return the error of fn, got %v", err)
+	}
+	all, _ := repo.GetAll(ctx)
+	if !equalIDs(sortedIDs(all), []int{kept.ID, removed.ID}) {
+		t.Fatalf("expected a failed transaction to change nothing, got %v", sortedIDs(all))
+	}
+	if got, _ := repo.GetByID(ctx, kept.ID); got.Title != "Kept" || got.Version != 1 {
+		t.Errorf("expected the update to be rolled back, got %+v", got)
+	}
+
+	var created *domain.Todo
+	err = txr.InTx(ctx, func(tx domain.TodoRepository) error {
+		var err error
+		if created, err = tx.Create(ctx, domain.CreateTodoInput{Title: "Committed"}); err != nil {
+			return err
+		}
+		done := true
+		if _, err := tx.Update(ctx, kept.ID, domain.UpdateTodoInput{Completed: &done}); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, removed.ID)
+	})
+	if err != nil {
+		t.Fatalf("InTx failed: %v", err)
+	}
+	all, _ = repo.GetAll(ctx)
+	if !equalIDs(sortedIDs(all), []int{kept.ID, created.ID}) {
+		t.Errorf("expected the committed changes, got %v", sortedIDs(all))
+	}
+	if got, _ := repo.GetByID(ctx, kept.ID); !got.Completed || got.Version != 2 {
+		t.Errorf("expected the update to be committed, got %+v", got)
+	}
+	if next := mustCreate(t, repo, domain.CreateTodoInput{Title: "After"}); next.ID <= created.ID {
+		t.Errorf("expected ids to keep growing after a transaction, got %d after %d", next.ID, created.ID)
+	}
+}
+
+func trashRepo(t *testing.T, repo domain.TodoRepository) domain.TrashRepository {
+	t.Helper()
+	trash, ok := repo.(domain.TrashRepository)
+	if !ok {
+		t.Skip("repository does not implement domain.TrashRepository")
+	}
+	return trash
+}
+
+func testTrash(t *testing.T, repo domain.TodoRepository) {
+	trash := trashRepo(t, repo)
+	ctx := context.Background()
+
+	parent := mustCreate(t, repo, domain.CreateTodoInput{Title: "Parent"})
+	child := mustCreate(t, repo, domain.CreateTodoInput{Title: "Child", ParentID: parent.ID})
+	kept := mustCreate(t, repo, domain.CreateTodoInput{Title: "Kept"})
+
+	if err := repo.Delete(ctx, child.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	// DeletedAt orders the trash
+	time.Sleep(5 * time.Millisecond)
+	if err := repo.Delete(ctx, parent.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, parent.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected a trashed todo to be hidden from GetByID, got %v", err)
+	}
+	if err := repo.Delete(ctx, parent.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound deleting a trashed todo, got %v", err)
+	}
+	title := "Changed"
+	if _, err := repo.Update(ctx, parent.ID, domain.UpdateTodoInput{Title: &title}); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound updating a trashed todo, got %v", err)
+	}
+	all, _ := repo.GetAll(ctx)
+	if !equalIDs(todoIDs(all), []int{kept.ID}) {
+		t.Errorf("expected GetAll to leave out the trash, got %v", todoIDs(all))
+	}
+	filtered, _ := repo.GetFiltered(ctx, nil, "")
+	if !equalIDs(todoIDs(filtered), []int{kept.ID}) {
+		t.Errorf("expected GetFiltered to leave out the trash, got %v", todoIDs(filtered))
+	}
+	page, _ := repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{ParentID: &parent.ID}})
+	if page.Total != 0 || len(page.Items) != 0 {
+		t.Errorf("expected List to leave out the trash, got %+v", page)
+	}
+
+	trashed, err := trash.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if !equalIDs(todoIDs(trashed), []int{parent.ID, child.ID}) {
+		t.Fatalf("expected the trash most recently deleted first, got %v", todoIDs(trashed))
+	}
+	if trashed[0].DeletedAt == nil || trashed[0].Version != 2 {
+		t.Errorf("expected a trashed todo with deleted_at at version 2, got %+v", trashed[0])
+	}
+
+	if _, err := trash.RestoreDeleted(ctx, child.ID); !errors.Is(err, domain.ErrParentDeleted) {
+		t.Errorf("expected ErrParentDeleted, got %v", err)
+	}
+	if _, err := trash.RestoreDeleted(ctx, kept.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound restoring a todo not in the trash, got %v", err)
+	}
+
+	restored, err := trash.RestoreDeleted(ctx, parent.ID)
+	if err != nil {
+		t.Fatalf("RestoreDeleted failed: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 || restored.Title != "Parent" {
+		t.Errorf("unexpected restored todo %+v", restored)
+	}
+	if restored, err = trash.RestoreDeleted(ctx, child.ID); err != nil {
+		t.Fatalf("RestoreDeleted failed: %v", err)
+	}
+	if restored.ParentID != parent.ID {
+		t.Errorf("expected the child back under its parent, got parent %d", restored.ParentID)
+	}
+	if got, err := repo.GetByID(ctx, child.ID); err != nil || got.DeletedAt != nil {
+		t.Errorf("expected the restored todo to be found, got %+v, %v", got, err)
+	}
+
+	// a child whose parent was purged comes back without one
+	repo.Delete(ctx, parent.ID)
+	time.Sleep(5 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	repo.Delete(ctx, child.ID)
+
+	if n, err := trash.PurgeTrash(ctx, cutoff.Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected nothing purged, got %d, %v", n, err)
+	}
+	if n, err := trash.PurgeTrash(ctx, cutoff); err != nil || n != 1 {
+		t.Errorf("expected the parent purged, got %d, %v", n, err)
+	}
+	if _, err := trash.RestoreDeleted(ctx, parent.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected a purged todo to be gone, got %v", err)
+	}
+	if restored, err = trash.RestoreDeleted(ctx, child.ID); err != nil {
+		t.Fatalf("RestoreDeleted failed: %v", err)
+	}
+	if restored.ParentID != 0 {
+		t.Errorf("expected the orphan without a parent, got %d", restored.ParentID)
+	}
+
+	lists, ok := repo.(domain.ListRepository)
+	if !ok {
+		return
+	}
+	list, err := lists.CreateList(ctx, domain.CreateListInput{Name: "Work"})
+	if err != nil {
+		t.Fatalf("CreateList failed: %v", err)
+	}
+	report := mustCreate(t, repo, domain.CreateTodoInput{Title: "Report", ListID: list.ID})
+	repo.Delete(ctx, report.ID)
+	if got, _ := lists.GetList(ctx, list.ID); got.OpenCount != 0 {
+		t.Errorf("expected the list to leave out the trash, got %+v", got)
+	}
+	if err := lists.DeleteList(ctx, list.ID, false); err != nil {
+		t.Fatalf("expected a list with only trashed todos to be empty, got %v", err)
+	}
+	if restored, err = trash.RestoreDeleted(ctx, report.ID); err != nil {
+		t.Fatalf("expected the todo of a deleted list to stay in the trash, got %v", err)
+	}
+	if restored.ListID != 0 {
+		t.Errorf("expected the todo restored without a list, got %d", restored.ListID)
+	}
+
+	// a cascade moves the todos of the list to the trash
+	list, _ = lists.CreateList(ctx, domain.CreateListInput{Name: "Home"})
+	dishes := mustCreate(t, repo, domain.CreateTodoInput{Title: "Dishes", ListID: list.ID})
+	if err := lists.DeleteList(ctx, list.ID, true); err != nil {
+		t.Fatalf("DeleteList with cascade failed: %v", err)
+	}
+	trashed, _ = trash.ListTrash(ctx)
+	if len(trashed) == 0 || trashed[0].ID != dishes.ID || trashed[0].ListID != 0 || trashed[0].DeletedAt == nil {
+		t.Errorf("expected the todo in the trash without a list, got %+v", trashed)
+	}
+}
+
+func webhookRepo(t *testing.T, repo domain.TodoRepository) domain.WebhookRepository {
+	t.Helper()
+	hooks, ok := repo.(domain.WebhookRepository)
+	if !ok {
+		t.Skip("repository does not implement domain.WebhookRepository")
+	}
+	return hooks
+}
+
+func testWebhooks(t *testing.T, repo domain.TodoRepository) {
+	hooks := webhookRepo(t, repo)
+	ctx := context.Background()
+
+	all, err := hooks.CreateWebhook(ctx, domain.CreateWebhookInput{URL: "http://all.example/hook", Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if !all.Active || len(all.Events) != 0 || all.Secret != "0123456789abcdef" {
+		t.Errorf("expected an active webhook for every event, got %+v", all)
+	}
+
+	off := false
+	some, _ := hooks.CreateWebhook(ctx, domain.CreateWebhookInput{
+		URL:    "http://some.example/hook",
+		Secret: "fedcba9876543210",
+		Events: []domain.EventType{domain.EventTodoCreated, domain.EventTodoDeleted},
+		Active: &off,
+	})
+	if some.Active || len(some.Events) != 2 || some.Events[1] != domain.EventTodoDeleted {
+		t.Errorf("unexpected webhook %+v", some)
+	}
+
+	url := "http://moved.example/hook"
+	events := []domain.EventType{domain.EventTodoCompleted}
+	on := true
+	updated, err := hooks.UpdateWebhook(ctx, some.ID, domain.UpdateWebhookInput{URL: &url, Events: &events, Active: &on})
+	if err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	if updated.URL != url || !updated.Active || len(updated.Events) != 1 || updated.Secret != "fedcba9876543210" {
+		t.Errorf("expected url, events and active changed, got %+v", updated)
+	}
+
+	list, err := hooks.ListWebhooks(ctx)
+	if err != nil {
+		t.Fatalf("ListWebhooks failed: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != all.ID || list[1].ID != some.ID {
+		t.Errorf("expected both webhooks by id, got %+v", list)
+	}
+
+	if err := hooks.DeleteWebhook(ctx, all.ID); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	if _, err := hooks.GetWebhook(ctx, all.ID); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if _, err := hooks.UpdateWebhook(ctx, all.ID, domain.UpdateWebhookInput{Active: &on}); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound on update, got %v", err)
+	}
+	if err := hooks.DeleteWebhook(ctx, all.ID); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound on delete, got %v", err)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	const n = 50
+
+	var wg sync.WaitGroup
+	ids := make(chan int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			todo, err := repo.Create(ctx, domain.CreateTodoInput{Title: "Task " + strconv.Itoa(i)})
+			if err != nil {
+				t.Errorf("Create failed: %v", err)
+				return
+			}
+			ids <- todo.ID
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("ID %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != n {
+		t.Errorf("expected %d todos, got %d", n, len(all))
+	}
+}
+
+func testConcurrentUpdate(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Task"})
+	const n = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			title := "Title " + strconv.Itoa(i)
+			if _, err := repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Title: &title}); err != nil {
+				t.Errorf("Update failed: %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetByID(ctx, todo.ID); err != nil {
+				t.Errorf("GetByID failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.GetByID(ctx, todo.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.Title == "Task" {
+		t.Errorf("expected one of the concurrent updates to win, got %q", got.Title)
+	}
+	if got.Version != n+1 {
+		t.Errorf("expected version %d after %d updates, got %d", n+1, n, got.Version)
+	}
+}
+
+func testConcurrentVersionedUpdate(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	todo := mustCreate(t, repo, domain.CreateTodoInput{Title: "Task"})
+	const n = 20
+
+	var (
+		wg  sync.WaitGroup
+		won atomic.Int32
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			title := "Title " + strconv.Itoa(i)
+			_, err := repo.Update(ctx, todo.ID, domain.UpdateTodoInput{Title: &title, Version: &todo.Version})
+			switch {
+			case err == nil:
+				won.Add(1)
+			case !errors.Is(err, domain.ErrVersionMismatch):
+				t.Errorf("Update failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if won.Load() != 1 {
+		t.Errorf("expected exactly one update at version %d to win, %d did", todo.Version, won.Load())
+	}
+}
+
+func todoIDs(todos []domain.Todo) []int {
+	ids := make([]int, 0, len(todos))
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func sortedIDs(todos []domain.Todo) []int {
+	ids := make([]int, 0, len(todos))
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Ints(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// testConcurrentCycles closes a cycle from both ends at once; the checks must
+// see each other so that at most one side wins.
+func testConcurrentCycles(t *testing.T, repo domain.TodoRepository) {
+	ctx := context.Background()
+	deps, _ := repo.(domain.DependencyRepository)
+
+	for i := 0; i < 10; i++ {
+		a := mustCreate(t, repo, domain.CreateTodoInput{Title: "A"})
+		b := mustCreate(t, repo, domain.CreateTodoInput{Title: "B"})
+
+		var wg sync.WaitGroup
+		parentErrs := make(chan error, 2)
+		depErrs := make(chan error, 2)
+		for _, pair := range [][2]int{{a.ID, b.ID}, {b.ID, a.ID}} {
+			wg.Add(1)
+			go func(id, other int) {
+				defer wg.Done()
+				_, err := repo.Update(ctx, id, domain.UpdateTodoInput{ParentID: &other})
+				parentErrs <- err
+				if deps != nil {
+					depErrs <- deps.AddDependency(ctx, domain.Dependency{TodoID: id, BlockerID: other})
+				}
+			}(pair[0], pair[1])
+		}
+		wg.Wait()
+		close(parentErrs)
+		close(depErrs)
+
+		for name, errs := range map[string]chan error{"parent": parentErrs, "dependency": depErrs} {
+			won := 0
+			for err := range errs {
+				if err == nil {
+					won++
+				}
+			}
+			if won > 1 {
+				t.Fatalf("%s cycle between %d and %d was stored", name, a.ID, b.ID)
+			}
+		}
+	}
+}
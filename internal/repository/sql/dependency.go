@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) AddDependency(ctx context.Context, dep domain.Dependency) error {
+	return r.inTx(ctx, func(tx *stdsql.Tx) error {
+		var count int
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT COUNT(*) FROM todos WHERE id IN (?, ?) AND deleted_at IS NULL`), dep.TodoID, dep.BlockerID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		want := 2
+		if dep.TodoID == dep.BlockerID {
+			want = 1
+		}
+		if count != want {
+			return domain.ErrTodoNotFound
+		}
+		if dep.TodoID == dep.BlockerID {
+			return domain.ErrDependencyCycle
+		}
+
+		// two edges checked side by side could each close half of a cycle
+		if err := r.lockGraph(ctx, tx, graphDependencies); err != nil {
+			return err
+		}
+		var cycle bool
+		err = tx.QueryRowContext(ctx, r.dialect.rebind(`
+			WITH RECURSIVE blockers (id) AS (
+				SELECT blocker_id FROM todo_dependencies WHERE todo_id = ?
+				UNION
+				SELECT todo_dependencies.blocker_id FROM todo_dependencies JOIN blockers ON todo_dependencies.todo_id = blockers.id
+			)
+			SELECT EXISTS (SELECT 1 FROM blockers WHERE id = ?)`), dep.BlockerID, dep.TodoID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return domain.ErrDependencyCycle
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(`INSERT INTO todo_dependencies (todo_id, blocker_id) VALUES (?, ?)`), dep.TodoID, dep.BlockerID)
+		if isUniqueViolation(err) {
+			return domain.ErrDependencyExists
+		}
+		return err
+	})
+}
+
+func (r *TodoRepository) RemoveDependency(ctx context.Context, dep domain.Dependency) error {
+	res, err := r.q().ExecContext(ctx, r.dialect.rebind(`DELETE FROM todo_dependencies WHERE todo_id = ? AND blocker_id = ?`), dep.TodoID, dep.BlockerID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrDependencyNotFound
+	}
+	return nil
+}
+
+func (r *TodoRepository) ListDependencies(ctx context.Context) ([]domain.Dependency, error) {
+	rows, err := r.q().QueryContext(ctx, `SELECT todo_id, blocker_id FROM todo_dependencies ORDER BY todo_id, blocker_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deps := []domain.Dependency{}
+	for rows.Next() {
+		var dep domain.Dependency
+		if err := rows.Scan(&dep.TodoID, &dep.BlockerID); err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+
+	return deps, rows.Err()
+}
@@ -0,0 +1,225 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// Driver is the database/sql driver name registered for the dialect. The
+// driver itself must be linked in by the binary.
+func (d Dialect) Driver() string {
+	switch d {
+	case DialectSQLite:
+		return "sqlite3"
+	default:
+		return string(d)
+	}
+}
+
+// ParseURL splits a DATABASE_URL into its dialect and the DSN handed to the
+// driver: sqlite://path/to/todo.db (or sqlite::memory:) and postgres://...
+func ParseURL(databaseURL string) (Dialect, string, error) {
+	scheme, rest, ok := strings.Cut(databaseURL, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid database url %q", databaseURL)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "sqlite", "sqlite3", "file":
+		dsn := strings.TrimPrefix(rest, "//")
+		if dsn == "" {
+			return "", "", fmt.Errorf("sqlite url %q has no path", databaseURL)
+		}
+		if !strings.Contains(dsn, "_busy_timeout") {
+			// wait for other processes, e.g. a concurrent migrate, instead of failing
+			sep := "?"
+			if strings.Contains(dsn, "?") {
+				sep = "&"
+			}
+			dsn += sep + "_busy_timeout=5000"
+		}
+		return DialectSQLite, dsn, nil
+	case "postgres", "postgresql":
+		return DialectPostgres, databaseURL, nil
+	default:
+		return "", "", fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
+
+// rebind rewrites ?-placeholders into the dialect's form.
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// noDueAt stands in for a missing due date when sorting, after every due
+// date domain.ValidDueAt accepts.
+var noDueAt = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// sortColumn is the expression ordered by for field. Titles use byte-wise
+// collation to match domain.CompareTodos, which sqlite does by default.
+func (d Dialect) sortColumn(field domain.SortField) string {
+	switch {
+	case field == domain.SortByTitle && d == DialectPostgres:
+		return `title COLLATE "C"`
+	case field == domain.SortByPriority:
+		return priorityRank
+	case field == domain.SortByDueAt && d == DialectPostgres:
+		return `COALESCE(due_at, '9999-12-31 00:00:00+00'::timestamptz)`
+	case field == domain.SortByDueAt:
+		// the text form the sqlite driver stores UTC times in
+		return `COALESCE(due_at, '9999-12-31 00:00:00+00:00')`
+	}
+	return string(field)
+}
+
+var priorityRank = func() string {
+	var b strings.Builder
+	b.WriteString("CASE priority")
+	for _, p := range domain.Priorities {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", p, p.Rank())
+	}
+	b.WriteString(" ELSE 0 END")
+	return b.String()
+}()
+
+func (d Dialect) orderClause(keys []domain.SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = d.sortColumn(k.Field)
+		if k.Desc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// keysetClause matches rows that sort strictly after the cursor:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys.
+func (d Dialect) keysetClause(keys []domain.SortKey, after domain.Cursor) (string, []interface{}) {
+	var (
+		terms []string
+		args  []interface{}
+	)
+
+	for i, k := range keys {
+		var conds []string
+		for _, prev := range keys[:i] {
+			conds = append(conds, d.sortColumn(prev.Field)+" = ?")
+			args = append(args, cursorValue(after, prev.Field))
+		}
+
+		op := " > ?"
+		if k.Desc {
+			op = " < ?"
+		}
+		conds = append(conds, d.sortColumn(k.Field)+op)
+		args = append(args, cursorValue(after, k.Field))
+
+		terms = append(terms, "("+strings.Join(conds, " AND ")+")")
+	}
+
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+// tagNames selects the comma separated tag names of the todo with id todoID,
+// NULL when it has none.
+func (d Dialect) tagNames(todoID string) string {
+	agg := "group_concat(tags.name, ',')"
+	if d == DialectPostgres {
+		agg = "string_agg(tags.name, ',' ORDER BY tags.name)"
+	}
+	return `(SELECT ` + agg + ` FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id WHERE todo_tags.todo_id = ` + todoID + `)`
+}
+
+// blockerIDs selects the comma separated ids of the todos blocking todoID,
+// NULL when there are none.
+func (d Dialect) blockerIDs(todoID string) string {
+	agg := "group_concat(blocker_id, ',')"
+	if d == DialectPostgres {
+		agg = "string_agg(CAST(blocker_id AS TEXT), ',')"
+	}
+	return `(SELECT ` + agg + ` FROM todo_dependencies WHERE todo_dependencies.todo_id = ` + todoID + `)`
+}
+
+// graphLock names a transaction-scoped lock taken before checking the parent
+// tree or the dependency graph for cycles.
+type graphLock int
+
+const (
+	graphParents graphLock = iota + 1
+	graphDependencies
+)
+
+// lockGraph is the statement that takes lock, empty where transactions
+// already run one at a time as in sqlite.
+func (d Dialect) lockGraph(lock graphLock) string {
+	if d != DialectPostgres {
+		return ""
+	}
+	return `SELECT pg_advisory_xact_lock(` + strconv.Itoa(int(lock)) + `)`
+}
+
+// blocked is true when any blocker of todoID is still open.
+func blocked(todoID string) string {
+	return `EXISTS (SELECT 1 FROM todo_dependencies JOIN todos blocker ON blocker.id = todo_dependencies.blocker_id
+		WHERE todo_dependencies.todo_id = ` + todoID + ` AND NOT blocker.completed)`
+}
+
+func withoutScore(keys []domain.SortKey) []domain.SortKey {
+	var out []domain.SortKey
+	for _, k := range keys {
+		if k.Field != domain.SortByScore {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func cursorValue(c domain.Cursor, field domain.SortField) interface{} {
+	todo := c.Todo()
+	switch field {
+	case domain.SortByTitle:
+		return todo.Title
+	case domain.SortByCompleted:
+		return todo.Completed
+	case domain.SortByCreatedAt:
+		return todo.CreatedAt.UTC()
+	case domain.SortByUpdatedAt:
+		return todo.UpdatedAt.UTC()
+	case domain.SortByPriority:
+		return todo.Priority.Rank()
+	case domain.SortByDueAt:
+		if todo.DueAt == nil {
+			return noDueAt
+		}
+		return todo.DueAt.UTC()
+	default:
+		return todo.ID
+	}
+}
@@ -0,0 +1,202 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (d Dialect) filterClause(filter domain.TodoFilter) ([]string, []interface{}, error) {
+	var (
+		where = []string{"deleted_at IS NULL"}
+		args  []interface{}
+	)
+
+	if filter.Completed != nil {
+		where = append(where, "completed = ?")
+		args = append(args, *filter.Completed)
+	}
+
+	if len(filter.Priorities) > 0 {
+		where = append(where, "priority IN ("+placeholders(len(filter.Priorities))+")")
+		for _, p := range filter.Priorities {
+			args = append(args, string(p))
+		}
+	}
+
+	if filter.DueBefore != nil {
+		where = append(where, "due_at < ?")
+		args = append(args, filter.DueBefore.UTC())
+	}
+
+	if filter.DueAfter != nil {
+		where = append(where, "due_at > ?")
+		args = append(args, filter.DueAfter.UTC())
+	}
+
+	if filter.Overdue != nil {
+		// never NULL thanks to the IS NOT NULL guard, so NOT is safe
+		clause := "(completed = ? AND due_at IS NOT NULL AND due_at < ?)"
+		if !*filter.Overdue {
+			clause = "NOT " + clause
+		}
+		where = append(where, clause)
+		args = append(args, false, filter.Now.UTC())
+	}
+
+	if len(filter.Tags) > 0 {
+		names := uniqueStrings(filter.Tags)
+		clause := "id IN (SELECT todo_tags.todo_id FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id WHERE tags.name IN (" + placeholders(len(names)) + ")"
+		for _, name := range names {
+			args = append(args, name)
+		}
+		if filter.TagMatch == domain.TagMatchAll {
+			clause += " GROUP BY todo_tags.todo_id HAVING COUNT(*) = ?"
+			args = append(args, len(names))
+		}
+		where = append(where, clause+")")
+	}
+
+	if filter.ListID != nil {
+		if *filter.ListID == 0 {
+			where = append(where, "list_id IS NULL")
+		} else {
+			where = append(where, "list_id = ?")
+			args = append(args, *filter.ListID)
+		}
+	}
+
+	if filter.ParentID != nil {
+		if *filter.ParentID == 0 {
+			where = append(where, "parent_id IS NULL")
+		} else {
+			where = append(where, "parent_id = ?")
+			args = append(args, *filter.ParentID)
+		}
+	}
+
+	for _, term := range domain.ParseSearch(filter.Search) {
+		where = append(where, `search_text LIKE ? ESCAPE '\'`)
+		args = append(args, searchPattern(term))
+	}
+
+	if filter.Expr != nil {
+		clause, exprArgs, err := d.filterExpr(filter.Expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, clause)
+		args = append(args, exprArgs...)
+	}
+
+	return where, args, nil
+}
+
+// filterExpr translates a parsed filter into a WHERE fragment with the same
+// semantics as domain.FilterExpr.Match.
+func (d Dialect) filterExpr(expr domain.FilterExpr) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case domain.AndExpr:
+		return d.joinExprs(e.Exprs, " AND ")
+	case domain.OrExpr:
+		return d.joinExprs(e.Exprs, " OR ")
+	case domain.NotExpr:
+		clause, args, err := d.filterExpr(e.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT " + clause, args, nil
+	case domain.Condition:
+		return d.condition(e)
+	default:
+		return "", nil, fmt.Errorf("unsupported filter expression %T", expr)
+	}
+}
+
+func (d Dialect) joinExprs(exprs []domain.FilterExpr, sep string) (string, []interface{}, error) {
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, x := range exprs {
+		clause, xargs, err := d.filterExpr(x)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, clause)
+		args = append(args, xargs...)
+	}
+	return "(" + strings.Join(parts, sep) + ")", args, nil
+}
+
+func (d Dialect) condition(c domain.Condition) (string, []interface{}, error) {
+	column := string(c.Field)
+
+	switch c.Op {
+	case domain.OpIn:
+		ids := c.Value.([]int)
+		args := make([]interface{}, len(ids))
+		for i, id := range ids {
+			args[i] = id
+		}
+		return "(" + column + " IN (" + placeholders(len(ids)) + "))", args, nil
+	case domain.OpPrefix:
+		return "(LOWER(" + column + `) LIKE ? ESCAPE '\')`, []interface{}{escapeLike(strings.ToLower(c.Value.(string))) + "%"}, nil
+	case domain.OpContains:
+		return "(LOWER(" + column + `) LIKE ? ESCAPE '\')`, []interface{}{"%" + escapeLike(strings.ToLower(c.Value.(string))) + "%"}, nil
+	case domain.OpEq, domain.OpNe, domain.OpLt, domain.OpLe, domain.OpGt, domain.OpGe:
+		value := c.Value
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC()
+		}
+		op := string(c.Op)
+		if c.Op == domain.OpNe {
+			op = "<>"
+		}
+		if c.Field == domain.FilterTitle || c.Field == domain.FilterDescription {
+			column = d.sortColumn(domain.SortField(c.Field))
+		}
+		return "(" + column + " " + op + " ?)", []interface{}{value}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported filter operator %q", c.Op)
+	}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	var out []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// searchText is what search terms are matched against: the words of the
+// title and of the description, see domain.Tokenize, each followed and
+// preceded by a space and the two parts separated by "|". LIKE patterns can
+// then tell where words start and end.
+func searchText(title, description string) string {
+	return " " + strings.Join(domain.Tokenize(title), " ") + " | " + strings.Join(domain.Tokenize(description), " ") + " "
+}
+
+// searchPattern matches search_text the way term.Match matches words.
+func searchPattern(term domain.SearchTerm) string {
+	text := escapeLike(strings.Join(term.Tokens, " "))
+	switch {
+	case term.IsPhrase():
+		return "% " + text + " %"
+	case term.Prefix:
+		return "% " + text + "%"
+	default:
+		return "%" + text + "%"
+	}
+}
@@ -0,0 +1,28 @@
+package sql
+
+import (
+	stdsql "database/sql"
+	"embed"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/migrate"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// Migrations returns the embedded schema migrations for the dialect.
+func Migrations(dialect Dialect) (fs.FS, error) {
+	return fs.Sub(migrationsFS, path.Join("migrations", string(dialect)))
+}
+
+func NewMigrator(db *stdsql.DB, dialect Dialect) (*migrate.Migrator, error) {
+	fsys, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(db, string(dialect), fsys, migrate.Options{LockTimeout: time.Minute})
+}
@@ -0,0 +1,3 @@
+DROP INDEX idx_todos_completed;
+
+DROP TABLE todos;
@@ -0,0 +1,10 @@
+CREATE TABLE todos (
+    id          BIGSERIAL PRIMARY KEY,
+    title       TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    completed   BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_todos_completed ON todos (completed);
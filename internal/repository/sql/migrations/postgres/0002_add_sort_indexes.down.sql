@@ -0,0 +1,2 @@
+DROP INDEX idx_todos_updated_at;
+DROP INDEX idx_todos_created_at;
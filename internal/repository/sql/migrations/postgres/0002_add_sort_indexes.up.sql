@@ -0,0 +1,2 @@
+CREATE INDEX idx_todos_created_at ON todos (created_at, id);
+CREATE INDEX idx_todos_updated_at ON todos (updated_at, id);
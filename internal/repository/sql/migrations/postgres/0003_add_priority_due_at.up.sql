@@ -0,0 +1,5 @@
+ALTER TABLE todos ADD COLUMN priority TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMPTZ;
+
+CREATE INDEX idx_todos_priority ON todos (priority, id);
+CREATE INDEX idx_todos_due_at ON todos (due_at, id);
@@ -0,0 +1,11 @@
+CREATE TABLE lists (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE todos ADD COLUMN list_id BIGINT REFERENCES lists (id);
+
+CREATE INDEX idx_todos_list_id ON todos (list_id, completed);
@@ -0,0 +1,4 @@
+DROP INDEX idx_todos_parent_id;
+
+ALTER TABLE todos DROP COLUMN auto_complete;
+ALTER TABLE todos DROP COLUMN parent_id;
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN previous_occurrence_id BIGINT;
+ALTER TABLE todos ADD COLUMN next_occurrence_id BIGINT;
@@ -0,0 +1,3 @@
+DROP INDEX idx_todos_deleted_at;
+
+ALTER TABLE todos DROP COLUMN deleted_at;
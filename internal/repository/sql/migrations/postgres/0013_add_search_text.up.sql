@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN search_text TEXT;
@@ -0,0 +1,10 @@
+CREATE TABLE todos (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    title       TEXT     NOT NULL,
+    description TEXT     NOT NULL DEFAULT '',
+    completed   BOOLEAN  NOT NULL DEFAULT FALSE,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE INDEX idx_todos_completed ON todos (completed);
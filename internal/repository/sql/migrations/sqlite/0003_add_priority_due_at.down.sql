@@ -0,0 +1,5 @@
+DROP INDEX idx_todos_due_at;
+DROP INDEX idx_todos_priority;
+
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN priority;
@@ -0,0 +1,5 @@
+DROP INDEX idx_todos_list_id;
+
+ALTER TABLE todos DROP COLUMN list_id;
+
+DROP TABLE lists;
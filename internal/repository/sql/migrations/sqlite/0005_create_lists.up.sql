@@ -0,0 +1,12 @@
+CREATE TABLE lists (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT     NOT NULL,
+    description TEXT     NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+-- no REFERENCES: sqlite cannot drop a column that is part of a foreign key
+ALTER TABLE todos ADD COLUMN list_id INTEGER;
+
+CREATE INDEX idx_todos_list_id ON todos (list_id, completed);
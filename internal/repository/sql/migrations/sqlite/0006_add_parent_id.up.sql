@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN parent_id INTEGER;
+ALTER TABLE todos ADD COLUMN auto_complete BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_todos_parent_id ON todos (parent_id, id);
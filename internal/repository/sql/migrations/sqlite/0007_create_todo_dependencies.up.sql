@@ -0,0 +1,7 @@
+CREATE TABLE todo_dependencies (
+    todo_id    INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+    blocker_id INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+    PRIMARY KEY (todo_id, blocker_id)
+);
+
+CREATE INDEX idx_todo_dependencies_blocker_id ON todo_dependencies (blocker_id, todo_id);
@@ -0,0 +1,4 @@
+ALTER TABLE todos DROP COLUMN next_occurrence_id;
+ALTER TABLE todos DROP COLUMN previous_occurrence_id;
+ALTER TABLE todos DROP COLUMN occurrence;
+ALTER TABLE todos DROP COLUMN recurrence;
@@ -0,0 +1,2 @@
+ALTER TABLE todos DROP COLUMN reminded_at;
+ALTER TABLE todos DROP COLUMN remind_at;
@@ -0,0 +1,2 @@
+ALTER TABLE todos ADD COLUMN remind_at DATETIME;
+ALTER TABLE todos ADD COLUMN reminded_at DATETIME;
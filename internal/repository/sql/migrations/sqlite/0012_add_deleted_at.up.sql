@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX idx_todos_deleted_at ON todos (deleted_at);
@@ -0,0 +1,35 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) MarkReminded(ctx context.Context, id int, remindAt, at time.Time) error {
+	res, err := r.q().ExecContext(ctx, r.dialect.rebind(`
+		UPDATE todos SET reminded_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND remind_at = ?`),
+		at.UTC(), id, remindAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	var exists bool
+	err = r.q().QueryRowContext(ctx, r.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL)`), id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrTodoNotFound
+	}
+	return domain.ErrReminderMoved
+}
@@ -0,0 +1,609 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+const todoColumns = "id, title, description, completed, priority, due_at, list_id, parent_id, auto_complete, recurrence, occurrence, previous_occurrence_id, next_occurrence_id, remind_at, reminded_at, version, created_at, updated_at, deleted_at"
+
+type TodoRepository struct {
+	db      *stdsql.DB
+	dialect Dialect
+	tx      *stdsql.Tx // set on the repository InTx hands out
+}
+
+func NewTodoRepository(db *stdsql.DB, dialect Dialect) *TodoRepository {
+	return &TodoRepository{db: db, dialect: dialect}
+}
+
+// OpenDB connects to databaseURL and checks the connection.
+func OpenDB(ctx context.Context, databaseURL string) (*stdsql.DB, Dialect, error) {
+	dialect, dsn, err := ParseURL(databaseURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	db, err := stdsql.Open(dialect.Driver(), dsn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if dialect == DialectSQLite {
+		// sqlite allows a single writer, queueing in the pool beats SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, "", fmt.Errorf("connect: %w", err)
+	}
+
+	return db, dialect, nil
+}
+
+// Open connects to databaseURL, applies pending migrations and returns a
+// repository that owns the connection pool.
+func Open(ctx context.Context, databaseURL string) (*TodoRepository, error) {
+	db, dialect, err := OpenDB(ctx, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := NewMigrator(db, dialect)
+	if err == nil {
+		_, err = migrator.Up(ctx, 0)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	repo := NewTodoRepository(db, dialect)
+	if err := repo.fillSearchText(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("fill search text: %w", err)
+	}
+	return repo, nil
+}
+
+// fillSearchText sets search_text on the todos written before it existed,
+// which searches would never find otherwise.
+func (r *TodoRepository) fillSearchText(ctx context.Context) error {
+	return r.inTx(ctx, func(tx *stdsql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id, title, description FROM todos WHERE search_text IS NULL`)
+		if err != nil {
+			return err
+		}
+		texts := map[int]string{}
+		for rows.Next() {
+			var (
+				id                 int
+				title, description string
+			)
+			if err := rows.Scan(&id, &title, &description); err != nil {
+				rows.Close()
+				return err
+			}
+			texts[id] = searchText(title, description)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for id, text := range texts {
+			if _, err := tx.ExecContext(ctx, r.dialect.rebind(`UPDATE todos SET search_text = ? WHERE id = ?`), text, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *TodoRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *TodoRepository) Create(ctx context.Context, input domain.CreateTodoInput) (*domain.Todo, error) {
+	now := time.Now().UTC()
+
+	var todo *domain.Todo
+	err := r.inTx(ctx, func(tx *stdsql.Tx) error {
+		if err := r.checkList(ctx, tx, input.ListID); err != nil {
+			return err
+		}
+		if err := r.checkParent(ctx, tx, 0, input.ParentID); err != nil {
+			return err
+		}
+
+		var id int
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`
+			INSERT INTO todos (title, description, search_text, completed, priority, due_at, list_id, parent_id, auto_complete, recurrence, occurrence, previous_occurrence_id, remind_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`),
+			input.Title, input.Description, searchText(input.Title, input.Description), input.Completed, input.Priority, utcOrNil(input.DueAt),
+			idOrNil(input.ListID), idOrNil(input.ParentID), input.AutoComplete,
+			input.Recurrence, input.Occurrence, idOrNil(input.PreviousOccurrenceID), utcOrNil(input.RemindAt), now, now).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		if err := r.setTags(ctx, tx, id, input.Tags); err != nil {
+			return err
+		}
+
+		todo, err = r.getByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (r *TodoRepository) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
+	return r.getByID(ctx, r.q(), id)
+}
+
+func (r *TodoRepository) getByID(ctx context.Context, q querier, id int) (*domain.Todo, error) {
+	row := q.QueryRowContext(ctx, r.dialect.rebind(r.selectTodos()+` WHERE id = ? AND deleted_at IS NULL`), id)
+	return scanTodo(row)
+}
+
+func (r *TodoRepository) GetAll(ctx context.Context) ([]domain.Todo, error) {
+	todos, err := r.query(ctx, r.selectTodos()+` WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	if todos == nil {
+		todos = []domain.Todo{}
+	}
+	return todos, nil
+}
+
+func (r *TodoRepository) Update(ctx context.Context, id int, input domain.UpdateTodoInput) (*domain.Todo, error) {
+	var todo *domain.Todo
+	err := r.inTx(ctx, func(tx *stdsql.Tx) error {
+		var listID interface{}
+		if input.ListID != nil {
+			if err := r.checkList(ctx, tx, *input.ListID); err != nil {
+				return err
+			}
+			listID = idOrNil(*input.ListID)
+		}
+		var parentID interface{}
+		if input.ParentID != nil {
+			if err := r.checkParent(ctx, tx, id, *input.ParentID); err != nil {
+				return err
+			}
+			parentID = idOrNil(*input.ParentID)
+		}
+		completing := input.Completed != nil && *input.Completed
+		if input.Completed != nil {
+			// completing a todo while one of its blockers is reopened could
+			// otherwise leave it completed but blocked
+			if err := r.lockGraph(ctx, tx, graphDependencies); err != nil {
+				return err
+			}
+		}
+
+		// nil pointers bind as NULL, so COALESCE keeps fields the input leaves out
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`
+			UPDATE todos SET
+				title = COALESCE(?, title),
+				description = COALESCE(?, description),
+				completed = COALESCE(?, completed),
+				priority = COALESCE(?, priority),
+				due_at = CASE WHEN ? THEN NULL ELSE COALESCE(?, due_at) END,
+				list_id = CASE WHEN ? THEN NULL ELSE COALESCE(?, list_id) END,
+				parent_id = CASE WHEN ? THEN NULL ELSE COALESCE(?, parent_id) END,
+				auto_complete = COALESCE(?, auto_complete),
+				recurrence = COALESCE(?, recurrence),
+				occurrence = COALESCE(?, occurrence),
+				next_occurrence_id = COALESCE(?, next_occurrence_id),
+				remind_at = CASE WHEN ? THEN NULL ELSE COALESCE(?, remind_at) END,
+				reminded_at = COALESCE(?, reminded_at),
+				version = version + 1,
+				updated_at = ?
+			WHERE id = ? AND deleted_at IS NULL AND version = COALESCE(?, version)
+				AND NOT (? AND NOT completed AND `+blocked("todos.id")+`)
+			RETURNING id`),
+			input.Title, input.Description, input.Completed, input.Priority,
+			input.ClearDueAt && input.DueAt == nil, utcOrNil(input.DueAt),
+			input.ListID != nil && *input.ListID == 0, listID,
+			input.ParentID != nil && *input.ParentID == 0, parentID,
+			input.AutoComplete, input.Recurrence, input.Occurrence, input.NextOccurrenceID,
+			input.ClearRemindAt && input.RemindAt == nil, utcOrNil(input.RemindAt), utcOrNil(input.RemindedAt),
+			time.Now().UTC(), id, input.Version, completing).Scan(&id)
+		if errors.Is(err, stdsql.ErrNoRows) {
+			if completing {
+				if err := r.checkUnblocked(ctx, tx, id); err != nil {
+					return err
+				}
+			}
+			return r.missing(ctx, tx, id, input.Version != nil)
+		}
+		if err != nil {
+			return err
+		}
+
+		if input.Tags != nil {
+			if _, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM todo_tags WHERE todo_id = ?`), id); err != nil {
+				return err
+			}
+			if err := r.setTags(ctx, tx, id, *input.Tags); err != nil {
+				return err
+			}
+		}
+
+		todo, err = r.getByID(ctx, tx, id)
+		if err != nil || (input.Title == nil && input.Description == nil) {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(`UPDATE todos SET search_text = ? WHERE id = ?`), searchText(todo.Title, todo.Description), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (r *TodoRepository) Delete(ctx context.Context, id int) error {
+	return r.delete(ctx, id, nil)
+}
+
+func (r *TodoRepository) DeleteVersion(ctx context.Context, id, version int) error {
+	if version <= 0 {
+		return domain.ErrInvalidVersion
+	}
+	return r.delete(ctx, id, &version)
+}
+
+// delete moves the todo to the trash, checking its version unless version
+// is nil. Its tags stay for a restore, its dependencies go.
+func (r *TodoRepository) delete(ctx context.Context, id int, version *int) error {
+	return r.inTx(ctx, func(tx *stdsql.Tx) error {
+		res, err := tx.ExecContext(ctx, r.dialect.rebind(`
+			UPDATE todos SET deleted_at = ?, version = version + 1
+			WHERE id = ? AND deleted_at IS NULL AND version = COALESCE(?, version)`), time.Now().UTC(), id, version)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return r.missing(ctx, tx, id, version != nil)
+		}
+
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM todo_dependencies WHERE todo_id = ? OR blocker_id = ?`), id, id); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// missing explains why a write matched no todo: it does not exist, or,
+// when the write was conditional, it is at another version.
+func (r *TodoRepository) missing(ctx context.Context, q querier, id int, versioned bool) error {
+	if !versioned {
+		return domain.ErrTodoNotFound
+	}
+	var exists int
+	err := q.QueryRowContext(ctx, r.dialect.rebind(`SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL`), id).Scan(&exists)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return domain.ErrTodoNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return domain.ErrVersionMismatch
+}
+
+// checkUnblocked fails with domain.ErrTodoBlocked when the open todo with id
+// has an open blocker.
+func (r *TodoRepository) checkUnblocked(ctx context.Context, q querier, id int) error {
+	var isBlocked bool
+	err := q.QueryRowContext(ctx, r.dialect.rebind(`SELECT NOT completed AND `+blocked("todos.id")+` FROM todos WHERE id = ? AND deleted_at IS NULL`), id).Scan(&isBlocked)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if isBlocked {
+		return domain.ErrTodoBlocked
+	}
+	return nil
+}
+
+func (r *TodoRepository) GetFiltered(ctx context.Context, completed *bool, search string) ([]domain.Todo, error) {
+	where, args, err := r.dialect.filterClause(domain.TodoFilter{Completed: completed, Search: search})
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.selectTodos()
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY id`
+
+	return r.query(ctx, query, args...)
+}
+
+func (r *TodoRepository) List(ctx context.Context, query domain.ListQuery) (*domain.TodoPage, error) {
+	where, args, err := r.dialect.filterClause(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.TodoPage{}
+	countQuery := `SELECT COUNT(*) FROM todos`
+	if len(where) > 0 {
+		countQuery += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if err := r.q().QueryRowContext(ctx, r.dialect.rebind(countQuery), args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	keys := query.Sort
+	if len(keys) == 0 {
+		keys = domain.DefaultSort
+	}
+
+	// relevance is not ranked in SQL, every row scores 0
+	columnKeys := withoutScore(keys)
+
+	if query.After != nil {
+		clause, keysetArgs := r.dialect.keysetClause(columnKeys, *query.After)
+		where = append(where, clause)
+		args = append(args, keysetArgs...)
+	}
+
+	selectQuery := r.selectTodos()
+	if len(where) > 0 {
+		selectQuery += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	selectQuery += ` ORDER BY ` + r.dialect.orderClause(columnKeys)
+	if query.Limit > 0 {
+		// one extra row tells whether another page follows
+		selectQuery += ` LIMIT ?`
+		args = append(args, query.Limit+1)
+	}
+
+	todos, err := r.query(ctx, selectQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Limit > 0 && len(todos) > query.Limit {
+		todos = todos[:query.Limit]
+		page.NextCursor = domain.CursorFor(todos[len(todos)-1], keys).Encode()
+	}
+	page.Items = todos
+	if page.Items == nil {
+		page.Items = []domain.Todo{}
+	}
+
+	return page, nil
+}
+
+func (r *TodoRepository) query(ctx context.Context, query string, args ...interface{}) ([]domain.Todo, error) {
+	rows, err := r.q().QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []domain.Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+
+	return todos, rows.Err()
+}
+
+// selectTodos selects todoColumns followed by the todo's tag names, its
+// blocker ids and whether it is blocked.
+func (r *TodoRepository) selectTodos() string {
+	return `SELECT ` + todoColumns + `, ` + r.dialect.tagNames("todos.id") + `, ` + r.dialect.blockerIDs("todos.id") + `, ` + blocked("todos.id") + ` FROM todos`
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (stdsql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*stdsql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *stdsql.Row
+}
+
+// q is where statements outside inTx run: the transaction of a repository
+// from InTx, the pool otherwise.
+func (r *TodoRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// InTx runs fn on a repository that runs every statement in one
+// transaction, committed when fn succeeds.
+func (r *TodoRepository) InTx(ctx context.Context, fn func(repo domain.TodoRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	return r.inTx(ctx, func(tx *stdsql.Tx) error {
+		return fn(&TodoRepository{db: r.db, dialect: r.dialect, tx: tx})
+	})
+}
+
+func (r *TodoRepository) inTx(ctx context.Context, fn func(tx *stdsql.Tx) error) error {
+	if r.tx != nil {
+		return r.savepoint(ctx, fn)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// savepoint runs fn within the open transaction, undoing what fn did if it
+// fails so the transaction stays usable.
+func (r *TodoRepository) savepoint(ctx context.Context, fn func(tx *stdsql.Tx) error) error {
+	if _, err := r.tx.ExecContext(ctx, `SAVEPOINT write`); err != nil {
+		return err
+	}
+	if err := fn(r.tx); err != nil {
+		r.tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT write`)
+		return err
+	}
+	_, err := r.tx.ExecContext(ctx, `RELEASE SAVEPOINT write`)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTodo(s scanner) (*domain.Todo, error) {
+	var (
+		todo     domain.Todo
+		priority string
+		dueAt    stdsql.NullTime
+		remindAt stdsql.NullTime
+		reminded stdsql.NullTime
+		deleted  stdsql.NullTime
+		listID   stdsql.NullInt64
+		parentID stdsql.NullInt64
+		prevID   stdsql.NullInt64
+		nextID   stdsql.NullInt64
+		tags     stdsql.NullString
+		blockers stdsql.NullString
+	)
+	err := s.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &priority, &dueAt, &listID, &parentID, &todo.AutoComplete, &todo.Recurrence, &todo.Occurrence, &prevID, &nextID, &remindAt, &reminded, &todo.Version, &todo.CreatedAt, &todo.UpdatedAt, &deleted, &tags, &blockers, &todo.Blocked)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, domain.ErrTodoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todo.Priority = domain.Priority(priority)
+	if dueAt.Valid {
+		todo.DueAt = &dueAt.Time
+	}
+	if remindAt.Valid {
+		todo.RemindAt = &remindAt.Time
+	}
+	if reminded.Valid {
+		todo.RemindedAt = &reminded.Time
+	}
+	if deleted.Valid {
+		todo.DeletedAt = &deleted.Time
+	}
+	todo.ListID = int(listID.Int64)
+	todo.ParentID = int(parentID.Int64)
+	todo.PreviousOccurrenceID = int(prevID.Int64)
+	todo.NextOccurrenceID = int(nextID.Int64)
+	if tags.Valid && tags.String != "" {
+		// tag names cannot contain commas
+		todo.Tags = strings.Split(tags.String, ",")
+		sort.Strings(todo.Tags)
+	}
+	if blockers.Valid && blockers.String != "" {
+		for _, s := range strings.Split(blockers.String, ",") {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("blocker id %q: %w", s, err)
+			}
+			todo.BlockedBy = append(todo.BlockedBy, id)
+		}
+		sort.Ints(todo.BlockedBy)
+	}
+	return &todo, nil
+}
+
+// checkParent fails unless parent is 0 or names an existing todo that is
+// not id or one of its subtasks; id is 0 for a todo yet to be created. It
+// must run in the transaction that sets the parent.
+func (r *TodoRepository) checkParent(ctx context.Context, q querier, id, parent int) error {
+	if parent == 0 {
+		return nil
+	}
+
+	var exists bool
+	err := q.QueryRowContext(ctx, r.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL)`), parent).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %d", domain.ErrUnknownParent, parent)
+	}
+	if id == 0 {
+		return nil
+	}
+
+	// two moves checked side by side could each close half of a cycle
+	if err := r.lockGraph(ctx, q, graphParents); err != nil {
+		return err
+	}
+	var cycle bool
+	err = q.QueryRowContext(ctx, r.dialect.rebind(`
+		WITH RECURSIVE ancestors (id, parent_id) AS (
+			SELECT id, parent_id FROM todos WHERE id = ?
+			UNION
+			SELECT todos.id, todos.parent_id FROM todos JOIN ancestors ON todos.id = ancestors.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`), parent, id).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return domain.ErrParentCycle
+	}
+	return nil
+}
+
+// lockGraph holds the lock of graph until the transaction q belongs to ends.
+func (r *TodoRepository) lockGraph(ctx context.Context, q querier, graph graphLock) error {
+	stmt := r.dialect.lockGraph(graph)
+	if stmt == "" {
+		return nil
+	}
+	_, err := q.ExecContext(ctx, stmt)
+	return err
+}
+
+func utcOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
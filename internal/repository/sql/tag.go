@@ -0,0 +1,157 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+const tagColumns = "id, name, color, created_at, updated_at"
+
+func (r *TodoRepository) CreateTag(ctx context.Context, input domain.CreateTagInput) (*domain.Tag, error) {
+	now := time.Now().UTC()
+
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(`
+		INSERT INTO tags (name, color, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING `+tagColumns),
+		input.Name, input.Color, now, now)
+
+	return scanTag(row)
+}
+
+func (r *TodoRepository) GetTag(ctx context.Context, id int) (*domain.Tag, error) {
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(`SELECT `+tagColumns+` FROM tags WHERE id = ?`), id)
+	return scanTag(row)
+}
+
+func (r *TodoRepository) ListTags(ctx context.Context) ([]domain.Tag, error) {
+	rows, err := r.q().QueryContext(ctx, `SELECT `+tagColumns+` FROM tags ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []domain.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// UpdateTag renames a tag in place; todos refer to it by id, so the new
+// name shows up on them without touching todo_tags.
+func (r *TodoRepository) UpdateTag(ctx context.Context, id int, input domain.UpdateTagInput) (*domain.Tag, error) {
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(`
+		UPDATE tags SET
+			name = COALESCE(?, name),
+			color = COALESCE(?, color),
+			updated_at = ?
+		WHERE id = ?
+		RETURNING `+tagColumns),
+		input.Name, input.Color, time.Now().UTC(), id)
+
+	return scanTag(row)
+}
+
+func (r *TodoRepository) DeleteTag(ctx context.Context, id int) error {
+	return r.inTx(ctx, func(tx *stdsql.Tx) error {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM todo_tags WHERE tag_id = ?`), id); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM tags WHERE id = ?`), id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return domain.ErrTagNotFound
+		}
+		return nil
+	})
+}
+
+// setTags attaches the tags named in names to a todo that has none.
+func (r *TodoRepository) setTags(ctx context.Context, q querier, todoID int, names []string) error {
+	names = uniqueStrings(names)
+	if len(names) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+
+	rows, err := q.QueryContext(ctx, r.dialect.rebind(`SELECT id, name FROM tags WHERE name IN (`+placeholders(len(names))+`)`), args...)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]int, len(names))
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		ids[name] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		id, ok := ids[name]
+		if !ok {
+			return fmt.Errorf("%w: %q", domain.ErrUnknownTag, name)
+		}
+		if _, err := q.ExecContext(ctx, r.dialect.rebind(`INSERT INTO todo_tags (todo_id, tag_id) VALUES (?, ?)`), todoID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanTag(s scanner) (*domain.Tag, error) {
+	var tag domain.Tag
+	err := s.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, domain.ErrTagNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, domain.ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// isUniqueViolation recognizes the unique constraint errors of both drivers
+// without importing them.
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || strings.Contains(msg, "duplicate key value violates unique constraint")
+}
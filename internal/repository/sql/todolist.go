@@ -0,0 +1,154 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// selectLists selects a list's columns followed by its open and completed
+// todo counts, binding false and true for the two counts.
+const selectLists = `SELECT id, name, description, created_at, updated_at,
+	(SELECT COUNT(*) FROM todos WHERE todos.list_id = lists.id AND todos.completed = ? AND todos.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM todos WHERE todos.list_id = lists.id AND todos.completed = ? AND todos.deleted_at IS NULL)
+	FROM lists`
+
+func (r *TodoRepository) CreateList(ctx context.Context, input domain.CreateListInput) (*domain.TodoList, error) {
+	now := time.Now().UTC()
+
+	var id int
+	err := r.q().QueryRowContext(ctx, r.dialect.rebind(`
+		INSERT INTO lists (name, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id`),
+		input.Name, input.Description, now, now).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetList(ctx, id)
+}
+
+func (r *TodoRepository) GetList(ctx context.Context, id int) (*domain.TodoList, error) {
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(selectLists+` WHERE id = ?`), false, true, id)
+	return scanList(row)
+}
+
+func (r *TodoRepository) GetLists(ctx context.Context) ([]domain.TodoList, error) {
+	rows, err := r.q().QueryContext(ctx, r.dialect.rebind(selectLists+` ORDER BY name, id`), false, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []domain.TodoList{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (r *TodoRepository) UpdateList(ctx context.Context, id int, input domain.UpdateListInput) (*domain.TodoList, error) {
+	err := r.q().QueryRowContext(ctx, r.dialect.rebind(`
+		UPDATE lists SET
+			name = COALESCE(?, name),
+			description = COALESCE(?, description),
+			updated_at = ?
+		WHERE id = ?
+		RETURNING id`),
+		input.Name, input.Description, time.Now().UTC(), id).Scan(&id)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, domain.ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetList(ctx, id)
+}
+
+func (r *TodoRepository) DeleteList(ctx context.Context, id int, cascade bool) error {
+	return r.inTx(ctx, func(tx *stdsql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM lists WHERE id = ?)`), id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return domain.ErrListNotFound
+		}
+
+		var count int
+		err = tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT COUNT(*) FROM todos WHERE list_id = ? AND deleted_at IS NULL`), id).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 && !cascade {
+			return domain.ErrListNotEmpty
+		}
+
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM todo_dependencies
+			WHERE todo_id IN (SELECT id FROM todos WHERE list_id = ? AND deleted_at IS NULL)
+			OR blocker_id IN (SELECT id FROM todos WHERE list_id = ? AND deleted_at IS NULL)`), id, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`
+			UPDATE todos SET deleted_at = ?, version = version + 1
+			WHERE list_id = ? AND deleted_at IS NULL`), time.Now().UTC(), id); err != nil {
+			return err
+		}
+		// a todo restored from the trash lands in no list
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`UPDATE todos SET list_id = NULL WHERE list_id = ?`), id); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM lists WHERE id = ?`), id)
+		return err
+	})
+}
+
+// checkList fails unless id is 0 or names an existing list.
+func (r *TodoRepository) checkList(ctx context.Context, q querier, id int) error {
+	if id == 0 {
+		return nil
+	}
+
+	var exists bool
+	err := q.QueryRowContext(ctx, r.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM lists WHERE id = ?)`), id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %d", domain.ErrUnknownList, id)
+	}
+	return nil
+}
+
+func scanList(s scanner) (*domain.TodoList, error) {
+	var list domain.TodoList
+	err := s.Scan(&list.ID, &list.Name, &list.Description, &list.CreatedAt, &list.UpdatedAt, &list.OpenCount, &list.CompletedCount)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, domain.ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// idOrNil maps the "none" id 0 of a list or parent to NULL.
+func idOrNil(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func (r *TodoRepository) ListTrash(ctx context.Context) ([]domain.Todo, error) {
+	return r.query(ctx, r.selectTodos()+` WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`)
+}
+
+func (r *TodoRepository) RestoreDeleted(ctx context.Context, id int) (*domain.Todo, error) {
+	var todo *domain.Todo
+	err := r.inTx(ctx, func(tx *stdsql.Tx) error {
+		var parentID stdsql.NullInt64
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT parent_id FROM todos WHERE id = ? AND deleted_at IS NOT NULL`), id).Scan(&parentID)
+		if errors.Is(err, stdsql.ErrNoRows) {
+			return domain.ErrTodoNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if parentID.Valid {
+			var parentDeleted stdsql.NullTime
+			err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT deleted_at FROM todos WHERE id = ?`), parentID.Int64).Scan(&parentDeleted)
+			switch {
+			case errors.Is(err, stdsql.ErrNoRows):
+				// the parent was purged
+				parentID.Valid = false
+			case err != nil:
+				return err
+			case parentDeleted.Valid:
+				return domain.ErrParentDeleted
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(`
+			UPDATE todos SET deleted_at = NULL, parent_id = ?, version = version + 1, updated_at = ?
+			WHERE id = ?`), parentID, time.Now().UTC(), id)
+		if err != nil {
+			return err
+		}
+
+		todo, err = r.getByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (r *TodoRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	var purged int64
+	err := r.inTx(ctx, func(tx *stdsql.Tx) error {
+		// sqlite leaves foreign keys off by default, so no cascade to rely on
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`
+			DELETE FROM todo_tags WHERE todo_id IN (SELECT id FROM todos WHERE deleted_at < ?)`), before.UTC()); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM todos WHERE deleted_at < ?`), before.UTC())
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(purged), nil
+}
@@ -0,0 +1,118 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+const webhookColumns = "id, url, secret, events, active, created_at, updated_at"
+
+func (r *TodoRepository) CreateWebhook(ctx context.Context, input domain.CreateWebhookInput) (*domain.Webhook, error) {
+	now := time.Now().UTC()
+	active := input.Active == nil || *input.Active
+
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(`
+		INSERT INTO webhooks (url, secret, events, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+webhookColumns),
+		input.URL, input.Secret, joinEvents(input.Events), active, now, now)
+
+	return scanWebhook(row)
+}
+
+func (r *TodoRepository) GetWebhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`), id)
+	return scanWebhook(row)
+}
+
+func (r *TodoRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := r.q().QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []domain.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+func (r *TodoRepository) UpdateWebhook(ctx context.Context, id int, input domain.UpdateWebhookInput) (*domain.Webhook, error) {
+	var events *string
+	if input.Events != nil {
+		joined := joinEvents(*input.Events)
+		events = &joined
+	}
+
+	row := r.q().QueryRowContext(ctx, r.dialect.rebind(`
+		UPDATE webhooks SET
+			url = COALESCE(?, url),
+			secret = COALESCE(?, secret),
+			events = COALESCE(?, events),
+			active = COALESCE(?, active),
+			updated_at = ?
+		WHERE id = ?
+		RETURNING `+webhookColumns),
+		input.URL, input.Secret, events, input.Active, time.Now().UTC(), id)
+
+	return scanWebhook(row)
+}
+
+func (r *TodoRepository) DeleteWebhook(ctx context.Context, id int) error {
+	res, err := r.q().ExecContext(ctx, r.dialect.rebind(`DELETE FROM webhooks WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// event types cannot contain commas, so they are stored as one string
+func joinEvents(events []domain.EventType) string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = string(e)
+	}
+	return strings.Join(names, ",")
+}
+
+func scanWebhook(s scanner) (*domain.Webhook, error) {
+	var (
+		hook   domain.Webhook
+		events string
+	)
+	err := s.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &hook.Active, &hook.CreatedAt, &hook.UpdatedAt)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hook.Events = []domain.EventType{}
+	if events != "" {
+		for _, e := range strings.Split(events, ",") {
+			hook.Events = append(hook.Events, domain.EventType(e))
+		}
+	}
+	return &hook, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// batchStep is one operation of a batch, run on the service it is given:
+// s itself, or one bound to the transaction of an atomic batch.
+type batchStep struct {
+	id  int
+	run func(s *TodoService) (*domain.Todo, error)
+}
+
+// Batch applies ops in order. An atomic batch is applied in one repository
+// transaction and stops at the first failed operation, which is returned as
+// a *domain.BatchError; otherwise every operation gets its own result.
+func (s *TodoService) Batch(ctx context.Context, ops []domain.BatchOp, atomic bool) ([]domain.BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, domain.ErrEmptyBatch
+	}
+	if len(ops) > domain.MaxBatchSize {
+		return nil, domain.ErrBatchTooLarge
+	}
+
+	steps := make([]batchStep, len(ops))
+	for i, op := range ops {
+		steps[i] = batchStep{id: op.ID, run: func(s *TodoService) (*domain.Todo, error) {
+			return s.applyBatchOp(ctx, op)
+		}}
+	}
+	return s.runBatch(ctx, atomic, func(*TodoService) ([]batchStep, error) { return steps, nil })
+}
+
+func (s *TodoService) applyBatchOp(ctx context.Context, op domain.BatchOp) (*domain.Todo, error) {
+	switch op.Op {
+	case domain.BatchCreate:
+		return s.Create(ctx, op.Input)
+	case domain.BatchUpdate:
+		if op.Patch == nil {
+			return nil, domain.ErrInvalidBatchOp
+		}
+		return s.Patch(ctx, op.ID, op.Patch, op.Version)
+	case domain.BatchDelete:
+		return nil, s.DeleteVersion(ctx, op.ID, op.Children, op.Version)
+	default:
+		return nil, domain.ErrInvalidBatchOp
+	}
+}
+
+// CompleteMatching completes every open todo that matches filter, atomically
+// or each on its own like Batch.
+func (s *TodoService) CompleteMatching(ctx context.Context, filter domain.TodoFilter, atomic bool) ([]domain.BatchResult, error) {
+	open := false
+	filter.Completed = &open
+
+	return s.runBatch(ctx, atomic, func(s *TodoService) ([]batchStep, error) {
+		todos, err := s.matching(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		steps := make([]batchStep, len(todos))
+		for i, todo := range todos {
+			steps[i] = batchStep{id: todo.ID, run: func(s *TodoService) (*domain.Todo, error) {
+				done := true
+				return s.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+			}}
+		}
+		return steps, nil
+	})
+}
+
+// DeleteCompleted deletes every completed todo that matches filter, with
+// their subtasks handled as children says.
+func (s *TodoService) DeleteCompleted(ctx context.Context, filter domain.TodoFilter, children domain.ChildPolicy, atomic bool) ([]domain.BatchResult, error) {
+	done := true
+	filter.Completed = &done
+
+	return s.runBatch(ctx, atomic, func(s *TodoService) ([]batchStep, error) {
+		todos, err := s.matching(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		steps := make([]batchStep, len(todos))
+		for i, todo := range todos {
+			steps[i] = batchStep{id: todo.ID, run: func(s *TodoService) (*domain.Todo, error) {
+				err := s.Delete(ctx, todo.ID, children)
+				if errors.Is(err, domain.ErrTodoNotFound) {
+					// deleted with a parent earlier in the batch
+					err = nil
+				}
+				return nil, err
+			}}
+		}
+		return steps, nil
+	})
+}
+
+// matching returns every todo that matches filter, oldest first. Like a
+// batch, it fails with domain.ErrBatchTooLarge past domain.MaxBatchSize.
+func (s *TodoService) matching(ctx context.Context, filter domain.TodoFilter) ([]domain.Todo, error) {
+	var todos []domain.Todo
+	page := domain.PageRequest{Limit: domain.MaxPageLimit}
+	for {
+		result, err := s.List(ctx, filter, string(domain.SortByID), page)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, result.Items...)
+		if len(todos) > domain.MaxBatchSize {
+			return nil, domain.ErrBatchTooLarge
+		}
+		if result.NextCursor == "" {
+			return todos, nil
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+// runBatch runs the steps plan returns. In an atomic batch plan and the
+// steps run in a transaction that the first failed step rolls back.
+func (s *TodoService) runBatch(ctx context.Context, atomic bool, plan func(s *TodoService) ([]batchStep, error)) ([]domain.BatchResult, error) {
+	run := func(s *TodoService) ([]domain.BatchResult, error) {
+		steps, err := plan(s)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]domain.BatchResult, len(steps))
+		for i, step := range steps {
+			todo, err := step.run(s)
+			if err != nil && atomic {
+				return nil, &domain.BatchError{Index: i, ID: step.id, Err: err}
+			}
+			results[i] = domain.BatchResult{ID: step.id, Todo: todo, Err: err}
+			if todo != nil {
+				results[i].ID = todo.ID
+			}
+		}
+		return results, nil
+	}
+
+	if !atomic {
+		return run(s)
+	}
+	var results []domain.BatchResult
+	err := s.inTx(ctx, func(tx *TodoService) error {
+		var err error
+		results, err = run(tx)
+		return err
+	})
+	return results, err
+}
+
+// inTx runs fn with a service bound to a transaction of the repository.
+// Reminders and events are held back until the transaction commits, and
+// dropped when it does not.
+func (s *TodoService) inTx(ctx context.Context, fn func(tx *TodoService) error) error {
+	txr, ok := s.repo.(domain.Transactor)
+	if !ok {
+		return domain.ErrTransactionsUnsupported
+	}
+
+	var held *heldEffects
+	err := txr.InTx(ctx, func(repo domain.TodoRepository) error {
+		held = &heldEffects{}
+		tx := NewTodoService(repo)
+		if s.reminders != nil {
+			tx.reminders = held
+		}
+		if len(s.sinks) > 0 {
+			tx.sinks = []EventSink{held}
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, effect := range held.effects {
+		effect(s)
+	}
+	return nil
+}
+
+// heldEffects records the reminder changes and events of a transaction, in
+// order, to replay on the service once it commits.
+type heldEffects struct {
+	effects []func(s *TodoService)
+}
+
+func (h *heldEffects) Schedule(todo domain.Todo) {
+	h.effects = append(h.effects, func(s *TodoService) { s.reminders.Schedule(todo) })
+}
+
+func (h *heldEffects) Cancel(id int) {
+	h.effects = append(h.effects, func(s *TodoService) { s.reminders.Cancel(id) })
+}
+
+func (h *heldEffects) Publish(event domain.TodoEvent) {
+	h.effects = append(h.effects, func(s *TodoService) {
+		for _, sink := range s.sinks {
+			sink.Publish(event)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+var errNoDependencies = errors.New("storage does not support dependencies")
+
+// nextSort orders todos that are ready at the same time in Next.
+var nextSort = []domain.SortKey{
+	{Field: domain.SortByPriority, Desc: true},
+	{Field: domain.SortByDueAt},
+	{Field: domain.SortByID},
+}
+
+// AddBlocker marks the todo with id as blocked by blockerID and returns it.
+func (s *TodoService) AddBlocker(ctx context.Context, id, blockerID int) (*domain.Todo, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	if err := validateID(blockerID); err != nil {
+		return nil, err
+	}
+	if s.deps == nil {
+		return nil, errNoDependencies
+	}
+	if id == blockerID {
+		return nil, domain.ErrDependencyCycle
+	}
+
+	// the repository rejects cycles, checked with the edge added atomically
+	if err := s.deps.AddDependency(ctx, domain.Dependency{TodoID: id, BlockerID: blockerID}); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *TodoService) RemoveBlocker(ctx context.Context, id, blockerID int) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	if err := validateID(blockerID); err != nil {
+		return err
+	}
+	if s.deps == nil {
+		return errNoDependencies
+	}
+	return s.deps.RemoveDependency(ctx, domain.Dependency{TodoID: id, BlockerID: blockerID})
+}
+
+// Blockers returns the todos the todo with id waits for, open or not.
+func (s *TodoService) Blockers(ctx context.Context, id int) ([]domain.Todo, error) {
+	todo, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	blockers := make([]domain.Todo, 0, len(todo.BlockedBy))
+	for _, blockerID := range todo.BlockedBy {
+		blocker, err := s.repo.GetByID(ctx, blockerID)
+		if errors.Is(err, domain.ErrTodoNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		blockers = append(blockers, *blocker)
+	}
+	return blockers, nil
+}
+
+// Next lists the open todos in an order they can be done in: every todo
+// comes after its open blockers, and among todos that are ready at the same
+// point the most urgent and soonest due go first. The first item is never
+// blocked.
+func (s *TodoService) Next(ctx context.Context, limit int) (*domain.TodoPage, error) {
+	if limit == 0 {
+		limit = domain.DefaultPageLimit
+	}
+	if limit < 0 || limit > domain.MaxPageLimit {
+		return nil, domain.ErrInvalidLimit
+	}
+	if s.deps == nil {
+		return nil, errNoDependencies
+	}
+
+	open := false
+	page, err := s.repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{Completed: &open}})
+	if err != nil {
+		return nil, err
+	}
+	edges, err := s.deps.ListDependencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := topoSort(page.Items, edges)
+	next := &domain.TodoPage{Items: ordered, Total: len(ordered)}
+	if len(ordered) > limit {
+		next.Items = ordered[:limit]
+	}
+	return next, nil
+}
+
+// topoSort orders todos with Kahn's algorithm, considering only edges
+// between the given todos. Todos caught in a cycle come last.
+func topoSort(todos []domain.Todo, edges []domain.Dependency) []domain.Todo {
+	byID := make(map[int]domain.Todo, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+	}
+
+	waiting := make(map[int]int, len(todos))
+	unblocks := make(map[int][]int)
+	for _, e := range edges {
+		_, okTodo := byID[e.TodoID]
+		_, okBlocker := byID[e.BlockerID]
+		if okTodo && okBlocker {
+			waiting[e.TodoID]++
+			unblocks[e.BlockerID] = append(unblocks[e.BlockerID], e.TodoID)
+		}
+	}
+
+	var ready []domain.Todo
+	for _, todo := range todos {
+		if waiting[todo.ID] == 0 {
+			ready = append(ready, todo)
+		}
+	}
+
+	less := func(a, b domain.Todo) bool {
+		return domain.CompareTodos(a, b, nextSort) < 0
+	}
+
+	ordered := make([]domain.Todo, 0, len(todos))
+	done := make(map[int]bool, len(todos))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return less(ready[i], ready[j]) })
+		todo := ready[0]
+		ready = ready[1:]
+
+		ordered = append(ordered, todo)
+		done[todo.ID] = true
+		for _, id := range unblocks[todo.ID] {
+			waiting[id]--
+			if waiting[id] == 0 {
+				ready = append(ready, byID[id])
+			}
+		}
+	}
+
+	if len(ordered) < len(todos) {
+		var rest []domain.Todo
+		for _, todo := range todos {
+			if !done[todo.ID] {
+				rest = append(rest, todo)
+			}
+		}
+		sort.Slice(rest, func(i, j int) bool { return less(rest[i], rest[j]) })
+		ordered = append(ordered, rest...)
+	}
+	return ordered
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// EventSink receives the events TodoService publishes. Publish is called on
+// the request path after the change is stored, so it must not block.
+type EventSink interface {
+	Publish(event domain.TodoEvent)
+}
+
+// AddEventSink makes the service publish todo events to sink as well.
+func (s *TodoService) AddEventSink(sink EventSink) {
+	s.sinks = append(s.sinks, sink)
+}
+
+// publish sends an event about todo; before is only given for updates.
+func (s *TodoService) publish(typ domain.EventType, before, todo *domain.Todo) {
+	if len(s.sinks) == 0 {
+		return
+	}
+
+	event := domain.TodoEvent{Type: typ, Todo: *todo, Before: before, OccurredAt: time.Now().UTC()}
+	for _, sink := range s.sinks {
+		sink.Publish(event)
+	}
+}
+
+// publishUpdate publishes todo.updated, followed by todo.completed when the
+// update completed an open todo.
+func (s *TodoService) publishUpdate(before, after *domain.Todo) {
+	s.publish(domain.EventTodoUpdated, before, after)
+	if after.Completed && !before.Completed {
+		s.publish(domain.EventTodoCompleted, before, after)
+	}
+}
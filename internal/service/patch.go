@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// patchAttempts bounds how often Patch, or Update completing a todo, starts
+// over when the todo changes meanwhile.
+const patchAttempts = 3
+
+// Replace sets every writable field of the todo to input's, validated like
+// Update.
+func (s *TodoService) Replace(ctx context.Context, id int, input domain.ReplaceTodoInput) (*domain.Todo, error) {
+	return s.Update(ctx, id, input.UpdateInput())
+}
+
+// Patch applies patch to the todo's JSON representation and replaces the
+// todo with the result. The patch may test read-only fields but not change
+// them. A version in the result, or a non-zero version, makes the change
+// conditional like Update's; otherwise a change to the todo made while
+// patching starts the patch over on the new state.
+func (s *TodoService) Patch(ctx context.Context, id int, patch domain.TodoPatch, version int) (*domain.Todo, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	if version < 0 {
+		return nil, domain.ErrInvalidVersion
+	}
+
+	for attempt := 1; ; attempt++ {
+		current, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && current.Version != version {
+			return nil, domain.ErrVersionMismatch
+		}
+
+		input, err := applyPatch(current, patch)
+		if err != nil {
+			return nil, err
+		}
+		if input.Version != nil && *input.Version != current.Version {
+			return nil, domain.ErrVersionMismatch
+		}
+		input.Version = &current.Version
+
+		todo, err := s.Replace(ctx, id, input)
+		if errors.Is(err, domain.ErrVersionMismatch) && attempt < patchAttempts {
+			continue
+		}
+		return todo, err
+	}
+}
+
+// applyPatch runs patch on the representation of current, with every
+// writable field present, and reads the writable fields of the result.
+func applyPatch(current *domain.Todo, patch domain.TodoPatch) (domain.ReplaceTodoInput, error) {
+	var input domain.ReplaceTodoInput
+
+	doc, err := todoDocument(current)
+	if err != nil {
+		return input, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return input, err
+	}
+
+	patched, err := patch(data)
+	if err != nil {
+		return input, err
+	}
+	var result map[string]interface{}
+	if err := decodeNumbers(patched, &result); err != nil || result == nil {
+		return input, fmt.Errorf("%w: the patched todo must be an object", domain.ErrInvalidPatch)
+	}
+
+	for _, field := range domain.ReadOnlyTodoFields {
+		if !reflect.DeepEqual(doc[field], result[field]) {
+			return input, fmt.Errorf("%w: %s", domain.ErrReadOnlyField, field)
+		}
+		delete(result, field)
+	}
+
+	if data, err = json.Marshal(result); err != nil {
+		return input, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&input); err != nil {
+		return input, fmt.Errorf("%w: %v", domain.ErrInvalidPatch, err)
+	}
+	return input, nil
+}
+
+// todoDocument is the JSON representation of todo as a map, with the
+// writable fields it leaves out when empty filled in, so patches can rely
+// on their paths.
+func todoDocument(todo *domain.Todo) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	for _, v := range []interface{}{todo, todo.ReplaceInput()} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeNumbers(data, &doc); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// decodeNumbers unmarshals data keeping numbers as json.Number, so they
+// compare as written.
+func decodeNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
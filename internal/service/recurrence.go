@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// normalizeRecurrence validates rule and returns its canonical form; an
+// empty rule stays empty.
+func normalizeRecurrence(rule string) (string, error) {
+	if rule == "" {
+		return "", nil
+	}
+	r, err := domain.ParseRecurrence(rule)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// nextOccurrence creates the occurrence that follows the completed todo and
+// links the two. It returns todo unchanged when the rule has run out.
+func (s *TodoService) nextOccurrence(ctx context.Context, todo *domain.Todo) (*domain.Todo, error) {
+	if todo.DueAt == nil {
+		return todo, nil
+	}
+	rule, err := domain.ParseRecurrence(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrence := max(todo.Occurrence, 1)
+	due, ok := rule.Next(*todo.DueAt, occurrence)
+	if !ok {
+		return todo, nil
+	}
+
+	// the reminder keeps its distance from the due date
+	var remindAt *time.Time
+	if todo.RemindAt != nil {
+		at := due.Add(todo.RemindAt.Sub(*todo.DueAt))
+		remindAt = &at
+	}
+
+	next, err := s.repo.Create(ctx, domain.CreateTodoInput{
+		Title:        todo.Title,
+		Description:  todo.Description,
+		Priority:     todo.Priority,
+		DueAt:        &due,
+		Tags:         todo.Tags,
+		ListID:       todo.ListID,
+		ParentID:     todo.ParentID,
+		AutoComplete: todo.AutoComplete,
+		Recurrence:   todo.Recurrence,
+		RemindAt:     remindAt,
+
+		Occurrence:           occurrence + 1,
+		PreviousOccurrenceID: todo.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.scheduleReminder(next)
+	s.publish(domain.EventTodoCreated, nil, next)
+
+	return s.repo.Update(ctx, todo.ID, domain.UpdateTodoInput{NextOccurrenceID: &next.ID})
+}
+
+// checkRecurrence makes sure a todo that stays or becomes recurring keeps a
+// due date to count from.
+func checkRecurrence(current *domain.Todo, input domain.UpdateTodoInput) error {
+	rule := current.Recurrence
+	if input.Recurrence != nil {
+		rule = *input.Recurrence
+	}
+	if rule == "" {
+		return nil
+	}
+
+	hasDueAt := input.DueAt != nil || (current.DueAt != nil && !input.ClearDueAt)
+	if !hasDueAt {
+		return domain.ErrRecurrenceNeedsDueAt
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// Reminders is told about todos whose reminder may have changed, so it can
+// send them on time.
+type Reminders interface {
+	Schedule(todo domain.Todo)
+	Cancel(id int)
+}
+
+// SetReminders makes the service keep r up to date as todos change.
+func (s *TodoService) SetReminders(r Reminders) {
+	s.reminders = r
+}
+
+func (s *TodoService) scheduleReminder(todo *domain.Todo) {
+	if s.reminders != nil {
+		s.reminders.Schedule(*todo)
+	}
+}
+
+func (s *TodoService) cancelReminder(id int) {
+	if s.reminders != nil {
+		s.reminders.Cancel(id)
+	}
+}
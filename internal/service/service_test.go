@@ -1,8 +1,14 @@
 package service_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/yokitheyo/todo/internal/domain"
 	"github.com/yokitheyo/todo/internal/repository/memory"
@@ -92,7 +98,7 @@ func TestDeleteTodo(t *testing.T) {
 		Title: "To delete",
 	})
 
-	err := svc.Delete(context.Background(), todo.ID)
+	err := svc.Delete(context.Background(), todo.ID, "")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -103,6 +109,112 @@ func TestDeleteTodo(t *testing.T) {
 	}
 }
 
+func TestVersions_StaleWritesFail(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+
+	parent, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Parent"})
+	child, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Child", ParentID: parent.ID})
+
+	zero := 0
+	if _, err := svc.Update(ctx, parent.ID, domain.UpdateTodoInput{Version: &zero}); !errors.Is(err, domain.ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+
+	title := "Renamed"
+	updated, err := svc.Update(ctx, parent.ID, domain.UpdateTodoInput{Title: &title, Version: &parent.Version})
+	if err != nil {
+		t.Fatalf("expected the update at the current version to apply, got %v", err)
+	}
+	if updated.Version != parent.Version+1 {
+		t.Errorf("expected version %d, got %d", parent.Version+1, updated.Version)
+	}
+	if _, err := svc.Update(ctx, parent.ID, domain.UpdateTodoInput{Title: &title, Version: &parent.Version}); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for a stale update, got %v", err)
+	}
+
+	// a stale delete must not touch the subtasks first
+	if err := svc.DeleteVersion(ctx, parent.ID, domain.ChildrenCascade, parent.Version); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale delete, got %v", err)
+	}
+	if _, err := svc.GetByID(ctx, child.ID); err != nil {
+		t.Fatalf("expected the subtask to survive a stale delete, got %v", err)
+	}
+	if err := svc.DeleteVersion(ctx, parent.ID, domain.ChildrenCascade, updated.Version); err != nil {
+		t.Fatalf("expected the delete at the current version to apply, got %v", err)
+	}
+	if _, err := svc.GetByID(ctx, child.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected the subtask deleted with its parent, got %v", err)
+	}
+}
+
+// racingRepo changes a todo right before deleting it, as a concurrent
+// request could between the service's checks and the delete.
+type racingRepo struct {
+	*memory.TodoRepository
+}
+
+func (r racingRepo) InTx(ctx context.Context, fn func(repo domain.TodoRepository) error) error {
+	return r.TodoRepository.InTx(ctx, func(repo domain.TodoRepository) error {
+		return fn(racingRepo{repo.(*memory.TodoRepository)})
+	})
+}
+
+func (r racingRepo) DeleteVersion(ctx context.Context, id, version int) error {
+	title := "Changed meanwhile"
+	if _, err := r.TodoRepository.Update(ctx, id, domain.UpdateTodoInput{Title: &title}); err != nil {
+		return err
+	}
+	return r.TodoRepository.DeleteVersion(ctx, id, version)
+}
+
+func TestVersions_StaleDeleteKeepsSubtasks(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	svc := service.NewTodoService(racingRepo{repo})
+	ctx := context.Background()
+
+	parent, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Parent"})
+	child, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Child", ParentID: parent.ID})
+
+	if err := svc.DeleteVersion(ctx, parent.ID, domain.ChildrenCascade, parent.Version); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, child.ID); err != nil {
+		t.Errorf("expected the failed delete to keep the subtask, got %v", err)
+	}
+}
+
+func TestPatch_StartsOverOnConcurrentChange(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Report", Description: "Q1"})
+
+	calls := 0
+	patch := func(doc []byte) ([]byte, error) {
+		calls++
+		if calls == 1 {
+			// another client gets in between reading and writing
+			other := "Q2"
+			if _, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Description: &other}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return bytes.Replace(doc, []byte(`"title":"Report"`), []byte(`"title":"Quarterly report"`), 1), nil
+	}
+
+	patched, err := svc.Patch(ctx, todo.ID, patch, 0)
+	if err != nil {
+		t.Fatalf("Patch failed: %v", err)
+	}
+	if calls != 2 || patched.Title != "Quarterly report" || patched.Description != "Q2" {
+		t.Errorf("expected the patch redone on top of the other change, got %d calls and %+v", calls, patched)
+	}
+
+	if _, err := svc.Patch(ctx, todo.ID, patch, todo.Version); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for a stale version, got %v", err)
+	}
+}
+
 func TestUpdateTodo_InvalidID(t *testing.T) {
 	svc, _ := setupService()
 	newTitle := "Updated"
@@ -127,3 +239,897 @@ func TestCreateTodo_TitleTooLong(t *testing.T) {
 		t.Errorf("expected ErrTitleTooLong, got %v", err)
 	}
 }
+
+func TestList_DefaultsAndValidation(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	for i := 0; i < domain.DefaultPageLimit+1; i++ {
+		_, _ = svc.Create(ctx, domain.CreateTodoInput{Title: "Task"})
+	}
+
+	page, err := svc.List(ctx, domain.TodoFilter{}, "", domain.PageRequest{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page.Items) != domain.DefaultPageLimit || page.NextCursor == "" {
+		t.Errorf("expected a full default page with a cursor, got %d items", len(page.Items))
+	}
+
+	next, err := svc.List(ctx, domain.TodoFilter{}, "", domain.PageRequest{Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(next.Items) != 1 || next.NextCursor != "" {
+		t.Errorf("expected last page with 1 item, got %d items, cursor %q", len(next.Items), next.NextCursor)
+	}
+
+	if _, err := svc.List(ctx, domain.TodoFilter{}, "", domain.PageRequest{Limit: domain.MaxPageLimit + 1}); err != domain.ErrInvalidLimit {
+		t.Errorf("expected ErrInvalidLimit, got %v", err)
+	}
+	if _, err := svc.List(ctx, domain.TodoFilter{}, "", domain.PageRequest{Cursor: "%%%"}); err != domain.ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestList_Sort(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	for _, title := range []string{"b", "c", "a"} {
+		_, _ = svc.Create(ctx, domain.CreateTodoInput{Title: title})
+	}
+
+	page, err := svc.List(ctx, domain.TodoFilter{}, "-title", domain.PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if page.Items[0].Title != "c" || page.Items[1].Title != "b" {
+		t.Errorf("expected c, b; got %q, %q", page.Items[0].Title, page.Items[1].Title)
+	}
+
+	if _, err := svc.List(ctx, domain.TodoFilter{}, "title", domain.PageRequest{Cursor: page.NextCursor}); err != domain.ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor for a cursor from another sort, got %v", err)
+	}
+	if _, err := svc.List(ctx, domain.TodoFilter{}, "size", domain.PageRequest{}); !errors.Is(err, domain.ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort, got %v", err)
+	}
+}
+
+func TestList_SearchSortsByScore(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	_, _ = svc.Create(ctx, domain.CreateTodoInput{Title: "Shopping", Description: "milk, eggs and bread for the week"})
+	milk, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Milk"})
+
+	page, err := svc.List(ctx, domain.TodoFilter{Search: " milk "}, "", domain.PageRequest{Limit: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if page.Items[0].ID != milk.ID {
+		t.Errorf("expected the best match first, got %+v", page.Items[0])
+	}
+
+	next, err := svc.List(ctx, domain.TodoFilter{Search: "milk"}, "", domain.PageRequest{Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("expected the cursor to work with the default search sort, got %v", err)
+	}
+	if len(next.Items) != 1 || next.Items[0].ID == milk.ID {
+		t.Errorf("expected the other match on the second page, got %+v", next.Items)
+	}
+
+	if _, err := svc.List(ctx, domain.TodoFilter{}, "-score", domain.PageRequest{}); !errors.Is(err, domain.ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort for score without search, got %v", err)
+	}
+}
+
+func TestPriorityAndDueAtValidation(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+
+	todo, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Task"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if todo.Priority != domain.PriorityNone || todo.DueAt != nil {
+		t.Errorf("expected no priority and no due date by default, got %+v", todo)
+	}
+
+	bad := domain.Priority("critical")
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Task", Priority: bad}); err != domain.ErrInvalidPriority {
+		t.Errorf("expected ErrInvalidPriority, got %v", err)
+	}
+	if _, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Priority: &bad}); err != domain.ErrInvalidPriority {
+		t.Errorf("expected ErrInvalidPriority on update, got %v", err)
+	}
+
+	ancient := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Task", DueAt: &ancient}); err != domain.ErrInvalidDueAt {
+		t.Errorf("expected ErrInvalidDueAt, got %v", err)
+	}
+
+	due := time.Date(2030, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	if _, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{DueAt: &due, ClearDueAt: true}); err != domain.ErrDueAtConflict {
+		t.Errorf("expected ErrDueAtConflict, got %v", err)
+	}
+
+	updated, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{DueAt: &due})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if updated.DueAt.Location() != time.UTC || !updated.DueAt.Equal(due) {
+		t.Errorf("expected due date stored in UTC, got %v", updated.DueAt)
+	}
+
+	cleared, _ := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{ClearDueAt: true})
+	if cleared.DueAt != nil {
+		t.Errorf("expected due date cleared, got %v", cleared.DueAt)
+	}
+}
+
+func TestTags_NormalizedOnTodos(t *testing.T) {
+	svc, repo := setupService()
+	tags := service.NewTagService(repo)
+	ctx := context.Background()
+
+	if _, err := tags.Create(ctx, domain.CreateTagInput{Name: "Work"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := tags.Create(ctx, domain.CreateTagInput{Name: "home", Color: "red"}); !errors.Is(err, domain.ErrInvalidTagColor) {
+		t.Errorf("expected ErrInvalidTagColor, got %v", err)
+	}
+
+	todo, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Task", Tags: []string{" WORK", "work"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(todo.Tags) != 1 || todo.Tags[0] != "work" {
+		t.Errorf("expected tags normalized to [work], got %v", todo.Tags)
+	}
+
+	if _, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Tags: &[]string{"bad tag"}}); !errors.Is(err, domain.ErrInvalidTagName) {
+		t.Errorf("expected ErrInvalidTagName, got %v", err)
+	}
+
+	page, err := svc.List(ctx, domain.TodoFilter{Tags: []string{"Work"}}, "", domain.PageRequest{})
+	if err != nil || page.Total != 1 {
+		t.Errorf("expected the tag filter to be normalized too, got %v, %v", page, err)
+	}
+}
+
+func TestLists_Validation(t *testing.T) {
+	svc, repo := setupService()
+	lists := service.NewListService(repo)
+	ctx := context.Background()
+
+	if _, err := lists.Create(ctx, domain.CreateListInput{Name: "   "}); !errors.Is(err, domain.ErrListNameRequired) {
+		t.Errorf("expected ErrListNameRequired, got %v", err)
+	}
+	long := string(make([]byte, domain.MaxListNameLength+1))
+	if _, err := lists.Create(ctx, domain.CreateListInput{Name: "x" + long}); !errors.Is(err, domain.ErrListNameTooLong) {
+		t.Errorf("expected ErrListNameTooLong, got %v", err)
+	}
+
+	list, err := lists.Create(ctx, domain.CreateListInput{Name: " Work "})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if list.Name != "Work" {
+		t.Errorf("expected trimmed name, got %q", list.Name)
+	}
+
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Task", ListID: -1}); !errors.Is(err, domain.ErrUnknownList) {
+		t.Errorf("expected ErrUnknownList, got %v", err)
+	}
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Task", ListID: list.ID}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := lists.Delete(ctx, list.ID, false); !errors.Is(err, domain.ErrListNotEmpty) {
+		t.Errorf("expected ErrListNotEmpty, got %v", err)
+	}
+	if err := lists.Delete(ctx, 0, true); !errors.Is(err, domain.ErrInvalidID) {
+		t.Errorf("expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestSubtasks_CyclesAndDelete(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+
+	root, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Root"})
+	child, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Child", ParentID: root.ID})
+	grandchild, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Grandchild", ParentID: child.ID})
+
+	if _, err := svc.Update(ctx, root.ID, domain.UpdateTodoInput{ParentID: &root.ID}); !errors.Is(err, domain.ErrParentCycle) {
+		t.Errorf("expected ErrParentCycle for itself, got %v", err)
+	}
+	if _, err := svc.Update(ctx, root.ID, domain.UpdateTodoInput{ParentID: &grandchild.ID}); !errors.Is(err, domain.ErrParentCycle) {
+		t.Errorf("expected ErrParentCycle for a descendant, got %v", err)
+	}
+
+	tree, err := svc.Subtree(ctx, root.ID)
+	if err != nil {
+		t.Fatalf("Subtree failed: %v", err)
+	}
+	if len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 || tree.Children[0].Children[0].ID != grandchild.ID {
+		t.Errorf("unexpected subtree %+v", tree)
+	}
+
+	if err := svc.Delete(ctx, root.ID, ""); !errors.Is(err, domain.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren, got %v", err)
+	}
+	if err := svc.Delete(ctx, root.ID, "drop"); !errors.Is(err, domain.ErrInvalidChildPolicy) {
+		t.Errorf("expected ErrInvalidChildPolicy, got %v", err)
+	}
+
+	if err := svc.Delete(ctx, child.ID, domain.ChildrenOrphan); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	got, err := svc.GetByID(ctx, grandchild.ID)
+	if err != nil || got.ParentID != 0 {
+		t.Errorf("expected the grandchild moved to the top level, got %+v, %v", got, err)
+	}
+
+	if _, err := svc.Update(ctx, grandchild.ID, domain.UpdateTodoInput{ParentID: &root.ID}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := svc.Delete(ctx, root.ID, domain.ChildrenCascade); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := svc.GetByID(ctx, grandchild.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("expected the cascade to delete the subtree, got %v", err)
+	}
+}
+
+func TestSubtasks_Rollup(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	done := true
+
+	root, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Root", AutoComplete: true})
+	phase, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Phase", ParentID: root.ID, AutoComplete: true})
+	a, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "A", ParentID: phase.ID})
+	b, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "B", ParentID: phase.ID})
+	manual, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Manual"})
+	c, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "C", ParentID: manual.ID})
+
+	svc.Update(ctx, a.ID, domain.UpdateTodoInput{Completed: &done})
+	if got, _ := svc.GetByID(ctx, phase.ID); got.Completed {
+		t.Fatalf("expected the parent open while a subtask is open")
+	}
+
+	svc.Update(ctx, b.ID, domain.UpdateTodoInput{Completed: &done})
+	if got, _ := svc.GetByID(ctx, phase.ID); !got.Completed {
+		t.Errorf("expected the parent completed with its last subtask")
+	}
+	if got, _ := svc.GetByID(ctx, root.ID); !got.Completed {
+		t.Errorf("expected the rollup to reach the grandparent")
+	}
+
+	svc.Update(ctx, c.ID, domain.UpdateTodoInput{Completed: &done})
+	if got, _ := svc.GetByID(ctx, manual.ID); got.Completed {
+		t.Errorf("expected no rollup without auto_complete")
+	}
+	updated, err := svc.Update(ctx, manual.ID, domain.UpdateTodoInput{AutoComplete: &done})
+	if err != nil || !updated.Completed {
+		t.Errorf("expected turning auto_complete on to complete a finished parent, got %+v, %v", updated, err)
+	}
+}
+
+func TestDependencies_CyclesAndCompletion(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+
+	deploy, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Deploy"})
+	build, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Build"})
+	test, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Test"})
+
+	if _, err := svc.AddBlocker(ctx, deploy.ID, test.ID); err != nil {
+		t.Fatalf("AddBlocker failed: %v", err)
+	}
+	if _, err := svc.AddBlocker(ctx, test.ID, build.ID); err != nil {
+		t.Fatalf("AddBlocker failed: %v", err)
+	}
+	if _, err := svc.AddBlocker(ctx, build.ID, deploy.ID); !errors.Is(err, domain.ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle, got %v", err)
+	}
+	if _, err := svc.AddBlocker(ctx, build.ID, build.ID); !errors.Is(err, domain.ErrDependencyCycle) {
+		t.Errorf("expected ErrDependencyCycle for itself, got %v", err)
+	}
+
+	done := true
+	if _, err := svc.Update(ctx, test.ID, domain.UpdateTodoInput{Completed: &done}); !errors.Is(err, domain.ErrTodoBlocked) {
+		t.Errorf("expected ErrTodoBlocked, got %v", err)
+	}
+	if _, err := svc.Update(ctx, build.ID, domain.UpdateTodoInput{Completed: &done}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := svc.Update(ctx, test.ID, domain.UpdateTodoInput{Completed: &done}); err != nil {
+		t.Errorf("expected completing once the blocker is done, got %v", err)
+	}
+
+	blockers, err := svc.Blockers(ctx, deploy.ID)
+	if err != nil || len(blockers) != 1 || blockers[0].ID != test.ID {
+		t.Errorf("expected test blocking deploy, got %+v, %v", blockers, err)
+	}
+}
+
+// reopeningRepo reopens a blocker once the service has read the todo it
+// blocks, as a concurrent request could before the todo is completed.
+type reopeningRepo struct {
+	*memory.TodoRepository
+	todo, blocker int
+}
+
+func (r reopeningRepo) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
+	todo, err := r.TodoRepository.GetByID(ctx, id)
+	if err == nil && id == r.todo {
+		open := false
+		if _, err := r.TodoRepository.Update(ctx, r.blocker, domain.UpdateTodoInput{Completed: &open}); err != nil {
+			return nil, err
+		}
+	}
+	return todo, err
+}
+
+func TestDependencies_BlockerReopenedWhileCompleting(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+
+	deploy, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Deploy"})
+	build, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Build", Completed: true})
+	if err := repo.AddDependency(ctx, domain.Dependency{TodoID: deploy.ID, BlockerID: build.ID}); err != nil {
+		t.Fatalf("AddDependency failed: %v", err)
+	}
+
+	svc := service.NewTodoService(reopeningRepo{repo, deploy.ID, build.ID})
+	done := true
+	if _, err := svc.Update(ctx, deploy.ID, domain.UpdateTodoInput{Completed: &done}); !errors.Is(err, domain.ErrTodoBlocked) {
+		t.Errorf("expected ErrTodoBlocked, got %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, deploy.ID); got.Completed {
+		t.Error("expected deploy left open behind its reopened blocker")
+	}
+}
+
+func TestDependencies_Next(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	urgent := domain.PriorityUrgent
+
+	deploy, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Deploy", Priority: urgent})
+	build, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Build"})
+	docs, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Docs", Priority: domain.PriorityHigh})
+	test, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Test"})
+	svc.Create(ctx, domain.CreateTodoInput{Title: "Done", Completed: true})
+
+	svc.AddBlocker(ctx, deploy.ID, test.ID)
+	svc.AddBlocker(ctx, test.ID, build.ID)
+
+	page, err := svc.Next(ctx, 0)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	want := []int{docs.ID, build.ID, test.ID, deploy.ID}
+	if len(page.Items) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, page.Items)
+	}
+	for i, id := range want {
+		if page.Items[i].ID != id {
+			t.Errorf("position %d: expected %d, got %d", i, id, page.Items[i].ID)
+		}
+	}
+
+	page, _ = svc.Next(ctx, 1)
+	if len(page.Items) != 1 || page.Total != 4 {
+		t.Errorf("expected 1 of 4 todos, got %d of %d", len(page.Items), page.Total)
+	}
+	if _, err := svc.Next(ctx, 101); !errors.Is(err, domain.ErrInvalidLimit) {
+		t.Errorf("expected ErrInvalidLimit, got %v", err)
+	}
+}
+
+func TestRecurrence_Validation(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	due := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Bad", DueAt: &due, Recurrence: "FREQ=HOURLY"}); !errors.Is(err, domain.ErrInvalidRecurrence) {
+		t.Errorf("expected ErrInvalidRecurrence, got %v", err)
+	}
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Undated", Recurrence: "FREQ=DAILY"}); !errors.Is(err, domain.ErrRecurrenceNeedsDueAt) {
+		t.Errorf("expected ErrRecurrenceNeedsDueAt, got %v", err)
+	}
+
+	todo, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Standup", DueAt: &due, Recurrence: "rrule:freq=weekly;byday=fr,mo"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if todo.Recurrence != "FREQ=WEEKLY;BYDAY=MO,FR" || todo.Occurrence != 1 {
+		t.Errorf("expected the canonical rule as occurrence 1, got %q (%d)", todo.Recurrence, todo.Occurrence)
+	}
+	if _, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{ClearDueAt: true}); !errors.Is(err, domain.ErrRecurrenceNeedsDueAt) {
+		t.Errorf("expected ErrRecurrenceNeedsDueAt when clearing due_at, got %v", err)
+	}
+
+	plain, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Plain"})
+	rule := "FREQ=DAILY"
+	if _, err := svc.Update(ctx, plain.ID, domain.UpdateTodoInput{Recurrence: &rule}); !errors.Is(err, domain.ErrRecurrenceNeedsDueAt) {
+		t.Errorf("expected ErrRecurrenceNeedsDueAt, got %v", err)
+	}
+	updated, err := svc.Update(ctx, plain.ID, domain.UpdateTodoInput{Recurrence: &rule, DueAt: &due})
+	if err != nil || updated.Recurrence != rule || updated.Occurrence != 1 {
+		t.Errorf("expected the todo to start recurring, got %+v, %v", updated, err)
+	}
+}
+
+func TestRecurrence_CompletingSpawnsNext(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+	due := time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)
+
+	first, _ := svc.Create(ctx, domain.CreateTodoInput{
+		Title: "Pay rent", Priority: domain.PriorityHigh, DueAt: &due, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2",
+	})
+
+	done := true
+	completed, err := svc.Update(ctx, first.ID, domain.UpdateTodoInput{Completed: &done})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !completed.Completed || completed.NextOccurrenceID == 0 {
+		t.Fatalf("expected the completed todo linked to its next occurrence, got %+v", completed)
+	}
+
+	next, err := svc.GetByID(ctx, completed.NextOccurrenceID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	want := time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC)
+	if next.Completed || next.DueAt == nil || !next.DueAt.Equal(want) {
+		t.Errorf("expected an open occurrence due %s, got %+v", want, next)
+	}
+	if next.Title != "Pay rent" || next.Priority != domain.PriorityHigh || next.Occurrence != 2 || next.PreviousOccurrenceID != first.ID {
+		t.Errorf("expected a copy linked back to %d, got %+v", first.ID, next)
+	}
+
+	// completing again does not spawn a second copy
+	again, _ := svc.Update(ctx, first.ID, domain.UpdateTodoInput{Completed: &done})
+	if again.NextOccurrenceID != next.ID {
+		t.Errorf("expected next occurrence %d kept, got %d", next.ID, again.NextOccurrenceID)
+	}
+
+	// COUNT=2 ends the series
+	last, _ := svc.Update(ctx, next.ID, domain.UpdateTodoInput{Completed: &done})
+	if last.NextOccurrenceID != 0 {
+		t.Errorf("expected no occurrence after the last, got %d", last.NextOccurrenceID)
+	}
+	todos, _ := svc.GetAll(ctx)
+	if len(todos) != 2 {
+		t.Errorf("expected 2 todos, got %d", len(todos))
+	}
+}
+
+// barrier holds the first n callers of wait until all of them have come.
+type barrier struct {
+	pending atomic.Int32
+	arrived sync.WaitGroup
+}
+
+func (b *barrier) set(n int) {
+	b.pending.Store(int32(n))
+	b.arrived.Add(n)
+}
+
+func (b *barrier) wait() {
+	if b.pending.Add(-1) >= 0 {
+		b.arrived.Done()
+		b.arrived.Wait()
+	}
+}
+
+// lockstepRepo has concurrent requests completing the todo with id all read
+// it before any writes it, and all write it before any goes on.
+type lockstepRepo struct {
+	*memory.TodoRepository
+	id            int
+	reads, writes barrier
+}
+
+func (r *lockstepRepo) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
+	todo, err := r.TodoRepository.GetByID(ctx, id)
+	if id == r.id {
+		r.reads.wait()
+	}
+	return todo, err
+}
+
+func (r *lockstepRepo) Update(ctx context.Context, id int, input domain.UpdateTodoInput) (*domain.Todo, error) {
+	todo, err := r.TodoRepository.Update(ctx, id, input)
+	if id == r.id && input.Completed != nil {
+		r.writes.wait()
+	}
+	return todo, err
+}
+
+func TestRecurrence_ConcurrentCompletesSpawnOnce(t *testing.T) {
+	const n = 4
+	repo := &lockstepRepo{TodoRepository: memory.NewTodoRepository()}
+	svc := service.NewTodoService(repo)
+	ctx := context.Background()
+	due := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+	todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Standup", DueAt: &due, Recurrence: "FREQ=DAILY"})
+	repo.id = todo.ID
+	repo.reads.set(n)
+	repo.writes.set(n)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done := true
+			_, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected every completion to succeed, got %v", err)
+		}
+	}
+	todos, _ := svc.GetAll(ctx)
+	if len(todos) != 2 {
+		t.Errorf("expected exactly one next occurrence, got %d todos", len(todos))
+	}
+}
+
+type remindersMock struct {
+	scheduled []domain.Todo
+	cancelled []int
+}
+
+func (m *remindersMock) Schedule(todo domain.Todo) { m.scheduled = append(m.scheduled, todo) }
+func (m *remindersMock) Cancel(id int)             { m.cancelled = append(m.cancelled, id) }
+
+func TestReminders_KeptUpToDate(t *testing.T) {
+	svc, _ := setupService()
+	reminders := &remindersMock{}
+	svc.SetReminders(reminders)
+	ctx := context.Background()
+
+	bad := time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Bad", RemindAt: &bad}); !errors.Is(err, domain.ErrInvalidRemindAt) {
+		t.Errorf("expected ErrInvalidRemindAt, got %v", err)
+	}
+
+	due := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	at := due.Add(-30 * time.Minute)
+	todo, err := svc.Create(ctx, domain.CreateTodoInput{Title: "Standup", DueAt: &due, RemindAt: &at, Recurrence: "FREQ=DAILY"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{RemindAt: &at, ClearRemindAt: true}); !errors.Is(err, domain.ErrRemindAtConflict) {
+		t.Errorf("expected ErrRemindAtConflict, got %v", err)
+	}
+
+	done := true
+	completed, _ := svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+	next, _ := svc.GetByID(ctx, completed.NextOccurrenceID)
+	if want := at.AddDate(0, 0, 1); next.RemindAt == nil || !next.RemindAt.Equal(want) {
+		t.Errorf("expected the next reminder at %s, got %v", want, next.RemindAt)
+	}
+
+	if err := svc.Delete(ctx, next.ID, ""); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// created, completed, next occurrence created
+	if len(reminders.scheduled) != 3 {
+		t.Fatalf("expected 3 scheduled todos, got %d", len(reminders.scheduled))
+	}
+	if reminders.scheduled[1].ReminderPending() || reminders.scheduled[2].ID != next.ID {
+		t.Errorf("unexpected schedule calls %+v", reminders.scheduled)
+	}
+	if len(reminders.cancelled) != 1 || reminders.cancelled[0] != next.ID {
+		t.Errorf("expected %d cancelled, got %v", next.ID, reminders.cancelled)
+	}
+}
+
+type sinkMock struct {
+	events []domain.TodoEvent
+}
+
+func (s *sinkMock) Publish(event domain.TodoEvent) {
+	s.events = append(s.events, event)
+}
+
+func (s *sinkMock) types() []domain.EventType {
+	var types []domain.EventType
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestEvents_PublishedOnChanges(t *testing.T) {
+	svc, _ := setupService()
+	sink := &sinkMock{}
+	svc.AddEventSink(sink)
+	ctx := context.Background()
+
+	parent, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Release"})
+	auto := true
+	svc.Update(ctx, parent.ID, domain.UpdateTodoInput{AutoComplete: &auto})
+	child, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Notes", ParentID: parent.ID})
+
+	done := true
+	svc.Update(ctx, child.ID, domain.UpdateTodoInput{Completed: &done})
+	// completing again is an update but not a completion
+	svc.Update(ctx, child.ID, domain.UpdateTodoInput{Completed: &done})
+	if err := svc.Delete(ctx, parent.ID, domain.ChildrenCascade); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	want := []domain.EventType{
+		domain.EventTodoCreated, domain.EventTodoUpdated, domain.EventTodoCreated,
+		domain.EventTodoUpdated, domain.EventTodoCompleted, // the subtask
+		domain.EventTodoUpdated, domain.EventTodoCompleted, // its parent, rolled up
+		domain.EventTodoUpdated,
+		domain.EventTodoDeleted, domain.EventTodoDeleted,
+	}
+	if got := sink.types(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	if e := sink.events[6]; e.Todo.ID != parent.ID || !e.Todo.Completed || e.Before == nil || e.Before.Completed || e.OccurredAt.IsZero() {
+		t.Errorf("unexpected rollup event %+v", e)
+	}
+	if e := sink.events[3]; e.Before == nil || e.Before.Completed || !e.Todo.Completed {
+		t.Errorf("expected the subtask before and after completion, got %+v", e)
+	}
+	if sink.events[0].Before != nil || sink.events[9].Before != nil {
+		t.Error("expected no before snapshot for created and deleted")
+	}
+	if e := sink.events[9]; e.Todo.ID != parent.ID || e.Todo.Title != "Release" {
+		t.Errorf("expected the deleted parent last, got %+v", e)
+	}
+}
+
+func TestBatch_AtomicAndBestEffort(t *testing.T) {
+	svc, _ := setupService()
+	sink := &sinkMock{}
+	svc.AddEventSink(sink)
+	ctx := context.Background()
+
+	todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Existing"})
+	sink.events = nil
+	rename := func(doc []byte) ([]byte, error) {
+		return bytes.Replace(doc, []byte(`"title":"Existing"`), []byte(`"title":"Renamed"`), 1), nil
+	}
+	ops := []domain.BatchOp{
+		{Op: domain.BatchCreate, Input: domain.CreateTodoInput{Title: "New"}},
+		{Op: domain.BatchUpdate, ID: todo.ID, Patch: rename},
+		{Op: domain.BatchDelete, ID: 999},
+	}
+
+	_, err := svc.Batch(ctx, ops, true)
+	var batchErr *domain.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 || !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Fatalf("expected operation 2 to fail the batch, got %v", err)
+	}
+	all, _ := svc.GetAll(ctx)
+	if len(all) != 1 || all[0].Title != "Existing" {
+		t.Errorf("expected a failed atomic batch to change nothing, got %+v", all)
+	}
+	if len(sink.events) != 0 {
+		t.Errorf("expected no events from a rolled back batch, got %v", sink.types())
+	}
+
+	results, err := svc.Batch(ctx, ops, false)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Err != nil || results[0].Todo.Title != "New" ||
+		results[1].Err != nil || results[1].Todo.Title != "Renamed" || !errors.Is(results[2].Err, domain.ErrTodoNotFound) {
+		t.Errorf("expected two successes and a not found, got %+v", results)
+	}
+
+	results, err = svc.Batch(ctx, ops[:2], true)
+	if err != nil {
+		t.Fatalf("atomic Batch failed: %v", err)
+	}
+	want := []domain.EventType{
+		domain.EventTodoCreated, domain.EventTodoUpdated, // best effort
+		domain.EventTodoCreated, domain.EventTodoUpdated, // atomic, after the commit
+	}
+	if got := sink.types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+
+	if _, err := svc.Batch(ctx, nil, false); !errors.Is(err, domain.ErrEmptyBatch) {
+		t.Errorf("expected ErrEmptyBatch, got %v", err)
+	}
+	if _, err := svc.Batch(ctx, make([]domain.BatchOp, domain.MaxBatchSize+1), false); !errors.Is(err, domain.ErrBatchTooLarge) {
+		t.Errorf("expected ErrBatchTooLarge, got %v", err)
+	}
+}
+
+func TestBatch_CompleteAndDeleteMatching(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+
+	work, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Work", Priority: domain.PriorityHigh})
+	blocker, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Blocker"})
+	blocked, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Blocked", Priority: domain.PriorityHigh})
+	home, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Home"})
+	if _, err := svc.AddBlocker(ctx, blocked.ID, blocker.ID); err != nil {
+		t.Fatalf("AddBlocker failed: %v", err)
+	}
+
+	filter := domain.TodoFilter{Priorities: []domain.Priority{domain.PriorityHigh}}
+	if _, err := svc.CompleteMatching(ctx, filter, true); !errors.Is(err, domain.ErrTodoBlocked) {
+		t.Fatalf("expected the blocked todo to fail the atomic batch, got %v", err)
+	}
+	if got, _ := svc.GetByID(ctx, work.ID); got.Completed {
+		t.Errorf("expected nothing completed by a failed atomic batch")
+	}
+
+	results, err := svc.CompleteMatching(ctx, filter, false)
+	if err != nil {
+		t.Fatalf("CompleteMatching failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != work.ID || results[0].Err != nil || !results[0].Todo.Completed ||
+		results[1].ID != blocked.ID || !errors.Is(results[1].Err, domain.ErrTodoBlocked) {
+		t.Errorf("expected work completed and blocked refused, got %+v", results)
+	}
+
+	parent, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Parent", Completed: true})
+	svc.Create(ctx, domain.CreateTodoInput{Title: "Child", Completed: true, ParentID: parent.ID})
+	results, err = svc.DeleteCompleted(ctx, domain.TodoFilter{}, domain.ChildrenCascade, true)
+	if err != nil {
+		t.Fatalf("DeleteCompleted failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected work, parent and child deleted, got %+v", results)
+	}
+	all, _ := svc.GetAll(ctx)
+	if !reflect.DeepEqual(ids(all), []int{blocker.ID, blocked.ID, home.ID}) {
+		t.Errorf("expected only the open todos left, got %v", ids(all))
+	}
+}
+
+func TestBatch_MatchingLimitedToMaxBatchSize(t *testing.T) {
+	svc, _ := setupService()
+	ctx := context.Background()
+
+	var first *domain.Todo
+	for i := 0; i <= domain.MaxBatchSize; i++ {
+		todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Todo"})
+		if first == nil {
+			first = todo
+		}
+	}
+
+	if _, err := svc.CompleteMatching(ctx, domain.TodoFilter{}, false); !errors.Is(err, domain.ErrBatchTooLarge) {
+		t.Fatalf("expected ErrBatchTooLarge for %d matches, got %v", domain.MaxBatchSize+1, err)
+	}
+	if got, _ := svc.GetByID(ctx, first.ID); got.Completed {
+		t.Errorf("expected nothing completed by a refused batch")
+	}
+
+	done := true
+	svc.Update(ctx, first.ID, domain.UpdateTodoInput{Completed: &done})
+	results, err := svc.CompleteMatching(ctx, domain.TodoFilter{}, false)
+	if err != nil || len(results) != domain.MaxBatchSize {
+		t.Fatalf("expected the %d open todos completed, got %d results and %v", domain.MaxBatchSize, len(results), err)
+	}
+
+	if _, err := svc.DeleteCompleted(ctx, domain.TodoFilter{}, domain.ChildrenBlock, false); !errors.Is(err, domain.ErrBatchTooLarge) {
+		t.Errorf("expected ErrBatchTooLarge for %d matches, got %v", domain.MaxBatchSize+1, err)
+	}
+	if all, _ := svc.GetAll(ctx); len(all) != domain.MaxBatchSize+1 {
+		t.Errorf("expected nothing deleted by a refused batch, got %d todos", len(all))
+	}
+}
+
+func TestTrash_Restore(t *testing.T) {
+	svc, _ := setupService()
+	reminders := &remindersMock{}
+	svc.SetReminders(reminders)
+	sink := &sinkMock{}
+	svc.AddEventSink(sink)
+	ctx := context.Background()
+
+	at := time.Now().Add(time.Hour).UTC()
+	parent, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Release", RemindAt: &at})
+	child, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Notes", ParentID: parent.ID})
+	if err := svc.Delete(ctx, parent.ID, domain.ChildrenCascade); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	trash, err := svc.Trash(ctx)
+	if err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	if len(trash) != 2 {
+		t.Fatalf("expected both todos in the trash, got %v", ids(trash))
+	}
+
+	if _, err := svc.Restore(ctx, 0); !errors.Is(err, domain.ErrInvalidID) {
+		t.Errorf("expected ErrInvalidID, got %v", err)
+	}
+	if _, err := svc.Restore(ctx, child.ID); !errors.Is(err, domain.ErrParentDeleted) {
+		t.Errorf("expected ErrParentDeleted, got %v", err)
+	}
+	restored, err := svc.Restore(ctx, parent.ID)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if last := reminders.scheduled[len(reminders.scheduled)-1]; last.ID != parent.ID || !last.ReminderPending() {
+		t.Errorf("expected the reminder scheduled again, got %+v", last)
+	}
+	e := sink.events[len(sink.events)-1]
+	if e.Type != domain.EventTodoRestored || e.Todo.ID != parent.ID || e.Todo.Version != restored.Version {
+		t.Errorf("expected todo.restored for the parent, got %+v", e)
+	}
+}
+
+func ids(todos []domain.Todo) []int {
+	var ids []int
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func TestWebhookService_Validation(t *testing.T) {
+	svc := service.NewWebhookService(memory.NewTodoRepository(), nil)
+	ctx := context.Background()
+	secret := "0123456789abcdef"
+
+	tests := []struct {
+		name  string
+		input domain.CreateWebhookInput
+		want  error
+	}{
+		{"relative url", domain.CreateWebhookInput{URL: "/hook", Secret: secret}, domain.ErrInvalidWebhookURL},
+		{"ftp url", domain.CreateWebhookInput{URL: "ftp://example.com", Secret: secret}, domain.ErrInvalidWebhookURL},
+		{"short secret", domain.CreateWebhookInput{URL: "https://example.com", Secret: "short"}, domain.ErrWebhookSecret},
+		{"unknown event", domain.CreateWebhookInput{URL: "https://example.com", Secret: secret, Events: []domain.EventType{"todo.moved"}}, domain.ErrInvalidEventType},
+		{"localhost", domain.CreateWebhookInput{URL: "http://LocalHost.:8080/hook", Secret: secret}, domain.ErrWebhookURLNotPublic},
+		{"loopback", domain.CreateWebhookInput{URL: "http://127.0.0.1/hook", Secret: secret}, domain.ErrWebhookURLNotPublic},
+		{"ipv6 loopback", domain.CreateWebhookInput{URL: "http://[::1]/hook", Secret: secret}, domain.ErrWebhookURLNotPublic},
+		{"private", domain.CreateWebhookInput{URL: "https://10.1.2.3/hook", Secret: secret}, domain.ErrWebhookURLNotPublic},
+		{"metadata", domain.CreateWebhookInput{URL: "http://169.254.169.254/latest", Secret: secret}, domain.ErrWebhookURLNotPublic},
+		{"mapped private", domain.CreateWebhookInput{URL: "http://[::ffff:192.168.0.1]/hook", Secret: secret}, domain.ErrWebhookURLNotPublic},
+		{"unspecified", domain.CreateWebhookInput{URL: "http://0.0.0.0/hook", Secret: secret}, domain.ErrWebhookURLNotPublic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Create(ctx, tt.input); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	hook, err := svc.Create(ctx, domain.CreateWebhookInput{URL: " https://example.com/hook ", Secret: secret})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if hook.Secret != "" || hook.URL != "https://example.com/hook" || !hook.Active {
+		t.Errorf("unexpected webhook %+v", hook)
+	}
+	if _, err := svc.Create(ctx, domain.CreateWebhookInput{URL: "https://93.184.216.34/hook", Secret: secret}); err != nil {
+		t.Errorf("expected a public address accepted, got %v", err)
+	}
+
+	svc.AllowPrivateURLs()
+	if _, err := svc.Create(ctx, domain.CreateWebhookInput{URL: "http://10.1.2.3/hook", Secret: secret}); err != nil {
+		t.Errorf("expected a private address accepted once allowed, got %v", err)
+	}
+}
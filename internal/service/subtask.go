@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// Subtree returns the todo with id and all of its subtasks, each level
+// ordered by id.
+func (s *TodoService) Subtree(ctx context.Context, id int) (*domain.TodoTree, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	todo, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &domain.TodoTree{Todo: *todo}
+	if err := s.fillTree(ctx, tree, map[int]bool{id: true}); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// fillTree adds the subtasks below tree. seen guards against a cycle in the
+// stored tree, which the repositories never write.
+func (s *TodoService) fillTree(ctx context.Context, tree *domain.TodoTree, seen map[int]bool) error {
+	children, err := s.children(ctx, tree.ID)
+	if err != nil {
+		return err
+	}
+
+	tree.Children = make([]domain.TodoTree, 0, len(children))
+	for _, child := range children {
+		if seen[child.ID] {
+			continue
+		}
+		seen[child.ID] = true
+
+		node := domain.TodoTree{Todo: child}
+		if err := s.fillTree(ctx, &node, seen); err != nil {
+			return err
+		}
+		tree.Children = append(tree.Children, node)
+	}
+	return nil
+}
+
+func (s *TodoService) children(ctx context.Context, id int) ([]domain.Todo, error) {
+	page, err := s.repo.List(ctx, domain.ListQuery{Filter: domain.TodoFilter{ParentID: &id}})
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// rollup completes the todo with id when it asks for AutoComplete, is not
+// blocked and all of its subtasks are completed, then does the same for its
+// parent.
+func (s *TodoService) rollup(ctx context.Context, id int) error {
+	seen := map[int]bool{}
+	for id != 0 && !seen[id] {
+		seen[id] = true
+
+		todo, err := s.repo.GetByID(ctx, id)
+		if errors.Is(err, domain.ErrTodoNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !todo.AutoComplete || todo.Completed || todo.Blocked {
+			return nil
+		}
+
+		children, err := s.children(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		for _, child := range children {
+			if !child.Completed {
+				return nil
+			}
+		}
+
+		completed := true
+		updated, err := s.repo.Update(ctx, id, domain.UpdateTodoInput{Completed: &completed})
+		if err != nil {
+			return err
+		}
+		s.scheduleReminder(updated)
+		s.publishUpdate(todo, updated)
+		id = todo.ParentID
+	}
+	return nil
+}
+
+func (s *TodoService) deleteChildren(ctx context.Context, id int, policy domain.ChildPolicy) error {
+	children, err := s.children(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	switch policy {
+	case domain.ChildrenCascade:
+		for _, child := range children {
+			if err := s.deleteChildren(ctx, child.ID, policy); err != nil {
+				return err
+			}
+			err := s.repo.Delete(ctx, child.ID)
+			if errors.Is(err, domain.ErrTodoNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			s.cancelReminder(child.ID)
+			s.publish(domain.EventTodoDeleted, nil, &child)
+		}
+	case domain.ChildrenOrphan:
+		top := 0
+		for _, child := range children {
+			updated, err := s.repo.Update(ctx, child.ID, domain.UpdateTodoInput{ParentID: &top})
+			if errors.Is(err, domain.ErrTodoNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			s.publishUpdate(&child, updated)
+		}
+	default:
+		return domain.ErrHasChildren
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+type TagService struct {
+	repo domain.TagRepository
+}
+
+func NewTagService(repo domain.TagRepository) *TagService {
+	return &TagService{repo: repo}
+}
+
+func (s *TagService) Create(ctx context.Context, input domain.CreateTagInput) (*domain.Tag, error) {
+	name, err := domain.NormalizeTagName(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	color, err := domain.NormalizeTagColor(input.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.CreateTag(ctx, domain.CreateTagInput{Name: name, Color: color})
+}
+
+func (s *TagService) GetByID(ctx context.Context, id int) (*domain.Tag, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTag(ctx, id)
+}
+
+func (s *TagService) List(ctx context.Context) ([]domain.Tag, error) {
+	return s.repo.ListTags(ctx)
+}
+
+// Update renames or recolors a tag; todos carrying it follow the new name.
+func (s *TagService) Update(ctx context.Context, id int, input domain.UpdateTagInput) (*domain.Tag, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		name, err := domain.NormalizeTagName(*input.Name)
+		if err != nil {
+			return nil, err
+		}
+		input.Name = &name
+	}
+
+	if input.Color != nil {
+		color, err := domain.NormalizeTagColor(*input.Color)
+		if err != nil {
+			return nil, err
+		}
+		input.Color = &color
+	}
+
+	return s.repo.UpdateTag(ctx, id, input)
+}
+
+// Delete removes a tag and detaches it from every todo.
+func (s *TagService) Delete(ctx context.Context, id int) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteTag(ctx, id)
+}
@@ -2,27 +2,69 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/yokitheyo/todo/internal/domain"
 )
 
 type TodoService struct {
-	repo domain.TodoRepository
+	repo  domain.TodoRepository
+	deps  domain.DependencyRepository // nil when repo keeps no dependency graph
+	trash domain.TrashRepository      // nil when repo deletes for good
+
+	reminders Reminders // nil until SetReminders
+	sinks     []EventSink
 }
 
+// NewTodoService uses repo for dependencies and the trash too when it
+// implements domain.DependencyRepository and domain.TrashRepository.
 func NewTodoService(repo domain.TodoRepository) *TodoService {
-	return &TodoService{repo: repo}
+	deps, _ := repo.(domain.DependencyRepository)
+	trash, _ := repo.(domain.TrashRepository)
+	return &TodoService{repo: repo, deps: deps, trash: trash}
 }
 
 func (s *TodoService) Create(ctx context.Context, input domain.CreateTodoInput) (*domain.Todo, error) {
 	input.Title = strings.TrimSpace(input.Title)
 	input.Description = strings.TrimSpace(input.Description)
+	if input.Priority == "" {
+		input.Priority = domain.PriorityNone
+	}
+	input.DueAt = utc(input.DueAt)
+	input.RemindAt = utc(input.RemindAt)
 
 	if err := s.validateCreateInput(input); err != nil {
 		return nil, err
 	}
-	return s.repo.Create(ctx, input)
+
+	rule, err := normalizeRecurrence(input.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	if rule != "" {
+		if input.DueAt == nil {
+			return nil, domain.ErrRecurrenceNeedsDueAt
+		}
+		input.Recurrence = rule
+		input.Occurrence = max(input.Occurrence, 1)
+	}
+
+	tags, err := domain.NormalizeTagNames(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	input.Tags = tags
+
+	todo, err := s.repo.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	s.scheduleReminder(todo)
+	s.publish(domain.EventTodoCreated, nil, todo)
+	return todo, nil
 }
 
 func (s *TodoService) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
@@ -58,14 +100,157 @@ func (s *TodoService) Update(ctx context.Context, id int, input domain.UpdateTod
 	if input.Completed != nil {
 	}
 
-	return s.repo.Update(ctx, id, input)
+	input.DueAt = utc(input.DueAt)
+	input.RemindAt = utc(input.RemindAt)
+
+	if input.Tags != nil {
+		tags, err := domain.NormalizeTagNames(*input.Tags)
+		if err != nil {
+			return nil, err
+		}
+		input.Tags = &tags
+	}
+
+	if input.Recurrence != nil {
+		rule, err := normalizeRecurrence(*input.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+		input.Recurrence = &rule
+	}
+
+	for attempt := 1; ; attempt++ {
+		todo, err := s.update(ctx, id, input)
+		// a completion racing another change to the todo starts over on
+		// the new state, unless the caller asked for a version
+		if errors.Is(err, domain.ErrVersionMismatch) && input.Version == nil && attempt < patchAttempts {
+			continue
+		}
+		return todo, err
+	}
+}
+
+// update applies the normalized input. A completion is conditional on the
+// version it read, so of two racing completions of a recurring todo only
+// the one that completed it spawns the next occurrence; the repository
+// refuses to complete a blocked todo.
+func (s *TodoService) update(ctx context.Context, id int, input domain.UpdateTodoInput) (*domain.Todo, error) {
+	completing := input.Completed != nil && *input.Completed
+	var current *domain.Todo
+	if completing || input.Recurrence != nil || input.ClearDueAt || input.Version != nil || len(s.sinks) > 0 {
+		var err error
+		if current, err = s.repo.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.Version != nil && current.Version != *input.Version {
+		return nil, domain.ErrVersionMismatch
+	}
+	if completing {
+		input.Version = &current.Version
+	}
+	if current != nil {
+		if err := checkRecurrence(current, input); err != nil {
+			return nil, err
+		}
+		if input.Recurrence != nil && *input.Recurrence != "" && current.Occurrence == 0 {
+			first := 1
+			input.Occurrence = &first
+		}
+	}
+
+	todo, err := s.repo.Update(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	s.scheduleReminder(todo)
+
+	if completing && !current.Completed && todo.Recurrence != "" && todo.NextOccurrenceID == 0 {
+		if todo, err = s.nextOccurrence(ctx, todo); err != nil {
+			return nil, err
+		}
+	}
+	if current != nil {
+		// always loaded when there are sinks to publish to
+		s.publishUpdate(current, todo)
+	}
+
+	if input.AutoComplete != nil && *input.AutoComplete {
+		if err := s.rollup(ctx, todo.ID); err != nil {
+			return nil, err
+		}
+		return s.repo.GetByID(ctx, todo.ID)
+	}
+	if input.Completed != nil && *input.Completed && todo.ParentID != 0 {
+		if err := s.rollup(ctx, todo.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	return todo, nil
+}
+
+// Delete removes a todo, handling its subtasks as children says; an empty
+// policy means ChildrenBlock.
+func (s *TodoService) Delete(ctx context.Context, id int, children domain.ChildPolicy) error {
+	return s.DeleteVersion(ctx, id, children, 0)
 }
 
-func (s *TodoService) Delete(ctx context.Context, id int) error {
+// DeleteVersion deletes like Delete, but only while the todo is at version;
+// version 0 deletes it at any version.
+func (s *TodoService) DeleteVersion(ctx context.Context, id int, children domain.ChildPolicy, version int) error {
 	if err := validateID(id); err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, id)
+	if version < 0 {
+		return domain.ErrInvalidVersion
+	}
+	if children == "" {
+		children = domain.ChildrenBlock
+	}
+	if !children.Valid() {
+		return domain.ErrInvalidChildPolicy
+	}
+
+	if _, ok := s.repo.(domain.Transactor); ok {
+		// the subtasks and the todo are deleted together or not at all
+		return s.inTx(ctx, func(tx *TodoService) error {
+			return tx.deleteVersion(ctx, id, children, version)
+		})
+	}
+	return s.deleteVersion(ctx, id, children, version)
+}
+
+func (s *TodoService) deleteVersion(ctx context.Context, id int, children domain.ChildPolicy, version int) error {
+	todo, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	// checked up front as well, so a stale delete leaves the subtasks alone
+	if version != 0 && todo.Version != version {
+		return domain.ErrVersionMismatch
+	}
+
+	if err := s.deleteChildren(ctx, id, children); err != nil {
+		return err
+	}
+	if version != 0 {
+		err = s.repo.DeleteVersion(ctx, id, version)
+	} else {
+		err = s.repo.Delete(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	s.cancelReminder(id)
+	s.publish(domain.EventTodoDeleted, nil, todo)
+
+	if todo.ParentID != 0 {
+		// the deleted todo may have been the last open subtask
+		return s.rollup(ctx, todo.ParentID)
+	}
+	return nil
 }
 
 func (s *TodoService) validateTitle(title string) error {
@@ -95,6 +280,26 @@ func (s *TodoService) validateCreateInput(input domain.CreateTodoInput) error {
 		return err
 	}
 
+	if !input.Priority.Valid() {
+		return domain.ErrInvalidPriority
+	}
+
+	if input.DueAt != nil && !domain.ValidDueAt(*input.DueAt) {
+		return domain.ErrInvalidDueAt
+	}
+
+	if input.RemindAt != nil && !domain.ValidDueAt(*input.RemindAt) {
+		return domain.ErrInvalidRemindAt
+	}
+
+	if err := validateListID(input.ListID); err != nil {
+		return err
+	}
+
+	if input.ParentID < 0 {
+		return domain.ErrUnknownParent
+	}
+
 	return nil
 }
 
@@ -109,9 +314,53 @@ func (s *TodoService) validateUpdateInput(input domain.UpdateTodoInput) error {
 		}
 	}
 
+	if input.Priority != nil && !input.Priority.Valid() {
+		return domain.ErrInvalidPriority
+	}
+
+	if input.DueAt != nil {
+		if input.ClearDueAt {
+			return domain.ErrDueAtConflict
+		}
+		if !domain.ValidDueAt(*input.DueAt) {
+			return domain.ErrInvalidDueAt
+		}
+	}
+
+	if input.RemindAt != nil {
+		if input.ClearRemindAt {
+			return domain.ErrRemindAtConflict
+		}
+		if !domain.ValidDueAt(*input.RemindAt) {
+			return domain.ErrInvalidRemindAt
+		}
+	}
+
+	if input.ListID != nil {
+		if err := validateListID(*input.ListID); err != nil {
+			return err
+		}
+	}
+
+	if input.ParentID != nil && *input.ParentID < 0 {
+		return domain.ErrUnknownParent
+	}
+
+	if input.Version != nil && *input.Version <= 0 {
+		return domain.ErrInvalidVersion
+	}
+
 	return nil
 }
 
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
 func validateID(id int) error {
 	if id <= 0 {
 		return domain.ErrInvalidID
@@ -124,3 +373,68 @@ func (s *TodoService) GetFiltered(ctx context.Context, completed *bool, search s
 
 	return s.repo.GetFiltered(ctx, completed, search)
 }
+
+func (s *TodoService) List(ctx context.Context, filter domain.TodoFilter, sort string, page domain.PageRequest) (*domain.TodoPage, error) {
+	if page.Limit == 0 {
+		page.Limit = domain.DefaultPageLimit
+	}
+	if page.Limit < 0 || page.Limit > domain.MaxPageLimit {
+		return nil, domain.ErrInvalidLimit
+	}
+
+	filter.Search = strings.TrimSpace(filter.Search)
+	if sort == "" && filter.Search != "" {
+		sort = domain.DefaultSearchSort
+	}
+
+	keys, err := domain.ParseSort(sort)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Search == "" && domain.HasSortField(keys, domain.SortByScore) {
+		return nil, fmt.Errorf("%w: score needs a search", domain.ErrInvalidSort)
+	}
+
+	for _, p := range filter.Priorities {
+		if !p.Valid() {
+			return nil, domain.ErrInvalidPriority
+		}
+	}
+
+	if filter.Tags, err = domain.NormalizeTagNames(filter.Tags); err != nil {
+		return nil, err
+	}
+	switch filter.TagMatch {
+	case "":
+		filter.TagMatch = domain.TagMatchAny
+	case domain.TagMatchAny, domain.TagMatchAll:
+	default:
+		return nil, domain.ErrInvalidTagMatch
+	}
+	if filter.ListID != nil {
+		if err := validateListID(*filter.ListID); err != nil {
+			return nil, err
+		}
+	}
+	if filter.ParentID != nil && *filter.ParentID < 0 {
+		return nil, domain.ErrUnknownParent
+	}
+	if filter.Now.IsZero() {
+		filter.Now = time.Now()
+	}
+	query := domain.ListQuery{Filter: filter, Sort: keys, Limit: page.Limit}
+
+	if page.Cursor != "" {
+		after, err := domain.DecodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// a cursor only makes sense for the order it was taken from
+		if !after.Matches(keys) {
+			return nil, domain.ErrInvalidCursor
+		}
+		query.After = after
+	}
+
+	return s.repo.List(ctx, query)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+type ListService struct {
+	repo domain.ListRepository
+}
+
+func NewListService(repo domain.ListRepository) *ListService {
+	return &ListService{repo: repo}
+}
+
+func (s *ListService) Create(ctx context.Context, input domain.CreateListInput) (*domain.TodoList, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Description = strings.TrimSpace(input.Description)
+
+	if err := validateListName(input.Name); err != nil {
+		return nil, err
+	}
+	if len(input.Description) > domain.MaxDescriptionLength {
+		return nil, domain.ErrDescriptionTooLong
+	}
+
+	return s.repo.CreateList(ctx, input)
+}
+
+func (s *ListService) GetByID(ctx context.Context, id int) (*domain.TodoList, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetList(ctx, id)
+}
+
+func (s *ListService) List(ctx context.Context) ([]domain.TodoList, error) {
+	return s.repo.GetLists(ctx)
+}
+
+func (s *ListService) Update(ctx context.Context, id int, input domain.UpdateListInput) (*domain.TodoList, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		trimmed := strings.TrimSpace(*input.Name)
+		if err := validateListName(trimmed); err != nil {
+			return nil, err
+		}
+		input.Name = &trimmed
+	}
+
+	if input.Description != nil {
+		trimmed := strings.TrimSpace(*input.Description)
+		if len(trimmed) > domain.MaxDescriptionLength {
+			return nil, domain.ErrDescriptionTooLong
+		}
+		input.Description = &trimmed
+	}
+
+	return s.repo.UpdateList(ctx, id, input)
+}
+
+// Delete removes a list. A list that still has todos is only deleted with
+// cascade, which moves its todos to the trash.
+func (s *ListService) Delete(ctx context.Context, id int, cascade bool) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteList(ctx, id, cascade)
+}
+
+func validateListName(name string) error {
+	if name == "" {
+		return domain.ErrListNameRequired
+	}
+	if len(name) > domain.MaxListNameLength {
+		return domain.ErrListNameTooLong
+	}
+	return nil
+}
+
+// validateListID accepts 0, which stands for no list.
+func validateListID(id int) error {
+	if id < 0 {
+		return domain.ErrUnknownList
+	}
+	return nil
+}
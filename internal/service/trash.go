@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+var errNoTrash = errors.New("storage does not keep deleted todos")
+
+// Trash returns the deleted todos, most recently deleted first.
+func (s *TodoService) Trash(ctx context.Context) ([]domain.Todo, error) {
+	if s.trash == nil {
+		return nil, errNoTrash
+	}
+	return s.trash.ListTrash(ctx)
+}
+
+// Restore takes the todo with id out of the trash. Its reminder is set
+// again, but the dependencies it lost when deleted are not.
+func (s *TodoService) Restore(ctx context.Context, id int) (*domain.Todo, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	if s.trash == nil {
+		return nil, errNoTrash
+	}
+
+	todo, err := s.trash.RestoreDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.scheduleReminder(todo)
+	s.publish(domain.EventTodoRestored, nil, todo)
+	return todo, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+// DeliveryLog keeps the recent deliveries of every webhook, newest first.
+type DeliveryLog interface {
+	Deliveries(webhookID int) []domain.WebhookDelivery
+	DeadLetters() []domain.WebhookDelivery
+}
+
+type WebhookService struct {
+	repo         domain.WebhookRepository
+	deliveries   DeliveryLog
+	allowPrivate bool
+}
+
+func NewWebhookService(repo domain.WebhookRepository, deliveries DeliveryLog) *WebhookService {
+	return &WebhookService{repo: repo, deliveries: deliveries}
+}
+
+// AllowPrivateURLs lets webhooks point to loopback and private addresses,
+// for receivers on a trusted network.
+func (s *WebhookService) AllowPrivateURLs() {
+	s.allowPrivate = true
+}
+
+func (s *WebhookService) Create(ctx context.Context, input domain.CreateWebhookInput) (*domain.Webhook, error) {
+	input.URL = strings.TrimSpace(input.URL)
+	if err := s.validateWebhookURL(input.URL); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookSecret(input.Secret); err != nil {
+		return nil, err
+	}
+
+	events, err := domain.NormalizeEventTypes(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	input.Events = events
+
+	hook, err := s.repo.CreateWebhook(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return redact(hook), nil
+}
+
+func (s *WebhookService) GetByID(ctx context.Context, id int) (*domain.Webhook, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	hook, err := s.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return redact(hook), nil
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]domain.Webhook, error) {
+	hooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+func (s *WebhookService) Update(ctx context.Context, id int, input domain.UpdateWebhookInput) (*domain.Webhook, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	if input.URL != nil {
+		trimmed := strings.TrimSpace(*input.URL)
+		if err := s.validateWebhookURL(trimmed); err != nil {
+			return nil, err
+		}
+		input.URL = &trimmed
+	}
+	if input.Secret != nil {
+		if err := validateWebhookSecret(*input.Secret); err != nil {
+			return nil, err
+		}
+	}
+	if input.Events != nil {
+		events, err := domain.NormalizeEventTypes(*input.Events)
+		if err != nil {
+			return nil, err
+		}
+		input.Events = &events
+	}
+
+	hook, err := s.repo.UpdateWebhook(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	return redact(hook), nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+// Deliveries returns the webhook's recent deliveries, newest first, limited
+// to those with status unless it is empty.
+func (s *WebhookService) Deliveries(ctx context.Context, id int, status domain.DeliveryStatus) ([]domain.WebhookDelivery, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	if status != "" && !status.Valid() {
+		return nil, domain.ErrInvalidDeliveryStatus
+	}
+	if _, err := s.repo.GetWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+
+	out := []domain.WebhookDelivery{}
+	for _, d := range s.deliveries.Deliveries(id) {
+		if status == "" || d.Status == status {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// DeadLetters returns the deliveries that ran out of retries, newest first.
+func (s *WebhookService) DeadLetters(ctx context.Context) ([]domain.WebhookDelivery, error) {
+	return s.deliveries.DeadLetters(), nil
+}
+
+// validateWebhookURL refuses hosts that are local by name or address. Names
+// are not resolved here, the dispatcher checks the address it connects to.
+func (s *WebhookService) validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return domain.ErrInvalidWebhookURL
+	}
+	if s.allowPrivate {
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return domain.ErrWebhookURLNotPublic
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !domain.PublicAddr(addr) {
+		return domain.ErrWebhookURLNotPublic
+	}
+	return nil
+}
+
+func validateWebhookSecret(secret string) error {
+	if len(secret) < domain.MinWebhookSecretLength || len(secret) > domain.MaxWebhookSecretLength {
+		return domain.ErrWebhookSecret
+	}
+	return nil
+}
+
+// redact drops the secret, which is never handed back to clients.
+func redact(hook *domain.Webhook) *domain.Webhook {
+	hook.Secret = ""
+	return hook
+}
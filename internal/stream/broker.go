@@ -0,0 +1,178 @@
+// Package stream fans todo events out to live subscribers, such as the
+// Server-Sent Events endpoint, and keeps the latest of them so a subscriber
+// that lost its connection can resume where it left off.
+package stream
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+type Options struct {
+	// BufferSize is how many of the latest events are kept for resuming.
+	BufferSize int
+	// SubscriberBuffer is how many events a subscriber may fall behind
+	// before it is dropped.
+	SubscriberBuffer int
+}
+
+// Event is a published todo event with the ID subscribers resume from.
+type Event struct {
+	ID string
+	domain.TodoEvent
+
+	seq uint64
+}
+
+// Broker implements service.EventSink. Event IDs are "<epoch>-<sequence>",
+// where the epoch changes every time the process starts, so an ID from an
+// earlier run is never mistaken for a recent one.
+type Broker struct {
+	opts  Options
+	epoch string
+
+	mu     sync.Mutex
+	seq    uint64
+	buffer []Event // oldest first
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroker(opts Options) *Broker {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1000
+	}
+	if opts.SubscriberBuffer <= 0 {
+		opts.SubscriberBuffer = 64
+	}
+	return &Broker{
+		opts:  opts,
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events published after it was made on C, which
+// is closed when the subscription or the broker is closed, or when the
+// subscriber fell too far behind.
+type Subscription struct {
+	C <-chan Event
+
+	// Backlog holds the events published after the ID the subscription
+	// resumed from. Reset reports that it could not resume, because the ID
+	// is unknown or older than the buffer; the subscriber then has to
+	// reload what it shows. LastID is the ID of the latest event either way.
+	Backlog []Event
+	Reset   bool
+	LastID  string
+
+	broker *Broker
+	events chan Event
+}
+
+// Publish never blocks: subscribers that are too far behind are dropped and
+// can resume from their last event.
+func (b *Broker) Publish(e domain.TodoEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	event := Event{ID: b.id(b.seq), TodoEvent: e, seq: b.seq}
+	if len(b.buffer) == b.opts.BufferSize {
+		copy(b.buffer, b.buffer[1:])
+		b.buffer = b.buffer[:len(b.buffer)-1]
+	}
+	b.buffer = append(b.buffer, event)
+
+	for sub := range b.subs {
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts a subscription. A non-empty lastEventID resumes after
+// that event.
+func (b *Broker) Subscribe(lastEventID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan Event, b.opts.SubscriberBuffer)
+	sub := &Subscription{C: events, LastID: b.id(b.seq), broker: b, events: events}
+	if b.closed {
+		close(events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub
+	}
+	after, ok := b.parseID(lastEventID)
+	if !ok || after > b.seq || (len(b.buffer) > 0 && after+1 < b.buffer[0].seq) {
+		sub.Reset = true
+		return sub
+	}
+	for _, event := range b.buffer {
+		if event.seq > after {
+			sub.Backlog = append(sub.Backlog, event)
+		}
+	}
+	return sub
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+// Close ends every subscription and any made later, e.g. when the server
+// shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+// Len returns the number of subscriptions.
+func (b *Broker) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// drop needs b.mu.
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+func (b *Broker) id(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func (b *Broker) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(strings.TrimSpace(id), "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
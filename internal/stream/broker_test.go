@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/yokitheyo/todo/internal/domain"
+)
+
+func publish(b *Broker, ids ...int) {
+	for _, id := range ids {
+		b.Publish(domain.TodoEvent{Type: domain.EventTodoCreated, Todo: domain.Todo{ID: id}})
+	}
+}
+
+func todoIDs(events []Event) []int {
+	var ids []int
+	for _, e := range events {
+		ids = append(ids, e.Todo.ID)
+	}
+	return ids
+}
+
+func TestBroker_Resume(t *testing.T) {
+	b := NewBroker(Options{BufferSize: 3})
+	first := b.Subscribe("")
+	defer first.Close()
+
+	publish(b, 1, 2)
+	e1 := <-first.C
+	<-first.C
+
+	sub := b.Subscribe(e1.ID)
+	if sub.Reset || len(sub.Backlog) != 1 || sub.Backlog[0].Todo.ID != 2 {
+		t.Fatalf("expected to resume with todo 2, got reset=%v %v", sub.Reset, todoIDs(sub.Backlog))
+	}
+	sub.Close()
+
+	// todo 1 falls out of the buffer, but nothing after it was lost yet
+	publish(b, 3, 4)
+	if sub = b.Subscribe(e1.ID); sub.Reset || len(sub.Backlog) != 3 {
+		t.Errorf("expected todos 2-4, got reset=%v %v", sub.Reset, todoIDs(sub.Backlog))
+	}
+	sub.Close()
+
+	publish(b, 5)
+	if sub = b.Subscribe(e1.ID); !sub.Reset || sub.Backlog != nil {
+		t.Errorf("expected a reset once todo 2 is gone, got %v", todoIDs(sub.Backlog))
+	}
+	sub.Close()
+
+	for _, id := range []string{"other-1", "garbage", first.LastID[:len(first.LastID)-1] + "99"} {
+		sub = b.Subscribe(id)
+		if !sub.Reset {
+			t.Errorf("expected a reset for %q", id)
+		}
+		sub.Close()
+	}
+}
+
+func TestBroker_DropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(Options{SubscriberBuffer: 2})
+	slow := b.Subscribe("")
+	fast := b.Subscribe("")
+	defer fast.Close()
+
+	for id := 1; id <= 3; id++ {
+		publish(b, id)
+		<-fast.C
+	}
+
+	var got []Event
+	for e := range slow.C {
+		got = append(got, e)
+	}
+	if len(got) != 2 || b.Len() != 1 {
+		t.Fatalf("expected the slow subscriber dropped after 2 events, got %v with %d left", todoIDs(got), b.Len())
+	}
+
+	// it can resume where it stopped
+	sub := b.Subscribe(got[1].ID)
+	defer sub.Close()
+	if len(sub.Backlog) != 1 || sub.Backlog[0].Todo.ID != 3 {
+		t.Errorf("expected to resume with todo 3, got %v", todoIDs(sub.Backlog))
+	}
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(Options{})
+	sub := b.Subscribe("")
+	b.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription closed")
+	}
+	sub.Close()
+
+	late := b.Subscribe("")
+	if _, ok := <-late.C; ok {
+		t.Error("expected a subscription made after Close to be closed")
+	}
+	publish(b, 1)
+}
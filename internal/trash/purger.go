@@ -0,0 +1,104 @@
+// Package trash removes deleted todos for good once they have been in the
+// trash long enough.
+package trash
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+type Options struct {
+	// Retention is how long a deleted todo stays in the trash.
+	Retention time.Duration
+
+	// Interval is the wait between two purges.
+	Interval time.Duration
+
+	Logger *logger.Logger
+}
+
+// Purger purges the trash every Interval, once when started and then
+// periodically.
+type Purger struct {
+	repo domain.TrashRepository
+	opts Options
+	log  *logger.Logger
+	now  func() time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewPurger(repo domain.TrashRepository, opts Options) *Purger {
+	if opts.Retention <= 0 {
+		opts.Retention = 30 * 24 * time.Hour
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+
+	log := opts.Logger
+	if log == nil {
+		log = logger.New("error", io.Discard, "text")
+	}
+
+	return &Purger{repo: repo, opts: opts, log: log, now: time.Now}
+}
+
+func (p *Purger) Start() {
+	runCtx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go p.run(runCtx)
+
+	p.log.Info("trash purger started", "retention", p.opts.Retention, "interval", p.opts.Interval)
+}
+
+// Stop cancels a purge in flight and waits for the purger to finish or ctx
+// to end.
+func (p *Purger) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Purge removes the todos deleted more than Retention ago and reports how
+// many there were.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	return p.repo.PurgeTrash(ctx, p.now().Add(-p.opts.Retention))
+}
+
+func (p *Purger) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := p.Purge(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			p.log.Error("failed to purge trash", "error", err)
+		case n > 0:
+			p.log.Info("purged trash", "todos", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package trash
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/repository/memory"
+)
+
+func TestPurger_PurgesOnlyExpiredTrash(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+
+	old, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Old"})
+	recent, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Recent"})
+	repo.Delete(ctx, old.ID)
+	repo.Delete(ctx, recent.ID)
+
+	p := NewPurger(repo, Options{Retention: time.Hour})
+	p.now = func() time.Time { return time.Now().Add(30 * time.Minute) }
+	if n, err := p.Purge(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing purged within the retention, got %d, %v", n, err)
+	}
+
+	p.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if n, err := p.Purge(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 purged, got %d, %v", n, err)
+	}
+	if trash, _ := repo.ListTrash(ctx); len(trash) != 0 {
+		t.Errorf("expected an empty trash, got %+v", trash)
+	}
+}
+
+func TestPurger_RunsUntilStopped(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	ctx := context.Background()
+
+	p := NewPurger(repo, Options{Retention: time.Millisecond, Interval: 5 * time.Millisecond})
+	p.Start()
+
+	todo, _ := repo.Create(ctx, domain.CreateTodoInput{Title: "Gone"})
+	repo.Delete(ctx, todo.ID)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		trash, _ := repo.ListTrash(ctx)
+		if len(trash) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the purger to empty the trash")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := p.Stop(stopCtx); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+// Package webhook delivers todo events to the URLs subscribed to them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/pkg/logger"
+)
+
+// Headers set on every delivery. The signature is the hex HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the webhook's secret.
+const (
+	HeaderDelivery  = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type Options struct {
+	Workers   int
+	QueueSize int
+
+	// MaxAttempts is how often a delivery is tried before it is dead. Retries
+	// wait RetryInterval, doubled after every failure up to MaxRetryInterval.
+	MaxAttempts      int
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	// Timeout bounds a single attempt when Client is nil.
+	Timeout time.Duration
+	Client  *http.Client
+
+	// AllowPrivateAddrs lets the default client connect to loopback and
+	// private addresses, which it refuses otherwise; see domain.PublicAddr.
+	AllowPrivateAddrs bool
+
+	// LogSize is how many recent deliveries, and dead letters, are kept.
+	LogSize int
+
+	Logger *logger.Logger
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID         string           `json:"id"`
+	Type       domain.EventType `json:"type"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Todo       domain.Todo      `json:"todo"`
+	Before     *domain.Todo     `json:"before,omitempty"`
+}
+
+// Dispatcher queues a delivery for every webhook that wants a published
+// event and sends them from a pool of workers. Deliveries, including the
+// dead letters, are kept in memory only, as are retries that are still
+// waiting when the dispatcher stops.
+type Dispatcher struct {
+	repo   domain.WebhookRepository
+	opts   Options
+	client *http.Client
+	log    *logger.Logger
+
+	queue chan *job
+	stop  chan struct{}
+	wg    sync.WaitGroup
+
+	mu         sync.Mutex
+	deliveries []*domain.WebhookDelivery // oldest first
+	dead       []domain.WebhookDelivery  // oldest first
+}
+
+type job struct {
+	delivery *domain.WebhookDelivery // guarded by Dispatcher.mu
+	body     []byte
+}
+
+func NewDispatcher(repo domain.WebhookRepository, opts Options) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 6
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	if opts.MaxRetryInterval < opts.RetryInterval {
+		opts.MaxRetryInterval = max(5*time.Minute, opts.RetryInterval)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.LogSize <= 0 {
+		opts.LogSize = 1000
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = newClient(opts)
+	}
+	log := opts.Logger
+	if log == nil {
+		log = logger.New("error", io.Discard, "text")
+	}
+
+	return &Dispatcher{
+		repo:   repo,
+		opts:   opts,
+		client: client,
+		log:    log,
+		queue:  make(chan *job, opts.QueueSize),
+		stop:   make(chan struct{}),
+	}
+}
+
+var errAddrNotPublic = errors.New("webhook: refusing to connect to a non-public address")
+
+// newClient returns a client whose connections are checked once the host
+// name is resolved, so a name pointing to an internal address is refused
+// too, also after a redirect. It ignores proxy settings, which would hide
+// the address.
+func newClient(opts Options) *http.Client {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivateAddrs {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil || !domain.PublicAddr(addr.Addr()) {
+				return errAddrNotPublic
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: opts.Timeout, Transport: transport}
+}
+
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+}
+
+// Stop lets the workers finish the attempts in flight and waits for them or
+// for ctx to end. Queued deliveries and pending retries are dropped.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	close(d.stop)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Publish queues a delivery of event to every active webhook that wants it.
+func (d *Dispatcher) Publish(event domain.TodoEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+
+	hooks, err := d.repo.ListWebhooks(ctx)
+	if err != nil {
+		d.log.Error("failed to list webhooks", "error", err, "event", event.Type)
+		return
+	}
+
+	var body []byte
+	eventID := newID()
+	for _, hook := range hooks {
+		if !hook.Wants(event.Type) {
+			continue
+		}
+
+		if body == nil {
+			body, err = json.Marshal(Payload{ID: eventID, Type: event.Type, OccurredAt: event.OccurredAt, Todo: event.Todo, Before: event.Before})
+			if err != nil {
+				d.log.Error("failed to encode webhook payload", "error", err, "event", event.Type)
+				return
+			}
+		}
+
+		delivery := &domain.WebhookDelivery{
+			ID:        newID(),
+			WebhookID: hook.ID,
+			EventID:   eventID,
+			Event:     event.Type,
+			TodoID:    event.Todo.ID,
+			Status:    domain.DeliveryPending,
+			CreatedAt: time.Now().UTC(),
+		}
+		d.record(delivery)
+		d.enqueue(&job{delivery: delivery, body: body})
+	}
+}
+
+// Deliveries returns the logged deliveries to the webhook, newest first.
+func (d *Dispatcher) Deliveries(webhookID int) []domain.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := []domain.WebhookDelivery{}
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if d.deliveries[i].WebhookID == webhookID {
+			out = append(out, *d.deliveries[i])
+		}
+	}
+	return out
+}
+
+// DeadLetters returns the deliveries that gave up, newest first.
+func (d *Dispatcher) DeadLetters() []domain.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make([]domain.WebhookDelivery, 0, len(d.dead))
+	for i := len(d.dead) - 1; i >= 0; i-- {
+		out = append(out, d.dead[i])
+	}
+	return out
+}
+
+func (d *Dispatcher) record(delivery *domain.WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deliveries = append(d.deliveries, delivery)
+	if n := len(d.deliveries) - d.opts.LogSize; n > 0 {
+		d.deliveries = append(d.deliveries[:0:0], d.deliveries[n:]...)
+	}
+}
+
+// enqueue hands j to the workers, burying it when the queue is full. Once
+// the dispatcher stops nothing is queued any more.
+func (d *Dispatcher) enqueue(j *job) {
+	select {
+	case <-d.stop:
+		return
+	default:
+	}
+
+	select {
+	case d.queue <- j:
+	default:
+		d.bury(j, "delivery queue is full")
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case j := <-d.queue:
+			d.attempt(j)
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(j *job) {
+	d.mu.Lock()
+	webhookID, deliveryID, event := j.delivery.WebhookID, j.delivery.ID, j.delivery.Event
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+
+	hook, err := d.repo.GetWebhook(ctx, webhookID)
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		d.bury(j, "webhook was deleted")
+		return
+	case err != nil:
+		d.fail(j, 0, err)
+		return
+	case !hook.Active:
+		d.bury(j, "webhook is inactive")
+		return
+	}
+
+	status, err := d.post(ctx, hook, deliveryID, event, j.body)
+	if err != nil {
+		d.fail(j, status, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now().UTC()
+	j.delivery.Attempts++
+	j.delivery.LastAttemptAt = &now
+	j.delivery.NextAttemptAt = nil
+	j.delivery.StatusCode = status
+	j.delivery.Error = ""
+	j.delivery.Status = domain.DeliverySucceeded
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook *domain.Webhook, deliveryID string, event domain.EventType, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderEvent, string(event))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// fail records a failed attempt and schedules a retry, or buries the
+// delivery once it is out of attempts.
+func (d *Dispatcher) fail(j *job, status int, err error) {
+	d.mu.Lock()
+	now := time.Now().UTC()
+	j.delivery.Attempts++
+	j.delivery.LastAttemptAt = &now
+	j.delivery.StatusCode = status
+	j.delivery.Error = err.Error()
+	attempts, id := j.delivery.Attempts, j.delivery.ID
+
+	if attempts >= d.opts.MaxAttempts {
+		d.mu.Unlock()
+		d.bury(j, err.Error())
+		return
+	}
+
+	delay := d.backoff(attempts)
+	next := now.Add(delay)
+	j.delivery.Status = domain.DeliveryFailed
+	j.delivery.NextAttemptAt = &next
+	d.mu.Unlock()
+
+	d.log.Warn("webhook delivery failed", "delivery", id, "attempt", attempts, "retry_in", delay.String(), "error", err)
+	time.AfterFunc(delay, func() { d.enqueue(j) })
+}
+
+// bury marks the delivery dead and adds it to the dead letters.
+func (d *Dispatcher) bury(j *job, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	j.delivery.Status = domain.DeliveryDead
+	j.delivery.Error = reason
+	j.delivery.NextAttemptAt = nil
+
+	d.dead = append(d.dead, *j.delivery)
+	if n := len(d.dead) - d.opts.LogSize; n > 0 {
+		d.dead = append(d.dead[:0:0], d.dead[n:]...)
+	}
+
+	d.log.Error("webhook delivery dead", "delivery", j.delivery.ID, "webhook", j.delivery.WebhookID, "attempts", j.delivery.Attempts, "error", reason)
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.RetryInterval
+	for i := 1; i < attempts && delay < d.opts.MaxRetryInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, d.opts.MaxRetryInterval)
+}
+
+// Sign returns the hex HMAC-SHA256 receivers compare the X-Webhook-Signature
+// header against, after its "sha256=" prefix.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b) // never fails, it crashes the program instead
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/todo/internal/domain"
+	"github.com/yokitheyo/todo/internal/repository/memory"
+	"github.com/yokitheyo/todo/internal/service"
+)
+
+const secret = "0123456789abcdef"
+
+// receiver is an httptest webhook endpoint that fails the first failures
+// requests and checks every signature.
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	failures int
+	requests int
+	received []Payload
+	ids      map[string]int // delivery id -> requests carrying it
+	got      chan Payload
+}
+
+func newReceiver(t *testing.T, failures int) (*receiver, *httptest.Server) {
+	rcv := &receiver{t: t, failures: failures, ids: map[string]int{}, got: make(chan Payload, 16)}
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+	return rcv, srv
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if got, want := r.Header.Get(HeaderSignature), "sha256="+Sign(secret, timestamp, body); got != want {
+		rcv.t.Errorf("bad signature %q, want %q", got, want)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.requests++
+	rcv.ids[r.Header.Get(HeaderDelivery)]++
+	if rcv.requests <= rcv.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		rcv.t.Errorf("bad payload: %v", err)
+	}
+	if r.Header.Get(HeaderEvent) != string(p.Type) {
+		rcv.t.Errorf("event header %q does not match payload %q", r.Header.Get(HeaderEvent), p.Type)
+	}
+	rcv.received = append(rcv.received, p)
+	rcv.got <- p
+}
+
+func (rcv *receiver) wait(t *testing.T) Payload {
+	t.Helper()
+	select {
+	case p := <-rcv.got:
+		return p
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+		return Payload{}
+	}
+}
+
+func setup(t *testing.T, opts Options) (*service.TodoService, *memory.TodoRepository, *Dispatcher) {
+	t.Helper()
+	repo := memory.NewTodoRepository()
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = 5 * time.Millisecond
+	}
+	// the receivers listen on loopback
+	opts.AllowPrivateAddrs = true
+	d := NewDispatcher(repo, opts)
+	d.Start()
+	t.Cleanup(func() { d.Stop(context.Background()) })
+
+	svc := service.NewTodoService(repo)
+	svc.AddEventSink(d)
+	return svc, repo, d
+}
+
+func TestDispatcher_DeliversSignedEvents(t *testing.T) {
+	svc, repo, d := setup(t, Options{})
+	rcv, srv := newReceiver(t, 0)
+	ctx := context.Background()
+
+	hook, _ := repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: srv.URL, Secret: secret})
+	off := false
+	repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: srv.URL, Secret: secret, Active: &off})
+
+	todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Ship"})
+	if p := rcv.wait(t); p.Type != domain.EventTodoCreated || p.Todo.ID != todo.ID || p.ID == "" {
+		t.Errorf("unexpected created payload %+v", p)
+	}
+
+	done := true
+	svc.Update(ctx, todo.ID, domain.UpdateTodoInput{Completed: &done})
+	svc.Delete(ctx, todo.ID, "")
+
+	var types []domain.EventType
+	for i := 0; i < 3; i++ {
+		types = append(types, rcv.wait(t).Type)
+	}
+	// workers run in parallel, so only the set is certain
+	want := map[domain.EventType]bool{domain.EventTodoUpdated: true, domain.EventTodoCompleted: true, domain.EventTodoDeleted: true}
+	for _, typ := range types {
+		if !want[typ] {
+			t.Errorf("unexpected event %q", typ)
+		}
+		delete(want, typ)
+	}
+
+	waitFor(t, func() bool {
+		deliveries := d.Deliveries(hook.ID)
+		for _, delivery := range deliveries {
+			if delivery.Status != domain.DeliverySucceeded {
+				return false
+			}
+		}
+		return len(deliveries) == 4
+	})
+}
+
+func TestDispatcher_FiltersEvents(t *testing.T) {
+	svc, repo, _ := setup(t, Options{Workers: 1})
+	rcv, srv := newReceiver(t, 0)
+	ctx := context.Background()
+
+	repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: srv.URL, Secret: secret, Events: []domain.EventType{domain.EventTodoDeleted}})
+
+	todo, _ := svc.Create(ctx, domain.CreateTodoInput{Title: "Ship"})
+	svc.Delete(ctx, todo.ID, "")
+
+	if p := rcv.wait(t); p.Type != domain.EventTodoDeleted || p.Todo.Title != "Ship" {
+		t.Errorf("expected only the deleted todo, got %+v", p)
+	}
+}
+
+func TestDispatcher_RetriesWithTheSameDeliveryID(t *testing.T) {
+	svc, repo, d := setup(t, Options{MaxAttempts: 5})
+	rcv, srv := newReceiver(t, 2)
+	ctx := context.Background()
+
+	hook, _ := repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: srv.URL, Secret: secret})
+	svc.Create(ctx, domain.CreateTodoInput{Title: "Ship"})
+	rcv.wait(t)
+
+	waitFor(t, func() bool {
+		deliveries := d.Deliveries(hook.ID)
+		return len(deliveries) == 1 && deliveries[0].Status == domain.DeliverySucceeded
+	})
+	delivery := d.Deliveries(hook.ID)[0]
+	if delivery.Attempts != 3 || delivery.StatusCode != http.StatusOK {
+		t.Errorf("expected success on the third attempt, got %+v", delivery)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.ids) != 1 || rcv.ids[delivery.ID] != 3 {
+		t.Errorf("expected 3 requests for delivery %s, got %v", delivery.ID, rcv.ids)
+	}
+}
+
+func TestDispatcher_DeadLetters(t *testing.T) {
+	svc, repo, d := setup(t, Options{MaxAttempts: 3})
+	_, srv := newReceiver(t, 100)
+	ctx := context.Background()
+
+	hook, _ := repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: srv.URL, Secret: secret})
+	svc.Create(ctx, domain.CreateTodoInput{Title: "Ship"})
+
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+	dead := d.DeadLetters()[0]
+	if dead.WebhookID != hook.ID || dead.Attempts != 3 || dead.StatusCode != http.StatusServiceUnavailable || dead.Error == "" {
+		t.Errorf("unexpected dead letter %+v", dead)
+	}
+	if got := d.Deliveries(hook.ID); len(got) != 1 || got[0].Status != domain.DeliveryDead {
+		t.Errorf("expected the logged delivery dead, got %+v", got)
+	}
+}
+
+func TestDispatcher_RefusesPrivateAddrs(t *testing.T) {
+	repo := memory.NewTodoRepository()
+	d := NewDispatcher(repo, Options{MaxAttempts: 1})
+	d.Start()
+	t.Cleanup(func() { d.Stop(context.Background()) })
+	svc := service.NewTodoService(repo)
+	svc.AddEventSink(d)
+
+	rcv, srv := newReceiver(t, 0)
+	ctx := context.Background()
+
+	repo.CreateWebhook(ctx, domain.CreateWebhookInput{URL: srv.URL, Secret: secret})
+	svc.Create(ctx, domain.CreateTodoInput{Title: "Ship"})
+
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+	if dead := d.DeadLetters()[0]; !strings.Contains(dead.Error, errAddrNotPublic.Error()) {
+		t.Errorf("expected the loopback address refused, got %+v", dead)
+	}
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.received) != 0 {
+		t.Errorf("expected nothing delivered, got %+v", rcv.received)
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(memory.NewTodoRepository(), Options{RetryInterval: time.Second, MaxRetryInterval: 5 * time.Second})
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 40: 5 * time.Second} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
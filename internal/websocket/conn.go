@@ -0,0 +1,456 @@
+// Package websocket implements the WebSocket protocol (RFC 6455) for the
+// server: the opening handshake, framing, fragmented messages, ping and pong,
+// and the closing handshake. Dial adds a minimal client. Extensions and
+// subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the opcode of a data frame.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0
+	opText         = 1
+	opBinary       = 2
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// Close codes from RFC 6455 section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const DefaultMaxMessageSize = 1 << 20
+
+// acceptGUID is appended to the client's key to prove the server speaks
+// WebSocket.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var ErrClosed = errors.New("websocket: connection closed")
+
+// CloseError is returned by ReadMessage once the peer sent a close frame.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with %d %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. ReadMessage must be called from a single
+// goroutine; the write methods may be called concurrently with it and with
+// each other.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // masks what it sends instead of what it receives
+
+	// MaxMessageSize bounds a message after reassembly; larger ones close
+	// the connection with CloseMessageTooBig.
+	MaxMessageSize int64
+	// ReadTimeout, when set, closes a connection no frame arrived on for
+	// that long. Pings keep a connection with a live peer from idling out.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds every write when set.
+	WriteTimeout time.Duration
+
+	wmu        sync.Mutex
+	closeSent  bool
+	closeOnce  sync.Once
+	closeError error
+}
+
+// Upgrade upgrades r with an Upgrader that allows no other origins.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	var u Upgrader
+	return u.Upgrade(w, r)
+}
+
+// Upgrader answers opening handshakes. Browsers send an Origin with every
+// handshake, and the server's own origin is always allowed; requests without
+// an Origin do not come from a browser and are allowed too.
+type Upgrader struct {
+	// AllowedOrigins are the other origins, such as
+	// "https://app.example.com", pages may connect from; "*" allows any.
+	AllowedOrigins []string
+}
+
+// Upgrade checks the opening handshake of r, answers it and takes the
+// connection over from the HTTP server. On a bad handshake it responds with
+// an HTTP error itself and returns the reason.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, msg string) (*Conn, error) {
+		http.Error(w, msg, status)
+		return nil, errors.New("websocket: " + msg)
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	if !u.originAllowed(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "connection cannot be upgraded")
+	}
+	// the server's read and write timeouts do not apply any more
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	if _, err := io.WriteString(conn, response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, br: rw.Reader, MaxMessageSize: DefaultMaxMessageSize}, nil
+}
+
+// originAllowed reports whether the Origin of r is absent, the server's own
+// or one of AllowedOrigins.
+func (u *Upgrader) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range u.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept value for a client's key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next data message, answering pings and skipping
+// pongs on the way. Once the peer closes, it answers the close frame and
+// returns a *CloseError; protocol violations close the connection with the
+// matching code.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ     MessageType
+		message []byte
+		started bool
+	)
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.closed(f.payload)
+		case opText, opBinary:
+			if started {
+				return 0, nil, c.fail(violation(CloseProtocolError, "new message inside a fragmented one"))
+			}
+			typ, started = MessageType(f.opcode), true
+		case opContinuation:
+			if !started {
+				return 0, nil, c.fail(violation(CloseProtocolError, "continuation without a message"))
+			}
+		default:
+			return 0, nil, c.fail(violation(CloseProtocolError, "unknown opcode"))
+		}
+
+		if int64(len(message))+int64(len(f.payload)) > c.MaxMessageSize {
+			return 0, nil, c.fail(violation(CloseMessageTooBig, "message too big"))
+		}
+		message = append(message, f.payload...)
+		if !f.fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(violation(CloseInvalidPayload, "text is not valid UTF-8"))
+		}
+		return typ, message, nil
+	}
+}
+
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// protocolError is a violation by the peer, closed with code.
+type protocolError struct {
+	code   int
+	reason string
+}
+
+func (e *protocolError) Error() string { return "websocket: " + e.reason }
+
+func (c *Conn) readFrame() (frame, error) {
+	if c.ReadTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: header[0]&0x80 != 0, opcode: header[0] & 0x0f}
+	if header[0]&0x70 != 0 {
+		return frame{}, violation(CloseProtocolError, "reserved bits set")
+	}
+	if masked := header[1]&0x80 != 0; masked == c.client {
+		return frame{}, violation(CloseProtocolError, "only client frames are masked")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n > 1<<62 {
+			return frame{}, violation(CloseProtocolError, "invalid length")
+		}
+		length = int64(n)
+	}
+
+	if f.opcode >= opClose && (!f.fin || length > 125) {
+		return frame{}, violation(CloseProtocolError, "invalid control frame")
+	}
+	if length > c.MaxMessageSize {
+		return frame{}, violation(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if !c.client {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return frame{}, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return frame{}, err
+	}
+	maskBytes(mask, f.payload)
+	return f, nil
+}
+
+func violation(code int, reason string) error {
+	return &protocolError{code: code, reason: reason}
+}
+
+// fail closes the connection after a read error, telling the peer why when
+// it broke the protocol.
+func (c *Conn) fail(err error) error {
+	var perr *protocolError
+	if errors.As(err, &perr) {
+		c.WriteClose(perr.code, perr.reason)
+	}
+	c.Close()
+	return err
+}
+
+// closed answers the peer's close frame and closes the connection.
+func (c *Conn) closed(payload []byte) error {
+	cerr := &CloseError{Code: CloseNoStatus}
+	if len(payload) == 1 {
+		return c.fail(violation(CloseProtocolError, "invalid close frame"))
+	}
+	if len(payload) >= 2 {
+		cerr.Code = int(binary.BigEndian.Uint16(payload))
+		cerr.Reason = string(payload[2:])
+		if !validCloseCode(cerr.Code) || !utf8.ValidString(cerr.Reason) {
+			return c.fail(violation(CloseProtocolError, "invalid close frame"))
+		}
+	}
+
+	if cerr.Code == CloseNoStatus {
+		c.writeFrame(opClose, nil)
+	} else {
+		c.WriteClose(cerr.Code, "")
+	}
+	c.Close()
+	return cerr
+}
+
+// validCloseCode reports whether code may be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != CloseNoStatus && code != 1006
+}
+
+// WriteMessage sends data as a single frame.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.New("websocket: invalid message type")
+	}
+	return c.writeFrame(byte(typ), data)
+}
+
+// Ping sends a ping; the peer's pong only resets ReadTimeout.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// WriteClose starts the closing handshake. Nothing can be written after it;
+// ReadMessage returns a *CloseError once the peer answers.
+func (c *Conn) WriteClose(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return c.writeFrame(opClose, append(payload, reason...))
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	buf := payload
+	if c.client {
+		header[1] |= 0x80
+		var mask [4]byte
+		rand.Read(mask[:])
+		header = append(header, mask[:]...)
+		buf = make([]byte, len(payload))
+		copy(buf, payload)
+		maskBytes(mask, buf)
+	}
+
+	if c.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	}
+	if _, err := c.conn.Write(append(header, buf...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// maskBytes masks or unmasks b; a zero mask leaves it as it is.
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+// CloseGracefully starts the closing handshake and closes the connection
+// after timeout in case the peer never answers; ReadMessage closes it as soon
+// as the answer arrives.
+func (c *Conn) CloseGracefully(code int, reason string, timeout time.Duration) error {
+	err := c.WriteClose(code, reason)
+	time.AfterFunc(timeout, func() { c.Close() })
+	return err
+}
+
+// Close closes the underlying connection without a closing handshake.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeError = c.conn.Close()
+	})
+	return c.closeError
+}
@@ -0,0 +1,220 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer echoes every message back until the client closes.
+func echoServer(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(typ, msg)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, url)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.ReadTimeout = 2 * time.Second
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// rawFrame builds a masked client frame by hand.
+func rawFrame(first byte, payload string) []byte {
+	mask := [4]byte{1, 2, 3, 4}
+	b := []byte(payload)
+	maskBytes(mask, b)
+	return append(append([]byte{first, 0x80 | byte(len(payload))}, mask[:]...), b...)
+}
+
+func TestAcceptKey(t *testing.T) {
+	// the example from RFC 6455 section 1.3
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey = %q", got)
+	}
+}
+
+func TestUpgrade_RejectsBadHandshakes(t *testing.T) {
+	url := strings.Replace(echoServer(t), "ws", "http", 1)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"plain request", nil, http.StatusBadRequest},
+		{"old version", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}, http.StatusUpgradeRequired},
+		{"bad key", map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{"foreign origin", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "https://evil.example"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestUpgrader_AllowedOrigins(t *testing.T) {
+	u := &Upgrader{AllowedOrigins: []string{"https://app.example.com"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := u.Upgrade(w, r); err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{srv.URL, http.StatusSwitchingProtocols},
+		{"https://APP.example.com", http.StatusSwitchingProtocols},
+		{"https://app.example.com.evil.example", http.StatusForbidden},
+		{"http://app.example.com", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("origin %q: expected %d, got %d", tt.origin, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestConn_EchoAndClose(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	long := strings.Repeat("x", 70000) // needs the 64-bit length
+	for _, msg := range []string{"hello", strings.Repeat("y", 300), long} {
+		if err := conn.WriteMessage(TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+		typ, got, err := conn.ReadMessage()
+		if err != nil || typ != TextMessage || string(got) != msg {
+			t.Fatalf("expected %d bytes echoed, got %d bytes, %v", len(msg), len(got), err)
+		}
+	}
+
+	if err := conn.WriteClose(CloseNormal, "bye"); err != nil {
+		t.Fatalf("WriteClose failed: %v", err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after the close frame, got %v", err)
+	}
+	var cerr *CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &cerr) || cerr.Code != CloseNormal {
+		t.Errorf("expected the close echoed, got %v", err)
+	}
+}
+
+func TestConn_FragmentsAndPings(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	// "Hel", a ping in between, then "lo"
+	raw := append(rawFrame(0x01, "Hel"), rawFrame(0x89, "p")...)
+	raw = append(raw, rawFrame(0x80, "lo")...)
+	if _, err := conn.conn.Write(raw); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	// the pong is skipped by the client's ReadMessage
+	typ, got, err := conn.ReadMessage()
+	if err != nil || typ != TextMessage || string(got) != "Hello" {
+		t.Errorf("expected Hello reassembled, got %q, %v", got, err)
+	}
+}
+
+func TestConn_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want int
+	}{
+		{"unmasked", []byte{0x81, 0x02, 'h', 'i'}, CloseProtocolError},
+		{"reserved bits", rawFrame(0xC1, "hi"), CloseProtocolError},
+		{"unknown opcode", rawFrame(0x83, "hi"), CloseProtocolError},
+		{"stray continuation", rawFrame(0x80, "hi"), CloseProtocolError},
+		{"fragmented ping", rawFrame(0x09, "hi"), CloseProtocolError},
+		{"invalid utf-8", rawFrame(0x81, "\xff\xfe"), CloseInvalidPayload},
+		{"reserved close code", rawFrame(0x88, "\x03\xed"), CloseProtocolError},
+	}
+	url := echoServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dial(t, url)
+			if _, err := conn.conn.Write(tt.raw); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			var cerr *CloseError
+			if _, _, err := conn.ReadMessage(); !errors.As(err, &cerr) || cerr.Code != tt.want {
+				t.Errorf("expected close %d, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestConn_MessageTooBig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.MaxMessageSize = 4
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	conn := dial(t, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	conn.WriteMessage(BinaryMessage, []byte("too long"))
+	var cerr *CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &cerr) || cerr.Code != CloseMessageTooBig {
+		t.Errorf("expected close %d, got %v", CloseMessageTooBig, err)
+	}
+}